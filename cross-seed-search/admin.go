@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type adminConfig struct {
+	Addr   string
+	APIKey string
+}
+
+func loadAdminConfig() *adminConfig {
+	return &adminConfig{
+		Addr:   os.Getenv("ADMIN_ADDR"),
+		APIKey: os.Getenv("ADMIN_API_KEY"),
+	}
+}
+
+type adminEvent struct {
+	ID       uint64    `json:"id"`
+	Instance string    `json:"instance,omitempty"`
+	Kind     eventKind `json:"event"`
+	Name     string    `json:"name"`
+	InfoHash string    `json:"info_hash"`
+	Category string    `json:"category"`
+	Host     string    `json:"host"`
+	Sinks    []string  `json:"sinks,omitempty"`
+	Time     time.Time `json:"time"`
+	Queued   time.Time `json:"queued"`
+}
+
+type adminFailure struct {
+	adminEvent
+	Sink     string    `json:"sink"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+func newAdminEvent(ev torrentEvent) adminEvent {
+	return adminEvent{
+		ID:       ev.ID,
+		Instance: ev.Release.Instance,
+		Kind:     ev.Kind,
+		Name:     ev.Release.Name,
+		InfoHash: ev.Release.InfoHash,
+		Category: ev.Release.Category,
+		Host:     eventHost(ev),
+		Sinks:    ev.Sinks,
+		Time:     ev.Time,
+		Queued:   ev.Queued,
+	}
+}
+
+// startAdminServer exposes the daemon's queue and failure history. Every
+// request must carry the API key in X-Api-Key.
+func startAdminServer(ctx context.Context, cfg *adminConfig, d *daemon) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status", d.handleStatus)
+	mux.HandleFunc("GET /api/queue", d.handleQueue)
+	mux.HandleFunc("DELETE /api/queue/{id}", d.handleDropQueued)
+	mux.HandleFunc("GET /api/failures", d.handleFailures)
+	mux.HandleFunc("POST /api/failures/{id}/requeue", d.handleRequeue)
+	mux.HandleFunc("DELETE /api/failures/{id}", d.handleDropFailure)
+
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           requireAPIKey(cfg.APIKey, mux),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		log.Info("Starting admin server", "addr", cfg.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Admin server failed", "error", err)
+		}
+	}()
+}
+
+func requireAPIKey(key string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get("X-Api-Key")
+		if got == "" {
+			got, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(key)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid API key"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (d *daemon) handleStatus(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	perHost := make(map[string]int)
+	perInstance := make(map[string]int)
+	for _, ev := range d.shaper.list() {
+		perHost[eventHost(ev)]++
+		perInstance[ev.Release.Instance]++
+	}
+
+	instances := make([]map[string]any, 0, len(d.dcfg.Instances))
+	processed, failed := 0, 0
+	for _, inst := range d.dcfg.Instances {
+		instances = append(instances, map[string]any{
+			"name":         inst.Name,
+			"url":          redactURL(inst.URL),
+			"pending":      perInstance[inst.Name],
+			"processed":    d.processed[inst.Name],
+			"failed_total": d.failedTotal[inst.Name],
+		})
+		processed += d.processed[inst.Name]
+		failed += d.failedTotal[inst.Name]
+	}
+
+	status := map[string]any{
+		"version":          version,
+		"started":          d.started,
+		"uptime":           time.Since(d.started).Round(time.Second).String(),
+		"pending":          d.shaper.len(),
+		"pending_by_host":  perHost,
+		"processed":        processed,
+		"failed_total":     failed,
+		"recent_failures":  len(d.failures),
+		"cross_seed":       d.cfg.CrossSeedEnabled,
+		"pushover":         d.cfg.PushoverEnabled,
+		"instances":        instances,
+		"queue_size_limit": d.dcfg.QueueSize,
+		"workers":          d.dcfg.Workers,
+		"in_flight":        len(d.workers),
+	}
+	d.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (d *daemon) handleQueue(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	queued := d.shaper.list()
+	d.mu.Unlock()
+
+	events := make([]adminEvent, 0, len(queued))
+	for _, ev := range queued {
+		events = append(events, newAdminEvent(ev))
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+func (d *daemon) handleDropQueued(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseEventID(w, r)
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	removed := d.shaper.remove(id)
+	d.mu.Unlock()
+
+	if !removed {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "event not queued"})
+		return
+	}
+	log.Info("Dropped queued event via admin API", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (d *daemon) handleFailures(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	failures := make([]adminFailure, 0, len(d.failures))
+	for i := len(d.failures) - 1; i >= 0; i-- {
+		f := d.failures[i]
+		failures = append(failures, adminFailure{
+			adminEvent: newAdminEvent(f.Event),
+			Sink:       f.Sink,
+			Error:      f.Error,
+			FailedAt:   f.Time,
+		})
+	}
+	d.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, failures)
+}
+
+// handleRequeue queues a failed event again, retrying only the sinks that
+// failed for it.
+func (d *daemon) handleRequeue(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseEventID(w, r)
+	if !ok {
+		return
+	}
+	if d.queueFull() {
+		d.rejectBusy(w)
+		return
+	}
+
+	removed := d.takeFailures(id)
+	if len(removed) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no failure recorded for event"})
+		return
+	}
+
+	ev := removed[0].Event
+	ev.Sinks = nil
+	for _, f := range removed {
+		ev.Sinks = append(ev.Sinks, f.Sink)
+	}
+	d.enqueue(ev)
+
+	log.Info("Requeued failed event via admin API", "id", id, "sinks", ev.Sinks)
+	writeJSON(w, http.StatusAccepted, newAdminEvent(ev))
+}
+
+func (d *daemon) handleDropFailure(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseEventID(w, r)
+	if !ok {
+		return
+	}
+	if len(d.takeFailures(id)) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no failure recorded for event"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// takeFailures removes and returns the recorded failures of an event.
+func (d *daemon) takeFailures(id uint64) []eventFailure {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var taken []eventFailure
+	kept := d.failures[:0]
+	for _, f := range d.failures {
+		if f.Event.ID == id {
+			taken = append(taken, f)
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	d.failures = kept
+	return taken
+}
+
+func parseEventID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid event id"})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debug("Failed to encode JSON response", "error", err)
+	}
+}
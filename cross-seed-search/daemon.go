@@ -0,0 +1,600 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"golang.org/x/time/rate"
+)
+
+type qbittorrentInstance struct {
+	Name     string
+	URL      string
+	Username string
+	Password string
+	// PasswordFile is re-read on each login, so a password rotated by
+	// qbittorrent-init is picked up. Password is used while it is missing.
+	PasswordFile string
+}
+
+type daemonConfig struct {
+	Instances       []qbittorrentInstance
+	PollInterval    time.Duration
+	QueueSize       int
+	PushoverEvents  map[eventKind]bool
+	HostRate        hostRate
+	HostRates       map[string]hostRate
+	FailureHistory  int
+	ShutdownTimeout time.Duration
+	RetryPath       string
+	Workers         int
+	SinkConcurrency map[string]int
+	DigestWindow    time.Duration
+	// PushoverStats adds live transfer stats from qBittorrent to Pushover
+	// notifications.
+	PushoverStats bool
+	// StatsWindow is how long stats lookups are collected before
+	// they are fetched together.
+	StatsWindow time.Duration
+}
+
+func loadDaemonConfig() (*daemonConfig, error) {
+	events := make(map[eventKind]bool)
+	for _, name := range strings.Split(getEnv("PUSHOVER_EVENTS", "completed"), ",") {
+		if name = strings.TrimSpace(strings.ToLower(name)); name != "" {
+			events[eventKind(name)] = true
+		}
+	}
+
+	defaultRate := hostRate{
+		every: getEnvDuration("DAEMON_HOST_RATE_INTERVAL", 5*time.Second),
+		burst: max(getEnvInt("DAEMON_HOST_RATE_BURST", 2), 1),
+	}
+	hostRates, err := parseHostRates(os.Getenv("DAEMON_HOST_RATES"), defaultRate)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := loadQBittorrentInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	workers := max(getEnvInt("WORKERS", 1), 1)
+	sinkConcurrency := map[string]int{
+		sinkPushover:  getEnvInt("PUSHOVER_CONCURRENCY", workers),
+		sinkCrossSeed: getEnvInt("CROSS_SEED_CONCURRENCY", workers),
+	}
+	for sink, n := range sinkConcurrency {
+		sinkConcurrency[sink] = min(max(n, 1), workers)
+	}
+
+	return &daemonConfig{
+		Instances:       instances,
+		PollInterval:    getEnvDuration("DAEMON_POLL_INTERVAL", 5*time.Second),
+		QueueSize:       getEnvInt("DAEMON_QUEUE_SIZE", 256),
+		PushoverEvents:  events,
+		HostRate:        defaultRate,
+		HostRates:       hostRates,
+		FailureHistory:  max(getEnvInt("DAEMON_FAILURE_HISTORY", 100), 1),
+		ShutdownTimeout: getEnvDuration("DAEMON_SHUTDOWN_TIMEOUT", 25*time.Second),
+		RetryPath:       getEnv("DAEMON_RETRY_JOURNAL_PATH", "/config/notifier/retry.jsonl"),
+		Workers:         workers,
+		SinkConcurrency: sinkConcurrency,
+		DigestWindow:    getEnvDuration("PUSHOVER_DIGEST_WINDOW", 0),
+		PushoverStats:   getEnvBool("PUSHOVER_INCLUDE_STATS", false),
+		StatsWindow:     getEnvDuration("PUSHOVER_STATS_BATCH_WINDOW", 250*time.Millisecond),
+	}, nil
+}
+
+// loadQBittorrentInstances reads QBT_INSTANCES, a comma separated list of
+// instance names, each configured through QBT_INSTANCE_<NAME>_URL,
+// _USERNAME, _PASSWORD and _PASSWORD_FILE. Without it a single unnamed
+// instance is read from QBT_WEBUI_URL, QBT_USERNAME, QBT_PASSWORD and
+// QBT_PASSWORD_FILE.
+func loadQBittorrentInstances() ([]qbittorrentInstance, error) {
+	names := os.Getenv("QBT_INSTANCES")
+	if strings.TrimSpace(names) == "" {
+		return []qbittorrentInstance{{
+			URL:          getEnv("QBT_WEBUI_URL", "http://127.0.0.1:8080"),
+			Username:     os.Getenv("QBT_USERNAME"),
+			Password:     os.Getenv("QBT_PASSWORD"),
+			PasswordFile: os.Getenv("QBT_PASSWORD_FILE"),
+		}}, nil
+	}
+
+	var instances []qbittorrentInstance
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "QBT_INSTANCE_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name)) + "_"
+		if seen[prefix] {
+			return nil, fmt.Errorf("duplicate qBittorrent instance %q", name)
+		}
+		seen[prefix] = true
+
+		inst := qbittorrentInstance{
+			Name:         name,
+			URL:          os.Getenv(prefix + "URL"),
+			Username:     os.Getenv(prefix + "USERNAME"),
+			Password:     os.Getenv(prefix + "PASSWORD"),
+			PasswordFile: os.Getenv(prefix + "PASSWORD_FILE"),
+		}
+		if inst.URL == "" {
+			return nil, fmt.Errorf("qBittorrent instance %q: %sURL is not set", name, prefix)
+		}
+		instances = append(instances, inst)
+	}
+	return instances, nil
+}
+
+const (
+	sinkPushover  = "pushover"
+	sinkCrossSeed = "cross-seed"
+)
+
+type eventFailure struct {
+	Event torrentEvent
+	Sink  string
+	Error string
+	Time  time.Time
+}
+
+// daemon owns the event queue. The main loop and the admin API share it, so
+// everything below mu is only touched with the lock held.
+type daemon struct {
+	cfg             *Config
+	dcfg            *daemonConfig
+	pushoverLimiter *rate.Limiter
+	journal         *eventJournal
+	failureJournal  string
+	events          chan torrentEvent
+	wake            chan struct{}
+	started         time.Time
+	// workers and sinkSlots are semaphores bounding the events processed
+	// at once and the concurrent sends per sink.
+	workers   chan struct{}
+	sinkSlots map[string]chan struct{}
+	inFlight  sync.WaitGroup
+	// digest is set when completions are batched into summaries.
+	digest *pushoverDigest
+	// stats fetches torrent stats per instance name.
+	stats map[string]*statsBatcher
+
+	mu          sync.Mutex
+	shaper      *hostShaper
+	nextID      uint64
+	processed   map[string]int
+	failedTotal map[string]int
+	failures    []eventFailure
+	interrupted []torrentEvent
+}
+
+// runDaemon watches qBittorrent for torrent events and dispatches them to the
+// configured sinks, replacing the "run external program" hook.
+func runDaemon(ctx context.Context, cfg *Config) error {
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+	dcfg, err := loadDaemonConfig()
+	if err != nil {
+		return err
+	}
+	for kind := range dcfg.PushoverEvents {
+		switch kind {
+		case eventAdded, eventCompleted, eventErrored:
+		default:
+			return fmt.Errorf("unknown event %q in PUSHOVER_EVENTS", kind)
+		}
+	}
+
+	// A standby replica waits here, before it touches the retry journal or
+	// opens any listeners.
+	lead, err := electLeader(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}
+	if lead != nil {
+		defer lead.release()
+		ctx = lead.ctx
+	}
+
+	d := &daemon{
+		cfg:             cfg,
+		dcfg:            dcfg,
+		pushoverLimiter: rate.NewLimiter(rate.Every(5*time.Second), 2),
+		events:          make(chan torrentEvent, dcfg.QueueSize),
+		wake:            make(chan struct{}, 1),
+		started:         time.Now(),
+		shaper:          newHostShaper(dcfg.HostRate, dcfg.HostRates),
+		journal:         openConfiguredJournal(),
+		failureJournal:  failureJournalPath(),
+		processed:       make(map[string]int),
+		failedTotal:     make(map[string]int),
+		workers:         make(chan struct{}, dcfg.Workers),
+		sinkSlots:       make(map[string]chan struct{}),
+		stats:           make(map[string]*statsBatcher),
+	}
+	for sink, n := range dcfg.SinkConcurrency {
+		d.sinkSlots[sink] = make(chan struct{}, n)
+	}
+	if d.journal != nil {
+		defer d.journal.close()
+	}
+
+	retries, err := takeRetryJournal(dcfg.RetryPath)
+	if err != nil {
+		log.Warn("Failed to load events left over from the last shutdown", "path", dcfg.RetryPath, "error", err)
+	}
+	for _, ev := range retries {
+		d.enqueue(ev)
+	}
+	if len(retries) > 0 {
+		log.Info("Requeued events left over from the last shutdown", "count", len(retries))
+	}
+
+	// Sends get their own context so that a shutdown lets the event in
+	// flight finish, up to DAEMON_SHUTDOWN_TIMEOUT.
+	sendCtx, cancelSend := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelSend()
+	stopDeadline := context.AfterFunc(ctx, func() {
+		time.AfterFunc(dcfg.ShutdownTimeout, cancelSend)
+	})
+	defer stopDeadline()
+
+	if dcfg.DigestWindow > 0 && cfg.PushoverEnabled {
+		d.digest = &pushoverDigest{}
+		go d.runDigest(ctx, sendCtx)
+		log.Info("Batching Pushover completion notifications", "window", dcfg.DigestWindow)
+	}
+
+	watchStateDump(ctx, d)
+
+	scfg := loadSweepConfig()
+	if scfg.Enabled && !cfg.CrossSeedEnabled {
+		return errors.New("CROSS_SEED_SWEEP_ENABLED requires CROSS_SEED_ENABLED")
+	}
+
+	if acfg := loadAdminConfig(); acfg.Addr != "" {
+		if acfg.APIKey == "" {
+			return errors.New("ADMIN_ADDR requires ADMIN_API_KEY")
+		}
+		startAdminServer(ctx, acfg, d)
+	}
+
+	wcfg, err := loadWebhookConfig()
+	if err != nil {
+		return err
+	}
+	if wcfg.Addr != "" {
+		if wcfg.APIKey == "" {
+			return errors.New("WEBHOOK_ADDR requires WEBHOOK_API_KEY")
+		}
+		startWebhookServer(ctx, wcfg, d)
+	}
+
+	icfg, err := loadInjectConfig(dcfg.Instances)
+	if err != nil {
+		return err
+	}
+
+	watchErr := make(chan error, len(dcfg.Instances))
+	for _, inst := range dcfg.Instances {
+		client := newQBittorrentClient(inst)
+		d.stats[inst.Name] = newStatsBatcher(client, dcfg.StatsWindow)
+		watcher := newMaindataWatcher(client, dcfg.PollInterval)
+
+		log.Info("Watching qBittorrent instance",
+			"instance", inst.Name,
+			"qbittorrent_url", inst.URL,
+			"poll_interval", dcfg.PollInterval,
+			"workers", dcfg.Workers)
+
+		if scfg.Enabled {
+			go runCrossSeedSweep(ctx, scfg, client, d.events)
+		}
+		if icfg.Dir != "" && inst.Name == icfg.Instance {
+			go runCrossSeedInject(ctx, icfg, client)
+		}
+		go func() {
+			watchErr <- watcher.run(ctx, d.events)
+		}()
+	}
+
+	for {
+		if ctx.Err() != nil {
+			d.inFlight.Wait()
+			d.flushFinalDigest(sendCtx)
+			return errors.Join(d.drain(), lead.err())
+		}
+
+		// Events are only taken from the shaper when a worker is free, so
+		// host tokens are not spent on events that would wait anyway.
+		var (
+			ev   torrentEvent
+			wait time.Duration
+			ok   bool
+		)
+		d.mu.Lock()
+		if len(d.workers) < cap(d.workers) {
+			ev, wait, ok = d.shaper.pop(time.Now())
+		}
+		pending := d.shaper.len()
+		d.mu.Unlock()
+		if ok {
+			d.workers <- struct{}{}
+			d.inFlight.Add(1)
+			go d.work(sendCtx, ev)
+			continue
+		}
+
+		var (
+			timer    <-chan time.Time
+			incoming <-chan torrentEvent
+		)
+		if wait > 0 {
+			timer = time.After(wait)
+		}
+		// Stop reading while the shaper is full so the watcher and the
+		// sweep block instead of growing the backlog without bound.
+		if pending < dcfg.QueueSize {
+			incoming = d.events
+		}
+
+		select {
+		case ev := <-incoming:
+			d.enqueue(ev)
+		case <-timer:
+		case <-d.wake:
+		case <-ctx.Done():
+		case err := <-watchErr:
+			if errors.Is(err, context.Canceled) {
+				continue
+			}
+			return err
+		}
+	}
+}
+
+// drain persists everything still queued to the retry journal. Intake has
+// already stopped with the context, so only events buffered in the channel
+// and the shaper's backlog are left.
+func (d *daemon) drain() error {
+	for buffered := true; buffered; {
+		select {
+		case ev := <-d.events:
+			d.enqueue(ev)
+		default:
+			buffered = false
+		}
+	}
+
+	d.mu.Lock()
+	remaining := append(d.interrupted, d.shaper.list()...)
+	d.mu.Unlock()
+
+	if len(remaining) == 0 {
+		log.Info("Shutting down, no queued events")
+		return nil
+	}
+	if err := writeRetryJournal(d.dcfg.RetryPath, remaining); err != nil {
+		return fmt.Errorf("failed to persist %d queued events: %w", len(remaining), err)
+	}
+	log.Info("Shutting down, persisted queued events", "count", len(remaining), "path", d.dcfg.RetryPath)
+	return nil
+}
+
+// work processes one event on a worker and frees the worker afterwards.
+func (d *daemon) work(ctx context.Context, ev torrentEvent) {
+	defer func() {
+		<-d.workers
+		d.inFlight.Done()
+		d.wakeUp()
+	}()
+
+	failures := d.process(ctx, ev)
+	if len(failures) == 0 {
+		return
+	}
+	if ctx.Err() == nil {
+		if err := appendFailures(d.failureJournal, failures); err != nil {
+			log.Warn("Failed to record failed deliveries", "error", err)
+		}
+	} else {
+		// Cut off by the shutdown deadline: keep the sinks that did not
+		// get through for the next start.
+		ev.Sinks = nil
+		for _, f := range failures {
+			ev.Sinks = append(ev.Sinks, f.Sink)
+		}
+		d.mu.Lock()
+		d.interrupted = append(d.interrupted, ev)
+		d.mu.Unlock()
+	}
+}
+
+// send runs fn once a slot for the sink is free.
+func (d *daemon) send(ctx context.Context, sink string, fn func() error) error {
+	slots := d.sinkSlots[sink]
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-slots }()
+	return fn()
+}
+
+// enqueue assigns new events an ID and hands them to the shaper. It is also
+// used by the admin API to requeue failed events.
+func (d *daemon) enqueue(ev torrentEvent) {
+	d.mu.Lock()
+	if ev.ID == 0 {
+		d.nextID++
+		ev.ID = d.nextID
+	}
+	ev.Queued = time.Now()
+	d.shaper.push(ev)
+	d.mu.Unlock()
+	d.wakeUp()
+}
+
+// queueFull reports whether the shaper holds as many events as the queue
+// allows. Producers that can't wait, such as the HTTP APIs, reject new
+// events until it drains.
+func (d *daemon) queueFull() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.shaper.len() >= d.dcfg.QueueSize
+}
+
+// rejectBusy answers 429 with a Retry-After of roughly one host rate
+// interval, when the next queued event is released.
+func (d *daemon) rejectBusy(w http.ResponseWriter) {
+	retry := max(int(d.dcfg.HostRate.every.Round(time.Second)/time.Second), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "queue full"})
+}
+
+func (d *daemon) wakeUp() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// process delivers one event to the sinks and returns the failures. Cross-seed
+// searches are paced per tracker by the host shaper; Pushover has a single
+// global limit. When ev.Sinks is set (a requeued failure) only those sinks are
+// retried.
+func (d *daemon) process(ctx context.Context, ev torrentEvent) []eventFailure {
+	release := ev.Release
+	release.Event = ev.Kind
+	if err := validateRelease(release); err != nil {
+		log.WarnContext(ctx, "Skipping event with invalid release info",
+			"event", ev.Kind,
+			"hash", release.InfoHash,
+			"error", err)
+		return nil
+	}
+
+	// Sweeps re-search on purpose and requeued failures must go through.
+	journaled := d.journal != nil && ev.Kind != eventSweep
+	if journaled && len(ev.Sinks) == 0 && d.journal.has(release.Instance, release.InfoHash, ev.Kind) {
+		log.InfoContext(ctx, "Skipping already processed event",
+			"instance", release.Instance,
+			"event", ev.Kind,
+			"hash", release.InfoHash)
+		return nil
+	}
+
+	log.InfoContext(ctx, "Torrent event",
+		"instance", release.Instance,
+		"event", ev.Kind,
+		"name", release.Name,
+		"hash", release.InfoHash,
+		"state", ev.State)
+
+	var failures []eventFailure
+	fail := func(sink string, err error) {
+		failures = append(failures, eventFailure{Event: ev, Sink: sink, Error: err.Error(), Time: time.Now()})
+	}
+
+	if d.cfg.PushoverEnabled && d.dcfg.PushoverEvents[ev.Kind] && ev.wantsSink(sinkPushover) {
+		if d.digest != nil && ev.Kind == eventCompleted {
+			d.digest.add(ev)
+		} else if err := d.pushoverLimiter.Wait(ctx); err != nil {
+			log.WarnContext(ctx, "Rate limit exceeded for Pushover", "error", err)
+			fail(sinkPushover, err)
+		} else if err := d.send(ctx, sinkPushover, func() error {
+			release.IndexerName = d.cfg.Indexers.resolve(ctx, release.Indexer)
+			msg := releaseNotification(d.cfg, release)
+			if d.dcfg.PushoverStats {
+				d.addTorrentStats(ctx, release, &msg)
+			}
+			return sendPushoverMessage(ctx, d.cfg, msg, d.cfg.pushoverPriority(release.Event))
+		}); err != nil {
+			log.ErrorContext(ctx, "Pushover notification failed", "error", err)
+			fail(sinkPushover, err)
+		}
+	}
+
+	if d.cfg.CrossSeedEnabled && (ev.Kind == eventCompleted || ev.Kind == eventSweep) && ev.wantsSink(sinkCrossSeed) {
+		if err := d.send(ctx, sinkCrossSeed, func() error {
+			return searchCrossSeed(ctx, d.cfg, release)
+		}); err != nil {
+			log.ErrorContext(ctx, "CrossSeed search failed", "error", err)
+			fail(sinkCrossSeed, err)
+		}
+	}
+
+	if journaled && len(failures) == 0 {
+		if err := d.journal.record(release.Instance, release.InfoHash, ev.Kind); err != nil {
+			log.WarnContext(ctx, "Failed to record processed event", "error", err)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.processed[release.Instance]++
+	d.failedTotal[release.Instance] += len(failures)
+	d.failures = append(d.failures, failures...)
+	if excess := len(d.failures) - d.dcfg.FailureHistory; excess > 0 {
+		d.failures = slices.Delete(d.failures, 0, excess)
+	}
+	return failures
+}
+
+// validateRelease validates release info built from qBittorrent's own state.
+// Unlike the command line arguments, the category and tracker may legitimately
+// be empty there (uncategorized torrents, no working tracker yet).
+func validateRelease(release *ReleaseInfo) error {
+	var skip []string
+	if release.Category == "" {
+		skip = append(skip, "Category")
+	}
+	if release.Indexer == "" {
+		skip = append(skip, "Indexer")
+	}
+	if err := validate.StructExcept(release, skip...); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	return nil
+}
+
+// addTorrentStats appends the torrent's live transfer stats to msg. Stats are
+// a nice-to-have, so failing to fetch them only drops them from the message.
+func (d *daemon) addTorrentStats(ctx context.Context, release *ReleaseInfo, msg *notification) {
+	batcher := d.stats[release.Instance]
+	if batcher == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second+d.dcfg.StatsWindow)
+	defer cancel()
+	stats, err := batcher.get(ctx, release.InfoHash)
+	if err != nil {
+		log.DebugContext(ctx, "Failed to fetch torrent stats", "hash", release.InfoHash, "error", err)
+		return
+	}
+	msg.Fields = append(msg.Fields,
+		[2]string{"Downloaded", humanize.Bytes(uint64(max(stats.Downloaded, 0)))},
+		[2]string{"Uploaded", humanize.Bytes(uint64(max(stats.Uploaded, 0)))},
+		[2]string{"Ratio", fmt.Sprintf("%.2f", stats.Ratio)},
+		[2]string{"Seeds", fmt.Sprintf("%d (%d in swarm)", stats.NumSeeds, stats.NumComplete)},
+	)
+}
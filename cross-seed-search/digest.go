@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// pushoverMessageLimit is the longest message Pushover accepts.
+const pushoverMessageLimit = 1024
+
+// pushoverDigest collects completions for PUSHOVER_DIGEST_WINDOW and sends
+// them as one summary notification instead of one message per torrent.
+type pushoverDigest struct {
+	mu     sync.Mutex
+	events []torrentEvent
+}
+
+func (g *pushoverDigest) add(ev torrentEvent) {
+	g.mu.Lock()
+	g.events = append(g.events, ev)
+	g.mu.Unlock()
+}
+
+func (g *pushoverDigest) take() []torrentEvent {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	events := g.events
+	g.events = nil
+	return events
+}
+
+// putBack returns events whose summary could not be sent, so they are part
+// of the next one.
+func (g *pushoverDigest) putBack(events []torrentEvent) {
+	g.mu.Lock()
+	g.events = append(events, g.events...)
+	g.mu.Unlock()
+}
+
+// runDigest sends the digest every window until ctx is done. The final flush
+// on shutdown is left to the caller.
+func (d *daemon) runDigest(ctx, sendCtx context.Context) {
+	ticker := time.NewTicker(d.dcfg.DigestWindow)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if events, err := d.flushDigest(sendCtx); err != nil {
+				log.ErrorContext(ctx, "Pushover digest failed, retrying with the next one", "count", len(events), "error", err)
+				d.digest.putBack(events)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// flushDigest sends everything collected so far. On failure it returns the
+// events that were not delivered.
+func (d *daemon) flushDigest(ctx context.Context) ([]torrentEvent, error) {
+	events := d.digest.take()
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	if err := d.pushoverLimiter.Wait(ctx); err != nil {
+		return events, err
+	}
+	err := d.send(ctx, sinkPushover, func() error {
+		if len(events) == 1 {
+			release := *events[0].Release
+			release.Event = events[0].Kind
+			return sendPushoverNotification(ctx, d.cfg, &release)
+		}
+		msg := digestMessage(events, d.cfg.PushoverFormat)
+		return sendPushoverMessage(ctx, d.cfg, msg, d.cfg.pushoverPriority(eventCompleted))
+	})
+	if err != nil {
+		return events, err
+	}
+	log.InfoContext(ctx, "Sent Pushover digest", "count", len(events))
+	return nil, nil
+}
+
+// digestMessage summarises the events and lists as many torrent names as fit
+// in a Pushover message.
+func digestMessage(events []torrentEvent, format messageFormat) notification {
+	var total uint64
+	for _, ev := range events {
+		total += uint64(max(ev.Release.Size, 0))
+	}
+	msg := notification{Title: fmt.Sprintf("%d torrents completed", len(events))}
+	msg.Heading = fmt.Sprintf("%s, %s total", msg.Title, humanize.IBytes(total))
+
+	for i, ev := range events {
+		line := fmt.Sprintf("%s (%s)",
+			strings.TrimSuffix(ev.Release.Name, ".torrent"),
+			humanize.IBytes(uint64(max(ev.Release.Size, 0))))
+		candidate := msg
+		candidate.Lines = append(slices.Clip(msg.Lines), line)
+		if rest := len(events) - i - 1; rest > 0 {
+			candidate.Lines = append(candidate.Lines, fmt.Sprintf("… and %d more", rest))
+		}
+		if len(candidate.render(format)) > pushoverMessageLimit {
+			msg.Lines = append(msg.Lines, fmt.Sprintf("… and %d more", len(events)-i))
+			break
+		}
+		msg.Lines = append(msg.Lines, line)
+	}
+	return msg
+}
+
+// flushFinalDigest sends the pending digest on shutdown. Events it cannot
+// deliver are kept for the retry journal, limited to the Pushover sink.
+func (d *daemon) flushFinalDigest(ctx context.Context) {
+	if d.digest == nil {
+		return
+	}
+	events, err := d.flushDigest(ctx)
+	if err == nil {
+		return
+	}
+	log.Error("Pushover digest failed on shutdown", "count", len(events), "error", err)
+	for i := range events {
+		events[i].Sinks = []string{sinkPushover}
+	}
+	d.mu.Lock()
+	d.interrupted = append(d.interrupted, events...)
+	d.mu.Unlock()
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// failureEntry is one failed delivery to one sink, appended to the failure
+// journal so that it can be replayed with `cross-seed-search replay`.
+type failureEntry struct {
+	retryEntry
+	Sink     string    `json:"sink"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+func newFailureEntry(f eventFailure) failureEntry {
+	entry := failureEntry{
+		retryEntry: newRetryEntry(f.Event),
+		Sink:       f.Sink,
+		Error:      f.Error,
+		FailedAt:   f.Time.UTC(),
+	}
+	entry.Sinks = nil
+	return entry
+}
+
+func (e failureEntry) release() *ReleaseInfo {
+	release := e.event().Release
+	release.Event = e.Event
+	return release
+}
+
+// failureJournalPath returns the configured failure journal, or "" when it
+// is disabled.
+func failureJournalPath() string {
+	if !getEnvBool("FAILURE_JOURNAL_ENABLED", true) {
+		return ""
+	}
+	return getEnv("FAILURE_JOURNAL_PATH", "/config/notifier/failures.jsonl")
+}
+
+// appendFailures writes failures with a single O_APPEND write, like the event
+// journal, so concurrent writers do not interleave lines.
+func appendFailures(path string, failures []eventFailure) error {
+	if path == "" || len(failures) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, f := range failures {
+		if err := enc.Encode(newFailureEntry(f)); err != nil {
+			return fmt.Errorf("failed to encode failure: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create failure journal directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open failure journal: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write failure journal: %w", err)
+	}
+	return f.Close()
+}
+
+// readFailureJournal returns the journal's entries and its raw lines. Lines
+// that cannot be decoded are skipped.
+func readFailureJournal(path string) ([]failureEntry, [][]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read failure journal: %w", err)
+	}
+
+	var (
+		entries []failureEntry
+		lines   [][]byte
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := bytes.Clone(scanner.Bytes())
+		lines = append(lines, line)
+		var entry failureEntry
+		if err := json.Unmarshal(line, &entry); err != nil || entry.InfoHash == "" {
+			log.Warn("Skipping malformed failure journal line", "path", path, "line", len(lines))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read failure journal: %w", err)
+	}
+	return entries, lines, nil
+}
+
+// rewriteFailureJournal replaces the first n lines of the journal with kept,
+// preserving lines appended by the daemon after the journal was read.
+func rewriteFailureJournal(path string, n int, kept []failureEntry) error {
+	_, lines, err := readFailureJournal(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".failures-*")
+	if err != nil {
+		return fmt.Errorf("failed to rewrite failure journal: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, entry := range kept {
+		if err := enc.Encode(entry); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to rewrite failure journal: %w", err)
+		}
+	}
+	for _, line := range lines[min(n, len(lines)):] {
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to rewrite failure journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to rewrite failure journal: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to rewrite failure journal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+type messageFormat string
+
+const (
+	formatHTML messageFormat = "html"
+	formatText messageFormat = "text"
+)
+
+func parseMessageFormat(env, value string) (messageFormat, error) {
+	switch f := messageFormat(strings.ToLower(value)); f {
+	case formatHTML, formatText:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q: expected html or text", env, value)
+	}
+}
+
+// notification is the content of a message independent of how a sink
+// renders it: a heading, labelled fields and free lines.
+type notification struct {
+	Title   string
+	Heading string
+	Fields  [][2]string
+	Lines   []string
+}
+
+func (n notification) render(format messageFormat) string {
+	var b strings.Builder
+	if format == formatHTML {
+		fmt.Fprintf(&b, "<b>%s</b>", html.EscapeString(n.Heading))
+		for _, f := range n.Fields {
+			fmt.Fprintf(&b, "<small>\n<b>%s:</b> %s</small>", html.EscapeString(f[0]), html.EscapeString(f[1]))
+		}
+		for _, line := range n.Lines {
+			fmt.Fprintf(&b, "<small>\n%s</small>", html.EscapeString(line))
+		}
+		return b.String()
+	}
+
+	b.WriteString(n.Heading)
+	for _, f := range n.Fields {
+		fmt.Fprintf(&b, "\n%s: %s", f[0], f[1])
+	}
+	for _, line := range n.Lines {
+		b.WriteString("\n" + line)
+	}
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// hedge runs op and, when it has not finished after delay, starts a second
+// attempt in parallel. The first success wins and cancels the other attempt.
+// A failure before the deadline is returned as is, leaving retries to the
+// caller. A delay of zero disables hedging.
+func hedge(ctx context.Context, delay time.Duration, op func(context.Context) error) error {
+	if delay <= 0 {
+		return op(ctx)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan error, 2)
+	run := func() { results <- op(ctx) }
+	go run()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var firstErr error
+	for launched, done := 1, 0; done < launched; {
+		select {
+		case <-timer.C:
+			log.DebugContext(ctx, "Request is slow, starting a hedged attempt", "delay", delay)
+			launched++
+			go run()
+		case err := <-results:
+			done++
+			if err == nil || launched == 1 {
+				return err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
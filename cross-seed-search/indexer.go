@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// torznabPath matches Prowlarr's per-indexer Torznab and Newznab paths,
+// "/12/api" and "/api/v1/indexer/12/newznab".
+var torznabPath = regexp.MustCompile(`^/(?:api/v1/indexer/)?(\d+)/(?:api|newznab|torznab)\b`)
+
+// indexerResolver turns the indexer URL of a release into the indexer's
+// name for notifications. INDEXER_NAMES maps hosts to names, for example
+// "tracker.example.org=Example"; PROWLARR_URL and PROWLARR_API_KEY look up
+// the rest in Prowlarr, cached for PROWLARR_CACHE_TTL.
+type indexerResolver struct {
+	static      map[string]string
+	prowlarrURL string
+	apiKey      string
+	ttl         time.Duration
+
+	mu      sync.Mutex
+	byHost  map[string]string
+	byID    map[int]string
+	fetched time.Time
+}
+
+// loadIndexerResolver returns nil when neither source is configured.
+func loadIndexerResolver() (*indexerResolver, error) {
+	r := &indexerResolver{
+		static:      make(map[string]string),
+		prowlarrURL: strings.TrimRight(os.Getenv("PROWLARR_URL"), "/"),
+		apiKey:      os.Getenv("PROWLARR_API_KEY"),
+		ttl:         getEnvDuration("PROWLARR_CACHE_TTL", time.Hour),
+	}
+	for _, entry := range strings.Split(os.Getenv("INDEXER_NAMES"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		host, name, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(host) == "" || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid entry %q in INDEXER_NAMES: expected host=name", entry)
+		}
+		r.static[normalizeIndexerHost(host)] = strings.TrimSpace(name)
+	}
+	if r.prowlarrURL != "" && r.apiKey == "" {
+		return nil, errors.New("PROWLARR_URL requires PROWLARR_API_KEY")
+	}
+	if len(r.static) == 0 && r.prowlarrURL == "" {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// resolve returns the indexer's name, or "" when it is unknown.
+func (r *indexerResolver) resolve(ctx context.Context, indexerURL string) string {
+	if r == nil {
+		return ""
+	}
+	u, err := url.Parse(indexerURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	host := normalizeIndexerHost(u.Hostname())
+	if name := lookupIndexerHost(r.static, host); name != "" {
+		return name
+	}
+	if r.prowlarrURL == "" {
+		return ""
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.fetched) > r.ttl {
+		if err := r.refresh(ctx); err != nil {
+			log.WarnContext(ctx, "Failed to load indexers from Prowlarr", "error", err)
+		}
+	}
+	if prowlarr, err := url.Parse(r.prowlarrURL); err == nil && strings.EqualFold(prowlarr.Host, u.Host) {
+		if m := torznabPath.FindStringSubmatch(strings.TrimPrefix(u.Path, strings.TrimRight(prowlarr.Path, "/"))); m != nil {
+			id, _ := strconv.Atoi(m[1])
+			return r.byID[id]
+		}
+	}
+	return lookupIndexerHost(r.byHost, host)
+}
+
+// refresh reloads the indexer list. A failed refresh keeps the previous list
+// and is retried after a minute rather than on every release.
+func (r *indexerResolver) refresh(ctx context.Context) error {
+	r.fetched = time.Now().Add(min(time.Minute, r.ttl) - r.ttl)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.prowlarrURL+"/api/v1/indexer", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Api-Key", r.apiKey)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &httpStatusError{code: resp.StatusCode, expected: http.StatusOK}
+	}
+
+	var indexers []struct {
+		ID          int      `json:"id"`
+		Name        string   `json:"name"`
+		IndexerURLs []string `json:"indexerUrls"`
+		Fields      []struct {
+			Name  string `json:"name"`
+			Value any    `json:"value"`
+		} `json:"fields"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 8<<20)).Decode(&indexers); err != nil {
+		return fmt.Errorf("failed to decode indexers: %w", err)
+	}
+
+	r.byHost = make(map[string]string)
+	r.byID = make(map[int]string)
+	for _, idx := range indexers {
+		r.byID[idx.ID] = idx.Name
+		urls := idx.IndexerURLs
+		for _, f := range idx.Fields {
+			if s, ok := f.Value.(string); ok && f.Name == "baseUrl" {
+				urls = append(urls, s)
+			}
+		}
+		for _, raw := range urls {
+			if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+				r.byHost[normalizeIndexerHost(u.Hostname())] = idx.Name
+			}
+		}
+	}
+	r.fetched = time.Now()
+	log.DebugContext(ctx, "Loaded indexers from Prowlarr", "count", len(indexers))
+	return nil
+}
+
+func normalizeIndexerHost(host string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(host)), "www.")
+}
+
+// lookupIndexerHost also tries the parent domains of host, since trackers
+// usually announce from a subdomain of the site Prowlarr knows.
+func lookupIndexerHost(names map[string]string, host string) string {
+	for host != "" {
+		if name, ok := names[host]; ok {
+			return name
+		}
+		_, host, _ = strings.Cut(host, ".")
+	}
+	return ""
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	injectSavePathMatch = "match"
+	injectSavePathFixed = "fixed"
+)
+
+// injectConfig adds the matches cross-seed found to qBittorrent paused, for
+// review before they start seeding. cross-seed's search webhook does not
+// return its matches, so cross-seed must run with action "save" and its
+// outputDir shared with this container as CROSS_SEED_INJECT_DIR.
+//
+// A match only seeds from the data it was matched against, so it is never
+// added with automatic torrent management. SavePathStrategy "match" uses the
+// save path of the torrent named in cross-seed's file name; "fixed" uses
+// SavePath, for data linked into one directory.
+type injectConfig struct {
+	Dir              string
+	Interval         time.Duration
+	Category         string
+	Tags             string
+	SavePath         string
+	Instance         string
+	SavePathStrategy string
+}
+
+func loadInjectConfig(instances []qbittorrentInstance) (*injectConfig, error) {
+	icfg := &injectConfig{
+		Dir:      os.Getenv("CROSS_SEED_INJECT_DIR"),
+		Interval: getEnvDuration("CROSS_SEED_INJECT_INTERVAL", time.Minute),
+		Category: getEnv("CROSS_SEED_INJECT_CATEGORY", "cross-seed"),
+		Tags:     getEnv("CROSS_SEED_INJECT_TAGS", "cross-seed,review"),
+		SavePath: os.Getenv("CROSS_SEED_INJECT_SAVE_PATH"),
+		Instance: getEnv("CROSS_SEED_INJECT_INSTANCE", instances[0].Name),
+	}
+	if icfg.Dir == "" {
+		return icfg, nil
+	}
+	defaultStrategy := injectSavePathMatch
+	if icfg.SavePath != "" {
+		defaultStrategy = injectSavePathFixed
+	}
+	icfg.SavePathStrategy = getEnv("CROSS_SEED_INJECT_SAVE_PATH_STRATEGY", defaultStrategy)
+	switch icfg.SavePathStrategy {
+	case injectSavePathMatch:
+	case injectSavePathFixed:
+		if icfg.SavePath == "" {
+			return nil, errors.New("CROSS_SEED_INJECT_SAVE_PATH_STRATEGY=fixed requires CROSS_SEED_INJECT_SAVE_PATH")
+		}
+	default:
+		return nil, fmt.Errorf("invalid CROSS_SEED_INJECT_SAVE_PATH_STRATEGY %q: expected match or fixed", icfg.SavePathStrategy)
+	}
+
+	if !slices.ContainsFunc(instances, func(inst qbittorrentInstance) bool { return inst.Name == icfg.Instance }) {
+		return nil, fmt.Errorf("CROSS_SEED_INJECT_INSTANCE %q is not in QBT_INSTANCES", icfg.Instance)
+	}
+	if info, err := os.Stat(icfg.Dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("CROSS_SEED_INJECT_DIR %q is not a directory", icfg.Dir)
+	}
+	return icfg, nil
+}
+
+// runCrossSeedInject checks the directory every Interval until ctx is done.
+func runCrossSeedInject(ctx context.Context, icfg *injectConfig, client *qbittorrentClient) {
+	for {
+		if err := injectOnce(ctx, icfg, client); err != nil && ctx.Err() == nil {
+			log.WarnContext(ctx, "Adding cross-seed matches failed", "instance", client.name, "error", err)
+		}
+
+		select {
+		case <-time.After(icfg.Interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// injectOnce adds every .torrent file in the directory. Added files are
+// renamed to .added, files qBittorrent refuses, usually because the torrent
+// is already there, to .rejected, and files whose matched torrent cannot be
+// found to .unmatched, so each is only tried once; other failures are
+// retried on the next run.
+func injectOnce(ctx context.Context, icfg *injectConfig, client *qbittorrentClient) error {
+	entries, err := os.ReadDir(icfg.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", icfg.Dir, err)
+	}
+
+	var pending []os.DirEntry
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".torrent") {
+			continue
+		}
+		// Leave files cross-seed may still be writing for the next run.
+		if info, err := entry.Info(); err != nil || time.Since(info.ModTime()) < 5*time.Second {
+			continue
+		}
+		pending = append(pending, entry)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var savePaths map[string][]string
+	if icfg.SavePathStrategy == injectSavePathMatch {
+		if savePaths, err = matchedSavePaths(ctx, client, icfg.Category); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for _, entry := range pending {
+		path := filepath.Join(icfg.Dir, entry.Name())
+		savePath := icfg.SavePath
+		if icfg.SavePathStrategy == injectSavePathMatch {
+			name := crossSeedMatchName(entry.Name())
+			paths := savePaths[injectNameKey(name)]
+			if len(paths) != 1 {
+				log.WarnContext(ctx, "No single completed torrent found for cross-seed match, not adding it",
+					"instance", client.name, "file", entry.Name(), "name", name, "save_paths", paths)
+				if err := os.Rename(path, path+".unmatched"); err != nil {
+					errs = append(errs, fmt.Errorf("failed to mark %s as unmatched: %w", entry.Name(), err))
+				}
+				continue
+			}
+			savePath = paths[0]
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		options := url.Values{
+			"category": {icfg.Category},
+			"tags":     {icfg.Tags},
+			"savepath": {savePath},
+			"autoTMM":  {"false"},
+			// qBittorrent 5 renamed paused to stopped.
+			"paused":  {"true"},
+			"stopped": {"true"},
+		}
+
+		suffix := ".added"
+		err = client.addTorrent(ctx, entry.Name(), data, options)
+		switch {
+		case errors.Is(err, errTorrentRejected):
+			log.WarnContext(ctx, "qBittorrent rejected cross-seed match, it may already be added",
+				"instance", client.name, "file", entry.Name())
+			suffix = ".rejected"
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+			continue
+		default:
+			log.InfoContext(ctx, "Added cross-seed match paused for review",
+				"instance", client.name,
+				"file", entry.Name(),
+				"save_path", savePath,
+				"category", icfg.Category,
+				"tags", icfg.Tags)
+		}
+		if err := os.Rename(path, path+suffix); err != nil {
+			errs = append(errs, fmt.Errorf("failed to mark %s as processed: %w", entry.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// matchedSavePaths maps the names of completed torrents to their distinct
+// save paths. Earlier matches, in the injection category, are left out.
+func matchedSavePaths(ctx context.Context, client *qbittorrentClient, category string) (map[string][]string, error) {
+	var torrents []struct {
+		Name     string  `json:"name"`
+		SavePath string  `json:"save_path"`
+		Category string  `json:"category"`
+		Progress float64 `json:"progress"`
+	}
+	if err := client.getJSON(ctx, "/api/v2/torrents/info", nil, &torrents); err != nil {
+		return nil, fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	byName := make(map[string]map[string]bool)
+	add := func(name, savePath string) {
+		key := injectNameKey(name)
+		if byName[key] == nil {
+			byName[key] = make(map[string]bool)
+		}
+		byName[key][savePath] = true
+	}
+	for _, t := range torrents {
+		if t.Progress < 1 || t.Category == category || t.SavePath == "" {
+			continue
+		}
+		add(t.Name, t.SavePath)
+		// cross-seed drops the extension of single file torrents.
+		if ext := filepath.Ext(t.Name); ext != "" {
+			add(strings.TrimSuffix(t.Name, ext), t.SavePath)
+		}
+	}
+
+	savePaths := make(map[string][]string, len(byName))
+	for key, paths := range byName {
+		savePaths[key] = slices.Sorted(maps.Keys(paths))
+	}
+	return savePaths, nil
+}
+
+// crossSeedMatchName returns the matched torrent's name from a file saved by
+// cross-seed, which names them "[type][tracker]name.torrent".
+func crossSeedMatchName(filename string) string {
+	name := strings.TrimSuffix(filename, ".torrent")
+	for strings.HasPrefix(name, "[") {
+		end := strings.Index(name, "]")
+		if end < 0 {
+			break
+		}
+		name = name[end+1:]
+	}
+	return name
+}
+
+// injectNameKey compares names the way cross-seed writes them into file
+// names, without characters file systems reject.
+func injectNameKey(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`/\?<>:*|"`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	return strings.ToLower(strings.TrimSpace(name))
+}
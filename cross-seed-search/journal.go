@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type journalEntry struct {
+	Instance string    `json:"instance,omitempty"`
+	InfoHash string    `json:"info_hash"`
+	Event    eventKind `json:"event"`
+	Time     time.Time `json:"time"`
+}
+
+// eventJournal is an append-only JSON lines file of processed infohash and
+// event pairs, used to skip events that were already delivered before a
+// restart. Entries older than the retention are dropped when it is opened.
+type eventJournal struct {
+	path string
+
+	mu   sync.Mutex
+	seen map[journalKey]time.Time
+	file *os.File
+}
+
+type journalKey struct {
+	instance string
+	hash     string
+	kind     eventKind
+}
+
+type journalConfig struct {
+	Enabled   bool
+	Path      string
+	Retention time.Duration
+}
+
+func loadJournalConfig() *journalConfig {
+	return &journalConfig{
+		Enabled:   getEnvBool("EVENT_JOURNAL_ENABLED", true),
+		Path:      getEnv("EVENT_JOURNAL_PATH", "/config/notifier/processed.jsonl"),
+		Retention: getEnvDuration("EVENT_JOURNAL_RETENTION", 90*24*time.Hour),
+	}
+}
+
+// openConfiguredJournal opens the journal if enabled. Failing to open it is
+// logged rather than fatal: duplicates are preferable to lost notifications.
+func openConfiguredJournal() *eventJournal {
+	cfg := loadJournalConfig()
+	if !cfg.Enabled {
+		return nil
+	}
+	j, err := openEventJournal(cfg.Path, cfg.Retention)
+	if err != nil {
+		log.Warn("Event journal unavailable, duplicates will not be suppressed", "path", cfg.Path, "error", err)
+		return nil
+	}
+	return j
+}
+
+func openEventJournal(path string, retention time.Duration) (*eventJournal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	j := &eventJournal{path: path, seen: make(map[journalKey]time.Time)}
+	cutoff := time.Now().Add(-retention)
+	lines, expired := 0, false
+
+	f, err := os.Open(path)
+	switch {
+	case err == nil:
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines++
+			var entry journalEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.InfoHash == "" {
+				expired = true
+				continue
+			}
+			if entry.Time.Before(cutoff) {
+				expired = true
+				continue
+			}
+			j.seen[journalKey{entry.Instance, entry.InfoHash, entry.Event}] = entry.Time
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read journal: %w", err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	// Rewrite without expired, malformed or duplicate lines.
+	if expired || lines > len(j.seen) {
+		if err := j.compact(); err != nil {
+			return nil, err
+		}
+	}
+
+	j.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	log.Debug("Loaded event journal", "path", path, "entries", len(j.seen))
+	return j, nil
+}
+
+func (j *eventJournal) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), ".journal-*")
+	if err != nil {
+		return fmt.Errorf("failed to compact journal: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for key, t := range j.seen {
+		if err := enc.Encode(journalEntry{Instance: key.instance, InfoHash: key.hash, Event: key.kind, Time: t}); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to compact journal: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to compact journal: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return fmt.Errorf("failed to compact journal: %w", err)
+	}
+	return nil
+}
+
+func (j *eventJournal) has(instance, hash string, kind eventKind) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, ok := j.seen[journalKey{instance, hash, kind}]
+	return ok
+}
+
+// recordedAt returns when an event was recorded as processed.
+func (j *eventJournal) recordedAt(instance, hash string, kind eventKind) (time.Time, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	t, ok := j.seen[journalKey{instance, hash, kind}]
+	return t, ok
+}
+
+// record appends an entry. Lines are written with a single O_APPEND write, so
+// concurrent one-shot invocations do not interleave.
+func (j *eventJournal) record(instance, hash string, kind eventKind) error {
+	entry := journalEntry{Instance: instance, InfoHash: hash, Event: kind, Time: time.Now().UTC()}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	j.seen[journalKey{instance, hash, kind}] = entry.Time
+	return nil
+}
+
+func (j *eventJournal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
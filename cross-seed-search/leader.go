@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// leaderLock is held by at most one replica at a time. tryAcquire takes the
+// lock or renews it when this replica already holds it.
+type leaderLock interface {
+	tryAcquire(ctx context.Context) (bool, error)
+	release(ctx context.Context) error
+}
+
+// leadership is held while this replica processes events. Its context is
+// cancelled when the lock is lost, which shuts the daemon down so a restart
+// puts it back on standby.
+type leadership struct {
+	lock   leaderLock
+	ctx    context.Context
+	cancel context.CancelFunc
+	lost   atomic.Bool
+}
+
+// electLeader blocks until this replica holds the lock configured by
+// LEADER_ELECTION: "file" for a lock file on a shared volume or "kubernetes"
+// for a Lease object. It returns nil when leader election is disabled.
+func electLeader(ctx context.Context) (*leadership, error) {
+	identity := os.Getenv("LEADER_ELECTION_IDENTITY")
+	if identity == "" {
+		identity, _ = os.Hostname()
+	}
+	duration := getEnvDuration("LEADER_ELECTION_LEASE_DURATION", 15*time.Second)
+	if duration < 3*time.Second {
+		return nil, errors.New("LEADER_ELECTION_LEASE_DURATION must be at least 3s")
+	}
+	interval := duration / 3
+
+	var lock leaderLock
+	switch kind := os.Getenv("LEADER_ELECTION"); kind {
+	case "":
+		return nil, nil
+	case "file":
+		lock = &fileLock{
+			path:     getEnv("LEADER_ELECTION_LOCK_PATH", "/config/notifier/leader.lock"),
+			identity: identity,
+		}
+	case "kubernetes":
+		l, err := newLeaseLock(identity, duration)
+		if err != nil {
+			return nil, err
+		}
+		lock = l
+	default:
+		return nil, fmt.Errorf("invalid LEADER_ELECTION %q: expected file or kubernetes", kind)
+	}
+
+	log.Info("Waiting to become leader", "identity", identity)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ok, err := lock.tryAcquire(ctx)
+		if err != nil {
+			log.Warn("Failed to acquire leadership", "error", err)
+		}
+		if ok {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	log.Info("Became leader", "identity", identity)
+
+	l := &leadership{lock: lock}
+	l.ctx, l.cancel = context.WithCancel(ctx)
+	go l.renew(interval, duration)
+	return l, nil
+}
+
+// renew keeps the lock. Failed renewals are retried until the lease is about
+// to expire, since another replica may take over after that.
+func (l *leadership) renew(interval, duration time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+		case <-l.ctx.Done():
+			return
+		}
+
+		ok, err := l.lock.tryAcquire(l.ctx)
+		switch {
+		case ok:
+			renewed = time.Now()
+			continue
+		case err != nil && time.Since(renewed) < duration-interval:
+			log.Warn("Failed to renew leadership", "error", err)
+			continue
+		}
+		log.Error("Lost leadership, stopping", "error", err)
+		l.lost.Store(true)
+		l.cancel()
+		return
+	}
+}
+
+// release gives up the lock so a standby replica takes over right away
+// instead of waiting for the lease to expire.
+func (l *leadership) release() {
+	l.cancel()
+	if l.lost.Load() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := l.lock.release(ctx); err != nil {
+		log.Warn("Failed to release leadership", "error", err)
+	}
+}
+
+func (l *leadership) err() error {
+	if l != nil && l.lost.Load() {
+		return errors.New("lost leadership")
+	}
+	return nil
+}
+
+// fileLock is an flock on a file shared by the replicas. The kernel drops the
+// lock when the process dies, so it never has to expire.
+type fileLock struct {
+	path     string
+	identity string
+	file     *os.File
+}
+
+func (f *fileLock) tryAcquire(ctx context.Context) (bool, error) {
+	if f.file != nil {
+		return true, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o750); err != nil {
+		return false, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_RDWR, 0o640)
+	if err != nil {
+		return false, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to lock %s: %w", f.path, err)
+	}
+	// The holder is recorded for whoever looks at the file; the lock
+	// itself doesn't depend on it.
+	file.Truncate(0)
+	file.WriteAt([]byte(f.identity+"\n"), 0)
+	f.file = file
+	return true, nil
+}
+
+func (f *fileLock) release(ctx context.Context) error {
+	if f.file == nil {
+		return nil
+	}
+	f.file.Truncate(0)
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
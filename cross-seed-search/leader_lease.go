@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// leaseTimeFormat is the MicroTime format of Lease timestamps.
+	leaseTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+var errLeaseConflict = errors.New("lease was modified by another replica")
+
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+// leaseLock is a coordination.k8s.io Lease, the object client-go's leader
+// election uses, talked to through the API server with the pod's service
+// account. The service account needs get, create and update on leases.
+type leaseLock struct {
+	identity  string
+	duration  time.Duration
+	name      string
+	namespace string
+	// leases is the collection URL; the Lease itself is below it.
+	leases string
+	client *http.Client
+
+	// The holder's renewals are timed on this replica's clock, so clock
+	// skew between nodes cannot make a live lease look expired.
+	observed   leaseSpec
+	observedAt time.Time
+}
+
+// newLeaseLock reads the in-cluster API server address and service account.
+// LEADER_ELECTION_LEASE_NAME names the Lease and LEADER_ELECTION_NAMESPACE
+// overrides the pod's namespace.
+func newLeaseLock(identity string, duration time.Duration) (*leaseLock, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("LEADER_ELECTION=kubernetes requires running in a Kubernetes pod")
+	}
+	namespace := os.Getenv("LEADER_ELECTION_NAMESPACE")
+	if namespace == "" {
+		data, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pod namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates found in cluster CA")
+	}
+
+	return &leaseLock{
+		identity:  identity,
+		duration:  duration,
+		name:      getEnv("LEADER_ELECTION_LEASE_NAME", "cross-seed-search"),
+		namespace: namespace,
+		leases: fmt.Sprintf("https://%s/apis/coordination.k8s.io/v1/namespaces/%s/leases",
+			net.JoinHostPort(host, port), namespace),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+			},
+		},
+	}, nil
+}
+
+func (l *leaseLock) tryAcquire(ctx context.Context) (bool, error) {
+	now := time.Now()
+	current, err := l.get(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if current == nil {
+		current = &lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   leaseMetadata{Name: l.name, Namespace: l.namespace},
+		}
+	} else if current.Spec != l.observed {
+		l.observed = current.Spec
+		l.observedAt = now
+	}
+
+	spec := current.Spec
+	held := spec.HolderIdentity == l.identity
+	expired := spec.HolderIdentity == "" ||
+		now.After(l.observedAt.Add(time.Duration(spec.LeaseDurationSeconds)*time.Second))
+	if !held && !expired {
+		return false, nil
+	}
+
+	if !held {
+		spec.HolderIdentity = l.identity
+		spec.AcquireTime = now.UTC().Format(leaseTimeFormat)
+		if current.Metadata.ResourceVersion != "" {
+			spec.LeaseTransitions++
+		}
+	}
+	spec.LeaseDurationSeconds = int(l.duration / time.Second)
+	spec.RenewTime = now.UTC().Format(leaseTimeFormat)
+	current.Spec = spec
+
+	if err := l.put(ctx, current); err != nil {
+		if errors.Is(err, errLeaseConflict) {
+			return false, nil
+		}
+		return false, err
+	}
+	l.observed = spec
+	l.observedAt = now
+	return true, nil
+}
+
+// release clears the holder, which standby replicas treat as expired.
+func (l *leaseLock) release(ctx context.Context) error {
+	current, err := l.get(ctx)
+	if err != nil || current == nil || current.Spec.HolderIdentity != l.identity {
+		return err
+	}
+	current.Spec.HolderIdentity = ""
+	current.Spec.LeaseDurationSeconds = 1
+	current.Spec.RenewTime = time.Now().UTC().Format(leaseTimeFormat)
+	return l.put(ctx, current)
+}
+
+// get returns nil when the Lease does not exist yet.
+func (l *leaseLock) get(ctx context.Context) (*lease, error) {
+	resp, err := l.do(ctx, http.MethodGet, l.leases+"/"+l.name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, leaseStatusError(resp)
+	}
+	var current lease
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBody)).Decode(&current); err != nil {
+		return nil, fmt.Errorf("failed to decode lease: %w", err)
+	}
+	return &current, nil
+}
+
+// put creates the Lease when it has no resource version yet and updates it
+// otherwise. The resource version makes concurrent updates fail with a
+// conflict instead of overwriting each other.
+func (l *leaseLock) put(ctx context.Context, current *lease) error {
+	body, err := json.Marshal(current)
+	if err != nil {
+		return fmt.Errorf("failed to encode lease: %w", err)
+	}
+	method, target := http.MethodPut, l.leases+"/"+l.name
+	if current.Metadata.ResourceVersion == "" {
+		method, target = http.MethodPost, l.leases
+	}
+
+	resp, err := l.do(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	case http.StatusConflict:
+		return errLeaseConflict
+	default:
+		return leaseStatusError(resp)
+	}
+}
+
+func (l *leaseLock) do(ctx context.Context, method, target string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	// The token is read on every request since the kubelet rotates it.
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("lease request failed: %w", err)
+	}
+	return resp, nil
+}
+
+func leaseStatusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %d from the Kubernetes API: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
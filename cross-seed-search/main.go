@@ -3,12 +3,10 @@ package main
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"html"
 	"io"
 	"log/slog"
 	"net"
@@ -18,9 +16,11 @@ import (
 	"os/signal"
 	"path"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/dustin/go-humanize"
@@ -39,16 +39,34 @@ var (
 	date       = ""
 	log        *slog.Logger
 	validate   = validator.New()
-	httpClient = createHTTPClient()
+	httpClient *http.Client
+	// maxResponseBody caps how much of a response is read, so a misbehaving
+	// endpoint cannot exhaust memory. main sets it from
+	// HTTP_MAX_RESPONSE_BYTES once secrets and CONFIG_YAML are loaded.
+	maxResponseBody int64 = 1 << 20
 )
 
 type Config struct {
 	CrossSeedEnabled bool
 	CrossSeedURL     string
 	CrossSeedAPIKey  string
-	PushoverEnabled  bool
-	PushoverUserKey  string
-	PushoverToken    string
+	// CrossSeedAPIKeySecondary is tried when the primary key is rejected,
+	// so the cross-seed key can be rotated without downtime.
+	CrossSeedAPIKeySecondary string
+	PushoverEnabled          bool
+	PushoverUserKey          string
+	PushoverToken            string
+	// PushoverPriorities maps event kinds to Pushover priorities.
+	PushoverPriorities map[eventKind]int
+	PushoverFormat     messageFormat
+	PushoverTitles     map[string]*template.Template
+	// CrossSeedHedgeDelay starts a second, parallel webhook call when the
+	// first takes longer than this. Zero disables hedging.
+	CrossSeedHedgeDelay time.Duration
+	// CrossSeedShards are the instances in CROSS_SEED_URL. Searches are
+	// split between them by info hash.
+	CrossSeedShards []crossSeedShard
+	Indexers        *indexerResolver
 }
 
 type ReleaseInfo struct {
@@ -58,6 +76,11 @@ type ReleaseInfo struct {
 	Size     int64  `validate:"gt=0"`
 	Indexer  string `validate:"required,url"`
 	Type     string `validate:"required"`
+	Event    eventKind
+	Instance string
+	// IndexerName is the indexer's name when it could be resolved from
+	// Indexer.
+	IndexerName string
 }
 
 func init() {
@@ -84,6 +107,18 @@ func main() {
 
 	configureLogger()
 
+	if err := loadSecretsDir(); err != nil {
+		log.Error("Failed to load secrets", "error", err)
+		os.Exit(1)
+	}
+	if err := loadConfigYAML(); err != nil {
+		log.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+	// LOG_LEVEL may have come from a secret or CONFIG_YAML.
+	configureLogger()
+	maxResponseBody = int64(max(getEnvInt("HTTP_MAX_RESPONSE_BYTES", 1<<20), 1))
+
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
@@ -92,12 +127,63 @@ func main() {
 		"commit", commit,
 		"date", date)
 
-	cfg := loadConfig()
+	client, err := createHTTPClient(len(os.Args) > 1 && os.Args[1] == "daemon")
+	if err != nil {
+		log.Error("Invalid TLS configuration", "error", err)
+		os.Exit(1)
+	}
+	httpClient = client
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
 	log.Debug("Loaded configuration",
 		"cross_seed_enabled", cfg.CrossSeedEnabled,
 		"pushover_enabled", cfg.PushoverEnabled,
 	)
 
+	if len(os.Args) > 1 && os.Args[1] == "daemon" {
+		if err := runDaemon(ctx, cfg); err != nil {
+			log.Error("Daemon failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		if err := runSelfTest(ctx, cfg); err != nil {
+			log.Error("Self-test failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "notify" {
+		if err := runNotify(ctx, cfg, os.Args[2:]); err != nil {
+			log.Error("Notification failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		if err := runRender(cfg, os.Args[2:]); err != nil {
+			log.Error("Render failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(ctx, cfg, os.Args[2:]); err != nil {
+			log.Error("Replay failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if len(os.Args) != 6 {
 		log.Error("Invalid arguments",
 			"usage", fmt.Sprintf("%s <release_name> <info_hash> <category> <size> <indexer>", os.Args[0]))
@@ -112,59 +198,99 @@ func main() {
 
 	limiter := rate.NewLimiter(rate.Every(5*time.Second), 2)
 
-	if cfg.PushoverEnabled {
-		if cfg.PushoverUserKey == "" || cfg.PushoverToken == "" {
-			log.Error("Pushover enabled but missing credentials")
-			os.Exit(1)
+	if err := validateConfig(cfg); err != nil {
+		log.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	journal := openConfiguredJournal()
+	if journal != nil {
+		defer journal.close()
+		if journal.has("", release.InfoHash, eventCompleted) {
+			log.Info("Torrent already processed, skipping", "hash", release.InfoHash)
+			return
 		}
+	}
 
+	ev := torrentEvent{Kind: eventCompleted, Release: release, Time: time.Now()}
+	var failures []eventFailure
+	fail := func(sink string, err error) {
+		failures = append(failures, eventFailure{Event: ev, Sink: sink, Error: err.Error(), Time: time.Now()})
+	}
+
+	if cfg.PushoverEnabled {
 		if err := limiter.Wait(ctx); err != nil {
 			log.WarnContext(ctx, "Rate limit exceeded for Pushover", "error", err)
+			fail(sinkPushover, err)
 		} else {
 			if err := sendPushoverNotification(ctx, cfg, release); err != nil {
 				log.ErrorContext(ctx, "Pushover notification failed", "error", err)
+				fail(sinkPushover, err)
 			}
 		}
 	}
 
 	if cfg.CrossSeedEnabled {
-		if cfg.CrossSeedURL == "" || cfg.CrossSeedAPIKey == "" {
-			log.Error("CrossSeed enabled but missing configuration")
-			os.Exit(1)
-		}
-
 		if err := limiter.Wait(ctx); err != nil {
 			log.WarnContext(ctx, "Rate limit exceeded for CrossSeed", "error", err)
+			fail(sinkCrossSeed, err)
 		} else {
 			if err := searchCrossSeed(ctx, cfg, release); err != nil {
 				log.ErrorContext(ctx, "CrossSeed search failed", "error", err)
+				fail(sinkCrossSeed, err)
 			}
 		}
 	}
 
+	if err := appendFailures(failureJournalPath(), failures); err != nil {
+		log.Warn("Failed to record failed deliveries", "error", err)
+	}
+
+	if journal != nil && len(failures) == 0 {
+		if err := journal.record("", release.InfoHash, eventCompleted); err != nil {
+			log.Warn("Failed to record processed event", "error", err)
+		}
+	}
+
 	log.Info("Processing completed successfully")
 }
 
-func createHTTPClient() *http.Client {
+// createHTTPClient builds the client for outgoing notifications. A single
+// run sends a couple of requests and exits, but the daemon keeps connections
+// open and negotiates HTTP/2 where supported, so bursts of events reuse them
+// instead of paying for a TLS handshake each.
+func createHTTPClient(daemon bool) (*http.Client, error) {
+	tlsCfg, err := tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	// The transport asks for gzip and decompresses transparently as long as
+	// requests don't set Accept-Encoding themselves; the response body cap
+	// applies to the decompressed size.
+	transport := &http.Transport{
+		TLSClientConfig: tlsCfg,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 0,
+		}).DialContext,
+	}
+	if daemon {
+		// A custom TLS config turns off HTTP/2 unless asked for.
+		transport.ForceAttemptHTTP2 = true
+		transport.MaxIdleConns = 32
+		transport.MaxIdleConnsPerHost = 8
+		transport.IdleConnTimeout = 90 * time.Second
+		transport.TLSHandshakeTimeout = 10 * time.Second
+	} else {
+		transport.DisableKeepAlives = true
+	}
 	return &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				MinVersion: tls.VersionTLS12,
-				CipherSuites: []uint16{
-					tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-					tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-				},
-			},
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 0,
-			}).DialContext,
-		},
+		Timeout:   30 * time.Second,
+		Transport: transport,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
-	}
+	}, nil
 }
 
 func configureLogger() {
@@ -205,15 +331,62 @@ func getLogLevel() slog.Level {
 	}
 }
 
-func loadConfig() *Config {
+func loadConfig() (*Config, error) {
+	priorities, err := parsePushoverPriorities(os.Getenv("PUSHOVER_PRIORITIES"))
+	if err != nil {
+		return nil, err
+	}
+	format, err := parseMessageFormat("PUSHOVER_FORMAT", getEnv("PUSHOVER_FORMAT", string(formatHTML)))
+	if err != nil {
+		return nil, err
+	}
+	titles, err := loadPushoverTitles()
+	if err != nil {
+		return nil, err
+	}
+	shards, err := parseCrossSeedShards(os.Getenv("CROSS_SEED_URL"), os.Getenv("CROSS_SEED_API_KEY"))
+	if err != nil {
+		return nil, err
+	}
+	indexers, err := loadIndexerResolver()
+	if err != nil {
+		return nil, err
+	}
 	return &Config{
-		CrossSeedEnabled: getEnvBool("CROSS_SEED_ENABLED", false),
-		CrossSeedURL:     os.Getenv("CROSS_SEED_URL"),
-		CrossSeedAPIKey:  os.Getenv("CROSS_SEED_API_KEY"),
-		PushoverEnabled:  getEnvBool("PUSHOVER_ENABLED", false),
-		PushoverUserKey:  os.Getenv("PUSHOVER_USER_KEY"),
-		PushoverToken:    os.Getenv("PUSHOVER_TOKEN"),
+		CrossSeedEnabled:         getEnvBool("CROSS_SEED_ENABLED", false),
+		CrossSeedURL:             os.Getenv("CROSS_SEED_URL"),
+		CrossSeedAPIKey:          os.Getenv("CROSS_SEED_API_KEY"),
+		CrossSeedAPIKeySecondary: os.Getenv("CROSS_SEED_API_KEY_SECONDARY"),
+		CrossSeedHedgeDelay:      getEnvDuration("CROSS_SEED_HEDGE_DELAY", 0),
+		PushoverEnabled:          getEnvBool("PUSHOVER_ENABLED", false),
+		PushoverUserKey:          os.Getenv("PUSHOVER_USER_KEY"),
+		PushoverToken:            os.Getenv("PUSHOVER_TOKEN"),
+		PushoverPriorities:       priorities,
+		PushoverFormat:           format,
+		PushoverTitles:           titles,
+		CrossSeedShards:          shards,
+		Indexers:                 indexers,
+	}, nil
+}
+
+func validateConfig(cfg *Config) error {
+	if cfg.PushoverEnabled && (cfg.PushoverUserKey == "" || cfg.PushoverToken == "") {
+		return errors.New("Pushover enabled but missing credentials")
+	}
+	if cfg.CrossSeedEnabled && (cfg.CrossSeedURL == "" || cfg.CrossSeedAPIKey == "") {
+		return errors.New("CrossSeed enabled but missing configuration")
+	}
+	if cfg.CrossSeedEnabled && slices.ContainsFunc(cfg.CrossSeedShards, func(s crossSeedShard) bool { return s.APIKey == "" }) {
+		return errors.New("CrossSeed enabled but an API key in CROSS_SEED_API_KEY is empty")
+	}
+	return nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
 	}
+	return defaultValue
 }
 
 func getEnvBool(key string, defaultValue bool) bool {
@@ -236,6 +409,18 @@ func getEnvInt(key string, defaultValue int) int {
 	return result
 }
 
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue
+	}
+	result, err := time.ParseDuration(val)
+	if err != nil || result <= 0 {
+		return defaultValue
+	}
+	return result
+}
+
 func parseAndValidateReleaseInfo(args []string) (*ReleaseInfo, error) {
 	if len(args) != 5 {
 		return nil, errors.New("invalid number of arguments (need 5)")
@@ -263,22 +448,44 @@ func parseAndValidateReleaseInfo(args []string) (*ReleaseInfo, error) {
 }
 
 func sendPushoverNotification(ctx context.Context, cfg *Config, release *ReleaseInfo) error {
+	release.IndexerName = cfg.Indexers.resolve(ctx, release.Indexer)
+	return sendPushoverMessage(ctx, cfg, releaseNotification(cfg, release), cfg.pushoverPriority(release.Event))
+}
 
-	message := fmt.Sprintf(
-		"<b>%s</b><small>\n<b>Category:</b> %s</small><small>\n<b>Indexer:</b> %s</small><small>\n<b>Size:</b> %s</small>",
-		html.EscapeString(strings.TrimSuffix(release.Name, ".torrent")),
-		html.EscapeString(release.Category),
-		html.EscapeString(release.Indexer),
-		humanize.Bytes(uint64(release.Size)),
-	)
+// indexerLabel is how the indexer is shown in notifications.
+func (r *ReleaseInfo) indexerLabel() string {
+	if r.IndexerName != "" {
+		return r.IndexerName
+	}
+	return r.Indexer
+}
+
+func releaseNotification(cfg *Config, release *ReleaseInfo) notification {
+	msg := notification{
+		Title:   cfg.pushoverTitle(release),
+		Heading: strings.TrimSuffix(release.Name, ".torrent"),
+		Fields: [][2]string{
+			{"Category", release.Category},
+			{"Indexer", release.indexerLabel()},
+			{"Size", humanize.Bytes(uint64(release.Size))},
+		},
+	}
+	if release.Instance != "" {
+		msg.Fields = append(msg.Fields, [2]string{"Instance", release.Instance})
+	}
+	return msg
+}
 
+func sendPushoverMessage(ctx context.Context, cfg *Config, msg notification, priority int) error {
 	payload := map[string]string{
 		"token":    cfg.PushoverToken,
 		"user":     cfg.PushoverUserKey,
-		"title":    fmt.Sprintf("%s Downloaded", release.Type),
-		"message":  message,
-		"priority": "-2",
-		"html":     "1",
+		"title":    msg.Title,
+		"message":  msg.render(cfg.PushoverFormat),
+		"priority": strconv.Itoa(priority),
+	}
+	if cfg.PushoverFormat == formatHTML {
+		payload["html"] = "1"
 	}
 
 	return retryOperation(ctx, 3, 2*time.Second, func() error {
@@ -293,8 +500,20 @@ func sendPushoverNotification(ctx context.Context, cfg *Config, release *Release
 	})
 }
 
+func pushoverEventTitle(kind eventKind) string {
+	switch kind {
+	case eventAdded:
+		return "Added"
+	case eventErrored:
+		return "Errored"
+	default:
+		return "Downloaded"
+	}
+}
+
 func searchCrossSeed(ctx context.Context, cfg *Config, release *ReleaseInfo) error {
-	targetURL, err := buildSafeURL(cfg.CrossSeedURL, "/api/webhook")
+	shard := cfg.crossSeedShard(release.InfoHash)
+	targetURL, err := buildSafeURL(shard.URL, "/api/webhook")
 	if err != nil {
 		return fmt.Errorf("failed to build safe URL: %w", err)
 	}
@@ -303,19 +522,36 @@ func searchCrossSeed(ctx context.Context, cfg *Config, release *ReleaseInfo) err
 	data.Set("infoHash", release.InfoHash)
 	data.Set("includeSingleEpisodes", "true")
 
-	return retryOperation(ctx, 3, 2*time.Second, func() error {
-		return sendHTTPRequest(
-			ctx,
-			http.MethodPost,
-			targetURL,
-			data.Encode(),
-			map[string]string{
-				"Content-Type": "application/x-www-form-urlencoded",
-				"X-Api-Key":    cfg.CrossSeedAPIKey,
-			},
-			http.StatusNoContent,
-		)
-	})
+	send := func(apiKey string) error {
+		return retryOperation(ctx, 3, 2*time.Second, func() error {
+			return hedge(ctx, cfg.CrossSeedHedgeDelay, func(ctx context.Context) error {
+				return sendHTTPRequest(
+					ctx,
+					http.MethodPost,
+					targetURL,
+					data.Encode(),
+					map[string]string{
+						"Content-Type": "application/x-www-form-urlencoded",
+						"X-Api-Key":    apiKey,
+					},
+					http.StatusNoContent,
+				)
+			})
+		})
+	}
+
+	err = send(shard.APIKey)
+	var statusErr *httpStatusError
+	if cfg.CrossSeedAPIKeySecondary == "" || !errors.As(err, &statusErr) || statusErr.code != http.StatusUnauthorized {
+		return err
+	}
+
+	log.DebugContext(ctx, "Cross-seed rejected the primary API key, trying the secondary key")
+	if err := send(cfg.CrossSeedAPIKeySecondary); err != nil {
+		return fmt.Errorf("secondary API key: %w", err)
+	}
+	log.WarnContext(ctx, "Cross-seed accepted the secondary API key; update CROSS_SEED_API_KEY to finish the rotation")
+	return nil
 }
 
 func sendHTTPRequest(
@@ -369,7 +605,7 @@ func sendHTTPRequest(
 	}
 
 	log.DebugContext(ctx, "Sending HTTP request",
-		"url", targetURL,
+		"url", redactURL(targetURL),
 		"method", method,
 		"headers", redactHeaders(headers))
 
@@ -379,16 +615,22 @@ func sendHTTPRequest(
 	}
 	defer resp.Body.Close()
 
-	respBody, _ := io.ReadAll(resp.Body)
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody+1))
+	if int64(len(respBody)) > maxResponseBody {
+		respBody = respBody[:maxResponseBody]
+		log.WarnContext(ctx, "Response body exceeded the limit and was truncated",
+			"url", redactURL(targetURL),
+			"limit", maxResponseBody)
+	}
 
 	log.DebugContext(ctx, "HTTP response received",
 		"status", resp.StatusCode,
+		"compressed", resp.Uncompressed,
 		"body", redactBody(string(respBody)),
 	)
 
 	if resp.StatusCode != expectedStatus {
-		return fmt.Errorf("unexpected status %d (expected %d)",
-			resp.StatusCode, expectedStatus)
+		return &httpStatusError{code: resp.StatusCode, expected: expectedStatus}
 	}
 
 	log.Info("HTTP request was successful")
@@ -396,6 +638,20 @@ func sendHTTPRequest(
 	return nil
 }
 
+// httpStatusError reports a response with an unexpected status code.
+type httpStatusError struct {
+	code     int
+	expected int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d (expected %d)", e.code, e.expected)
+}
+
+func (e *httpStatusError) StatusCode() int {
+	return e.code
+}
+
 func redactHeaders(headers map[string]string) map[string]string {
 	safe := make(map[string]string)
 	for k, v := range headers {
@@ -471,12 +727,7 @@ func isRetriableError(err error) bool {
 }
 
 func redactBody(content string) string {
-	if strings.Contains(content, "api_key") {
-		return "[REDACTED_API_KEY]"
-	}
-	if strings.Contains(content, "token") {
-		return "[REDACTED_TOKEN]"
-	}
+	content = redactText(content)
 	if len(content) > 100 {
 		return fmt.Sprintf("[TRUNCATED_LEN=%d]", len(content))
 	}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"strings"
+)
+
+// runNotify sends one message through the configured Pushover account. It
+// is how qbittorrent-init delivers its crash and ban alerts, so every
+// notification in the image uses this client, its format and its retries.
+func runNotify(ctx context.Context, cfg *Config, args []string) error {
+	fs := flag.NewFlagSet("notify", flag.ContinueOnError)
+	priority := fs.Int("priority", 0, "Pushover priority, from -2 to 2")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: notify [--priority n] <title> <message>")
+	}
+	if *priority < -2 || *priority > 2 {
+		return errors.New("--priority must be between -2 and 2")
+	}
+	if cfg.PushoverUserKey == "" || cfg.PushoverToken == "" {
+		return errors.New("pushover credentials not configured")
+	}
+
+	heading, rest, _ := strings.Cut(fs.Arg(1), "\n")
+	msg := notification{Title: fs.Arg(0), Heading: heading}
+	if rest != "" {
+		msg.Lines = strings.Split(rest, "\n")
+	}
+	return sendPushoverMessage(ctx, cfg, msg, *priority)
+}
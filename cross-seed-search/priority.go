@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultPushoverPriority keeps notifications silent unless configured.
+const defaultPushoverPriority = -2
+
+// parsePushoverPriorities reads PUSHOVER_PRIORITIES, a comma separated list
+// of event=priority pairs such as "errored=1,completed=-2". Priorities run
+// from -2 (no alert) to 1 (high); emergency priority needs acknowledgement
+// handling and is not supported.
+func parsePushoverPriorities(s string) (map[eventKind]int, error) {
+	priorities := make(map[eventKind]int)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		kind := eventKind(strings.ToLower(strings.TrimSpace(name)))
+		switch kind {
+		case eventAdded, eventCompleted, eventErrored:
+		default:
+			return nil, fmt.Errorf("unknown event %q in PUSHOVER_PRIORITIES", name)
+		}
+		priority, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil || priority < -2 || priority > 1 {
+			return nil, fmt.Errorf("invalid priority for %s in PUSHOVER_PRIORITIES: %q (expected -2 to 1)", kind, value)
+		}
+		priorities[kind] = priority
+	}
+	return priorities, nil
+}
+
+// pushoverPriority returns the priority for an event. Releases from the
+// command line carry no event kind and are completions.
+func (c *Config) pushoverPriority(kind eventKind) int {
+	if kind == "" {
+		kind = eventCompleted
+	}
+	if p, ok := c.PushoverPriorities[kind]; ok {
+		return p
+	}
+	return defaultPushoverPriority
+}
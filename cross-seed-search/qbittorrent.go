@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var errQBittorrentForbidden = errors.New("qBittorrent rejected the request: authentication required")
+
+type qbittorrentClient struct {
+	name         string
+	baseURL      string
+	username     string
+	password     string
+	passwordFile string
+	httpClient   *http.Client
+}
+
+func newQBittorrentClient(inst qbittorrentInstance) *qbittorrentClient {
+	jar, _ := cookiejar.New(nil)
+	return &qbittorrentClient{
+		name:         inst.Name,
+		baseURL:      strings.TrimRight(inst.URL, "/"),
+		username:     inst.Username,
+		password:     inst.Password,
+		passwordFile: inst.PasswordFile,
+		// The default transport negotiates gzip, which shrinks maindata
+		// considerably on large instances.
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+			Jar:     jar,
+		},
+	}
+}
+
+func (c *qbittorrentClient) login(ctx context.Context) error {
+	password := c.password
+	if c.passwordFile != "" {
+		data, err := os.ReadFile(c.passwordFile)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && c.password != "":
+			// The password has not been rotated yet.
+		case err != nil:
+			return fmt.Errorf("failed to read password file: %w", err)
+		default:
+			password = strings.TrimSpace(string(data))
+		}
+	}
+
+	form := url.Values{"username": {c.username}, "password": {password}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v2/auth/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", c.baseURL)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("login request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "Ok." {
+		return fmt.Errorf("login failed: %s", strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// getJSON decodes a GET response, logging in once when the session is
+// missing or has expired.
+func (c *qbittorrentClient) getJSON(ctx context.Context, apiPath string, query url.Values, out any) error {
+	return c.stream(ctx, apiPath, query, func(dec *json.Decoder) error {
+		return dec.Decode(out)
+	})
+}
+
+// stream is getJSON for large responses: fn reads the body from the decoder
+// piece by piece instead of it being decoded as one value, which would hold
+// the whole response in memory.
+func (c *qbittorrentClient) stream(ctx context.Context, apiPath string, query url.Values, fn func(*json.Decoder) error) error {
+	target := c.baseURL + apiPath
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("request failed: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized {
+			resp.Body.Close()
+			if attempt > 0 {
+				return errQBittorrentForbidden
+			}
+			if err := c.login(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s: unexpected status %d", apiPath, resp.StatusCode)
+		}
+		if err := fn(json.NewDecoder(resp.Body)); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", apiPath, err)
+		}
+		return nil
+	}
+}
+
+// torrentStats are the transfer totals and swarm counts of one torrent.
+type torrentStats struct {
+	Hash        string  `json:"hash"`
+	Uploaded    int64   `json:"uploaded"`
+	Downloaded  int64   `json:"downloaded"`
+	Ratio       float64 `json:"ratio"`
+	NumSeeds    int     `json:"num_seeds"`
+	NumComplete int     `json:"num_complete"`
+}
+
+// torrentStats fetches the stats of several torrents in one request.
+func (c *qbittorrentClient) torrentStats(ctx context.Context, hashes []string) (map[string]*torrentStats, error) {
+	var torrents []torrentStats
+	if err := c.getJSON(ctx, "/api/v2/torrents/info", url.Values{"hashes": {strings.Join(hashes, "|")}}, &torrents); err != nil {
+		return nil, err
+	}
+	stats := make(map[string]*torrentStats, len(torrents))
+	for i := range torrents {
+		stats[strings.ToLower(torrents[i].Hash)] = &torrents[i]
+	}
+	return stats, nil
+}
+
+// errTorrentRejected is returned by addTorrent when qBittorrent refuses the
+// torrent, which it also does when the torrent is already in the client.
+var errTorrentRejected = errors.New("qBittorrent did not add the torrent")
+
+// addTorrent uploads a .torrent file with the given torrents/add options,
+// logging in once when the session is missing or has expired.
+func (c *qbittorrentClient) addTorrent(ctx context.Context, filename string, data []byte, options url.Values) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for key, values := range options {
+		for _, v := range values {
+			if err := form.WriteField(key, v); err != nil {
+				return fmt.Errorf("failed to build form: %w", err)
+			}
+		}
+	}
+	part, err := form.CreateFormFile("torrents", filename)
+	if err != nil {
+		return fmt.Errorf("failed to build form: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return fmt.Errorf("failed to build form: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return fmt.Errorf("failed to build form: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v2/torrents/add", bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Referer", c.baseURL)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("request failed: %w", err)
+		}
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized:
+			if attempt > 0 {
+				return errQBittorrentForbidden
+			}
+			if err := c.login(ctx); err != nil {
+				return err
+			}
+			continue
+		case resp.StatusCode == http.StatusUnsupportedMediaType || resp.StatusCode == http.StatusConflict:
+			return errTorrentRejected
+		case resp.StatusCode != http.StatusOK:
+			return fmt.Errorf("/api/v2/torrents/add: unexpected status %d", resp.StatusCode)
+		case strings.TrimSpace(string(reply)) == "Fails.":
+			return errTorrentRejected
+		}
+		return nil
+	}
+}
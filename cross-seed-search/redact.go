@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveParams are query parameters and form or JSON keys whose values are
+// credentials, such as tracker passkeys and API keys.
+var sensitiveParams = map[string]bool{
+	"passkey": true, "pk": true, "torrent_pass": true, "authkey": true, "auth": true,
+	"apikey": true, "api_key": true, "api-key": true, "key": true, "token": true,
+	"access_token": true, "secret": true, "sig": true, "signature": true, "password": true,
+}
+
+var (
+	urlPattern      = regexp.MustCompile(`https?://[^\s"'<>]+`)
+	keyValuePattern = regexp.MustCompile(`(?i)("?(?:passkey|torrent_pass|authkey|api[_-]?key|(?:access_)?token|secret|password)"?\s*[:=]\s*"?)([^"&\s,}]+)`)
+)
+
+// redactURL masks credentials in a URL for logging: userinfo passwords,
+// sensitive query parameters and path segments that look like passkeys.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+
+	segments := strings.Split(u.Path, "/")
+	for i, s := range segments {
+		if looksLikePasskey(s) {
+			segments[i] = redacted
+		}
+	}
+	u.Path = strings.Join(segments, "/")
+	u.RawPath = ""
+
+	query := u.Query()
+	for name := range query {
+		if sensitiveParams[strings.ToLower(name)] {
+			query.Set(name, redacted)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	// url.URL would escape the brackets of the placeholder.
+	return strings.ReplaceAll(u.String(), "%5BREDACTED%5D", redacted)
+}
+
+// looksLikePasskey reports whether a path segment is a long random token
+// mixing letters and digits. Info hashes are left alone since they identify
+// the torrent rather than the user.
+func looksLikePasskey(s string) bool {
+	if len(s) < 16 || (len(s) == 40 && isHexString(s)) {
+		return false
+	}
+	var letters, digits bool
+	for _, r := range s {
+		switch {
+		case unicode.IsDigit(r):
+			digits = true
+		case unicode.IsLetter(r):
+			letters = true
+		case r != '-' && r != '_':
+			return false
+		}
+	}
+	return letters && digits
+}
+
+// redactText masks URLs and key/value credentials embedded in free text
+// such as response bodies.
+func redactText(s string) string {
+	s = urlPattern.ReplaceAllStringFunc(s, redactURL)
+	return keyValuePattern.ReplaceAllString(s, "${1}"+redacted)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+// runRender prints the Pushover notification for a made-up release without
+// sending it, to try out PUSHOVER_FORMAT, PUSHOVER_PRIORITIES and the
+// PUSHOVER_TITLE templates.
+func runRender(cfg *Config, args []string) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	event := fs.String("event", string(eventCompleted), "event to render (added, completed or errored)")
+	name := fs.String("name", "Example.Release.2024.1080p.WEB-DL", "release name")
+	category := fs.String("category", "tv", "category")
+	size := fs.Int64("size", 4<<30, "size in bytes")
+	indexer := fs.String("indexer", "https://tracker.example.org", "indexer")
+	instance := fs.String("instance", "", "qBittorrent instance")
+	format := fs.String("format", string(cfg.PushoverFormat), "message format (html or text)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	kind := eventKind(*event)
+	switch kind {
+	case eventAdded, eventCompleted, eventErrored:
+	default:
+		return fmt.Errorf("unknown event %q", *event)
+	}
+	f, err := parseMessageFormat("--format", *format)
+	if err != nil {
+		return err
+	}
+
+	release := &ReleaseInfo{
+		Name:     *name,
+		Category: *category,
+		Size:     *size,
+		Indexer:  *indexer,
+		Type:     "Torrent",
+		Event:    kind,
+		Instance: *instance,
+	}
+	release.IndexerName = cfg.Indexers.resolve(context.Background(), release.Indexer)
+	msg := releaseNotification(cfg, release)
+	fmt.Printf("Title:    %s\nPriority: %d\nFormat:   %s\n\n%s\n", msg.Title, cfg.pushoverPriority(kind), f, msg.render(f))
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// runReplay re-attempts deliveries recorded in the failure journal. Entries
+// that succeed are removed from the journal; entries that fail again are kept
+// with the new error.
+func runReplay(ctx context.Context, cfg *Config, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	since := fs.String("since", "", "only failures at or after this time (RFC 3339, date, or age such as 24h or 7d)")
+	until := fs.String("until", "", "only failures before this time (RFC 3339, date, or age)")
+	sink := fs.String("sink", "", "only failures of this sink (pushover or cross-seed)")
+	instance := fs.String("instance", "", "only failures of this qBittorrent instance")
+	interactive := fs.Bool("interactive", false, "confirm each delivery")
+	fs.BoolVar(interactive, "i", false, "shorthand for --interactive")
+	dryRun := fs.Bool("dry-run", false, "list matching failures without delivering them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var from, to time.Time
+	var err error
+	if *since != "" {
+		if from, err = parseReplayTime(*since); err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+	}
+	if *until != "" {
+		if to, err = parseReplayTime(*until); err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+	switch *sink {
+	case "", sinkPushover, sinkCrossSeed:
+	default:
+		return fmt.Errorf("unknown sink %q", *sink)
+	}
+
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+	path := failureJournalPath()
+	if path == "" {
+		return errors.New("the failure journal is disabled (FAILURE_JOURNAL_ENABLED=false)")
+	}
+
+	entries, lines, err := readFailureJournal(path)
+	if err != nil {
+		return err
+	}
+
+	journal := openConfiguredJournal()
+	if journal != nil {
+		defer journal.close()
+	}
+
+	limiters := map[string]*rate.Limiter{
+		sinkPushover:  rate.NewLimiter(rate.Every(5*time.Second), 2),
+		sinkCrossSeed: rate.NewLimiter(rate.Every(5*time.Second), 2),
+	}
+	stdin := bufio.NewReader(os.Stdin)
+
+	var (
+		kept                         []failureEntry
+		matched, delivered, obsolete int
+		quit                         bool
+	)
+	for i, entry := range entries {
+		match := !quit &&
+			(*sink == "" || entry.Sink == *sink) &&
+			(*instance == "" || entry.Instance == *instance) &&
+			(from.IsZero() || !entry.FailedAt.Before(from)) &&
+			(to.IsZero() || entry.FailedAt.Before(to))
+		if !match {
+			kept = append(kept, entry)
+			continue
+		}
+		matched++
+
+		// Skip failures that were delivered since, e.g. through an admin API
+		// requeue.
+		if journal != nil && entry.Event != eventSweep {
+			if t, ok := journal.recordedAt(entry.Instance, entry.InfoHash, entry.Event); ok && t.After(entry.FailedAt) {
+				obsolete++
+				continue
+			}
+		}
+
+		fmt.Printf("%s  %-10s  %-9s  %s  %s\n",
+			entry.FailedAt.Local().Format(time.DateTime), entry.Sink, entry.Event, entry.InfoHash, entry.Name)
+
+		if *dryRun {
+			kept = append(kept, entry)
+			continue
+		}
+
+		if *interactive {
+			answer, err := prompt(stdin, "Replay? [y]es/[n]o/[a]ll/[q]uit: ")
+			if err != nil {
+				return err
+			}
+			switch answer {
+			case "a", "all":
+				*interactive = false
+			case "y", "yes":
+			case "q", "quit":
+				quit = true
+				kept = append(kept, entry)
+				continue
+			default:
+				kept = append(kept, entry)
+				continue
+			}
+		}
+
+		if err := replayFailure(ctx, cfg, limiters[entry.Sink], entry); err != nil {
+			fmt.Printf("  failed: %v\n", err)
+			entry.Error = err.Error()
+			entry.FailedAt = time.Now().UTC()
+			kept = append(kept, entry)
+			if ctx.Err() != nil {
+				quit = true
+			}
+			continue
+		}
+		fmt.Println("  delivered")
+		delivered++
+
+		if journal != nil && entry.Event != eventSweep && !pendingFailure(slices.Concat(kept, entries[i+1:]), entry) {
+			if err := journal.record(entry.Instance, entry.InfoHash, entry.Event); err != nil {
+				log.Warn("Failed to record processed event", "error", err)
+			}
+		}
+	}
+
+	if !*dryRun {
+		if err := rewriteFailureJournal(path, len(lines), kept); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%d matched, %d delivered, %d already delivered, %d remaining in journal\n",
+		matched, delivered, obsolete, len(kept))
+	return nil
+}
+
+func replayFailure(ctx context.Context, cfg *Config, limiter *rate.Limiter, entry failureEntry) error {
+	if err := limiter.Wait(ctx); err != nil {
+		return err
+	}
+	release := entry.release()
+	switch entry.Sink {
+	case sinkPushover:
+		if !cfg.PushoverEnabled {
+			return errors.New("Pushover is not enabled")
+		}
+		return sendPushoverNotification(ctx, cfg, release)
+	case sinkCrossSeed:
+		if !cfg.CrossSeedEnabled {
+			return errors.New("CrossSeed is not enabled")
+		}
+		return searchCrossSeed(ctx, cfg, release)
+	default:
+		return fmt.Errorf("unknown sink %q", entry.Sink)
+	}
+}
+
+// pendingFailure reports whether another failure of the same event is still
+// in the journal or waiting to be replayed.
+func pendingFailure(remaining []failureEntry, entry failureEntry) bool {
+	for _, e := range remaining {
+		if e.Instance == entry.Instance && e.InfoHash == entry.InfoHash && e.Event == entry.Event {
+			return true
+		}
+	}
+	return false
+}
+
+func prompt(r *bufio.Reader, question string) (string, error) {
+	fmt.Print(question)
+	answer, err := r.ReadString('\n')
+	if err != nil && answer == "" {
+		if errors.Is(err, io.EOF) {
+			return "q", nil
+		}
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(answer)), nil
+}
+
+// parseReplayTime accepts an RFC 3339 timestamp, a date, or an age such as
+// 24h or 7d counted back from now.
+func parseReplayTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.ParseFloat(days, 64); err == nil && n >= 0 {
+			return time.Now().Add(-time.Duration(n * 24 * float64(time.Hour))), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a time, date or age", s)
+	}
+	return time.Now().Add(-d), nil
+}
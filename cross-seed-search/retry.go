@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// retryEntry is a queued event persisted on shutdown so that it is delivered
+// after the next start instead of being lost.
+type retryEntry struct {
+	Instance string    `json:"instance,omitempty"`
+	Event    eventKind `json:"event"`
+	Name     string    `json:"name"`
+	InfoHash string    `json:"info_hash"`
+	Category string    `json:"category"`
+	Size     int64     `json:"size"`
+	Indexer  string    `json:"indexer"`
+	Type     string    `json:"type"`
+	State    string    `json:"state,omitempty"`
+	Sinks    []string  `json:"sinks,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+func newRetryEntry(ev torrentEvent) retryEntry {
+	r := ev.Release
+	return retryEntry{
+		Instance: r.Instance,
+		Event:    ev.Kind,
+		Name:     r.Name,
+		InfoHash: r.InfoHash,
+		Category: r.Category,
+		Size:     r.Size,
+		Indexer:  r.Indexer,
+		Type:     r.Type,
+		State:    ev.State,
+		Sinks:    ev.Sinks,
+		Time:     ev.Time,
+	}
+}
+
+func (e retryEntry) event() torrentEvent {
+	return torrentEvent{
+		Kind: e.Event,
+		Release: &ReleaseInfo{
+			Name:     e.Name,
+			InfoHash: e.InfoHash,
+			Category: e.Category,
+			Size:     e.Size,
+			Indexer:  e.Indexer,
+			Type:     e.Type,
+			Instance: e.Instance,
+		},
+		State: e.State,
+		Time:  e.Time,
+		Sinks: e.Sinks,
+	}
+}
+
+// takeRetryJournal reads the events left over by the previous shutdown and
+// removes the file, so they are only requeued once.
+func takeRetryJournal(path string) ([]torrentEvent, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open retry journal: %w", err)
+	}
+	defer f.Close()
+
+	var events []torrentEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry retryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.InfoHash == "" {
+			log.Warn("Skipping malformed retry journal line", "path", path)
+			continue
+		}
+		events = append(events, entry.event())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read retry journal: %w", err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return nil, fmt.Errorf("failed to remove retry journal: %w", err)
+	}
+	return events, nil
+}
+
+// writeRetryJournal appends events to the retry journal. Appending keeps
+// anything a previous shutdown wrote if it was never taken.
+func writeRetryJournal(path string, events []torrentEvent) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create retry journal directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open retry journal: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, ev := range events {
+		if err := enc.Encode(newRetryEntry(ev)); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write retry journal: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write retry journal: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write retry journal: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// loadSecretsDir reads SECRETS_DIR, a directory of files each named after an
+// environment variable and holding its value, as mounted for Kubernetes and
+// Docker secrets. The files are set as environment variables unless the
+// variable is already set, so the rest of the configuration doesn't need to
+// know where a value came from.
+func loadSecretsDir() error {
+	dir := os.Getenv("SECRETS_DIR")
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read SECRETS_DIR: %w", err)
+	}
+
+	var loaded []string
+	for _, entry := range entries {
+		name := entry.Name()
+		// Kubernetes keeps the real files in ..data and timestamped
+		// directories and links them by name.
+		if strings.HasPrefix(name, ".") || !envNamePattern.MatchString(name) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if os.Getenv(name) != "" {
+			log.Warn("Secret file ignored, the variable is already set", "variable", name)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read secret %s: %w", name, err)
+		}
+		os.Setenv(name, strings.TrimRight(string(data), "\r\n"))
+		loaded = append(loaded, name)
+	}
+	log.Info("Loaded secrets", "dir", dir, "variables", loaded)
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// runSelfTest sends a synthetic message through every configured sink and
+// reports the result and latency of each, to check a new deployment.
+func runSelfTest(ctx context.Context, cfg *Config) error {
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+
+	tests := []struct {
+		sink    string
+		enabled bool
+		run     func(context.Context, *Config) error
+	}{
+		{sinkPushover, cfg.PushoverEnabled, testPushover},
+		{sinkCrossSeed, cfg.CrossSeedEnabled, testCrossSeed},
+	}
+
+	var tested, failed int
+	for _, t := range tests {
+		if !t.enabled {
+			fmt.Printf("%-10s  skipped (not enabled)\n", t.sink)
+			continue
+		}
+		tested++
+		start := time.Now()
+		err := t.run(ctx, cfg)
+		latency := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			failed++
+			fmt.Printf("%-10s  failed after %s: %v\n", t.sink, latency, err)
+			continue
+		}
+		fmt.Printf("%-10s  ok in %s\n", t.sink, latency)
+	}
+
+	switch {
+	case tested == 0:
+		return errors.New("no sinks are enabled")
+	case failed > 0:
+		return fmt.Errorf("%d of %d sinks failed", failed, tested)
+	}
+	return nil
+}
+
+func testPushover(ctx context.Context, cfg *Config) error {
+	msg := notification{
+		Title:   "cross-seed-search test",
+		Heading: "Test notification",
+		Lines:   []string{"Pushover notifications are working."},
+	}
+	return sendPushoverMessage(ctx, cfg, msg, 0)
+}
+
+// testCrossSeed checks that every cross-seed instance answers. It does not
+// call the webhook, since that would start a search.
+func testCrossSeed(ctx context.Context, cfg *Config) error {
+	var errs []error
+	for _, shard := range cfg.CrossSeedShards {
+		if err := pingCrossSeed(ctx, shard); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", redactURL(shard.URL), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func pingCrossSeed(ctx context.Context, shard crossSeedShard) error {
+	target, err := buildSafeURL(shard.URL, "/api/ping")
+	if err != nil {
+		return fmt.Errorf("failed to build safe URL: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Api-Key", shard.APIKey)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &httpStatusError{code: resp.StatusCode, expected: http.StatusOK}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type hostRate struct {
+	every time.Duration
+	burst int
+}
+
+// hostShaper queues events per tracker host and releases them through one
+// token bucket per host. Hosts are served round-robin, so a backlog for one
+// busy tracker does not delay events for the others.
+type hostShaper struct {
+	defaultRate hostRate
+	overrides   map[string]hostRate
+	limiters    map[string]*rate.Limiter
+	queues      map[string][]torrentEvent
+	hosts       []string
+	next        int
+	pending     int
+}
+
+func newHostShaper(defaultRate hostRate, overrides map[string]hostRate) *hostShaper {
+	return &hostShaper{
+		defaultRate: defaultRate,
+		overrides:   overrides,
+		limiters:    make(map[string]*rate.Limiter),
+		queues:      make(map[string][]torrentEvent),
+	}
+}
+
+func (s *hostShaper) len() int {
+	return s.pending
+}
+
+func (s *hostShaper) push(ev torrentEvent) {
+	host := eventHost(ev)
+	if _, ok := s.queues[host]; !ok {
+		s.hosts = append(s.hosts, host)
+	}
+	s.queues[host] = append(s.queues[host], ev)
+	s.pending++
+}
+
+// pop returns the next event whose host has a token available. When every
+// queued host is throttled it returns how long to wait instead.
+func (s *hostShaper) pop(now time.Time) (torrentEvent, time.Duration, bool) {
+	var wait time.Duration
+	for i := range s.hosts {
+		idx := (s.next + i) % len(s.hosts)
+		host := s.hosts[idx]
+
+		r := s.limiter(host).ReserveN(now, 1)
+		if d := r.DelayFrom(now); d > 0 {
+			r.CancelAt(now)
+			if wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+
+		queue := s.queues[host]
+		ev := queue[0]
+		s.pending--
+		if len(queue) == 1 {
+			s.next = idx
+			s.dropHost(idx)
+		} else {
+			s.queues[host] = queue[1:]
+			s.next = (idx + 1) % len(s.hosts)
+		}
+		return ev, 0, true
+	}
+	return torrentEvent{}, wait, false
+}
+
+// dropHost removes an empty host queue, keeping the round-robin position.
+func (s *hostShaper) dropHost(idx int) {
+	delete(s.queues, s.hosts[idx])
+	s.hosts = slices.Delete(s.hosts, idx, idx+1)
+	if s.next > idx {
+		s.next--
+	}
+	if s.next >= len(s.hosts) {
+		s.next = 0
+	}
+}
+
+// list returns the queued events, grouped by host in round-robin order.
+func (s *hostShaper) list() []torrentEvent {
+	events := make([]torrentEvent, 0, s.pending)
+	for i := range s.hosts {
+		host := s.hosts[(s.next+i)%len(s.hosts)]
+		events = append(events, s.queues[host]...)
+	}
+	return events
+}
+
+// remove drops a queued event by ID.
+func (s *hostShaper) remove(id uint64) bool {
+	for idx, host := range s.hosts {
+		queue := s.queues[host]
+		for i, ev := range queue {
+			if ev.ID != id {
+				continue
+			}
+			s.pending--
+			if len(queue) == 1 {
+				s.dropHost(idx)
+			} else {
+				s.queues[host] = slices.Delete(queue, i, i+1)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// limiter returns the host's token bucket. Buckets are kept after the queue
+// drains so that a host cannot regain a full burst by going idle briefly.
+func (s *hostShaper) limiter(host string) *rate.Limiter {
+	lim, ok := s.limiters[host]
+	if !ok {
+		r, ok := s.overrides[host]
+		if !ok {
+			r = s.defaultRate
+		}
+		lim = rate.NewLimiter(rate.Every(r.every), r.burst)
+		s.limiters[host] = lim
+	}
+	return lim
+}
+
+func eventHost(ev torrentEvent) string {
+	u, err := url.Parse(ev.Release.Indexer)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// parseHostRates parses DAEMON_HOST_RATES entries of the form
+// host=interval[/burst], e.g. "tracker.example.org=10s/1,other.org=2s".
+func parseHostRates(spec string, defaultRate hostRate) (map[string]hostRate, error) {
+	rates := make(map[string]hostRate)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, value, ok := strings.Cut(entry, "=")
+		if !ok || host == "" {
+			return nil, fmt.Errorf("invalid host rate %q (expected host=interval[/burst])", entry)
+		}
+
+		r := hostRate{burst: defaultRate.burst}
+		interval, burst, hasBurst := strings.Cut(value, "/")
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid interval in host rate %q", entry)
+		}
+		r.every = d
+		if hasBurst {
+			if r.burst, err = strconv.Atoi(burst); err != nil || r.burst < 1 {
+				return nil, fmt.Errorf("invalid burst in host rate %q", entry)
+			}
+		}
+		rates[strings.ToLower(host)] = r
+	}
+	return rates, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// crossSeedShard is one cross-seed instance.
+type crossSeedShard struct {
+	URL    string
+	APIKey string
+}
+
+// parseCrossSeedShards splits CROSS_SEED_URL into one shard per comma
+// separated URL. CROSS_SEED_API_KEY is either one key shared by all of them
+// or a key per URL in the same order.
+func parseCrossSeedShards(urls, keys string) ([]crossSeedShard, error) {
+	if strings.TrimSpace(urls) == "" {
+		return nil, nil
+	}
+	urlList := strings.Split(urls, ",")
+	keyList := strings.Split(keys, ",")
+	if len(keyList) != 1 && len(keyList) != len(urlList) {
+		return nil, fmt.Errorf("CROSS_SEED_API_KEY has %d keys for %d URLs in CROSS_SEED_URL", len(keyList), len(urlList))
+	}
+
+	shards := make([]crossSeedShard, len(urlList))
+	for i, u := range urlList {
+		shards[i] = crossSeedShard{URL: strings.TrimSpace(u), APIKey: strings.TrimSpace(keyList[min(i, len(keyList)-1)])}
+		if shards[i].URL == "" {
+			return nil, fmt.Errorf("empty URL at position %d in CROSS_SEED_URL", i+1)
+		}
+	}
+	return shards, nil
+}
+
+// crossSeedShard picks the instance for a torrent from the first two bytes
+// of its info hash, so each instance searches a fixed subset and a torrent is
+// never searched twice. Changing the number of instances moves most
+// torrents to a different one.
+func (cfg *Config) crossSeedShard(infoHash string) crossSeedShard {
+	n := len(cfg.CrossSeedShards)
+	if n == 1 {
+		return cfg.CrossSeedShards[0]
+	}
+	var key uint32
+	if prefix, err := hex.DecodeString(infoHash[:min(len(infoHash), 4)]); err == nil && len(prefix) == 2 {
+		key = uint32(prefix[0])<<8 | uint32(prefix[1])
+	} else {
+		h := fnv.New32a()
+		h.Write([]byte(strings.ToLower(infoHash)))
+		key = h.Sum32()
+	}
+	return cfg.CrossSeedShards[key%uint32(n)]
+}
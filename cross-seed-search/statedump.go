@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// watchStateDump writes the daemon's state to stderr on SIGUSR1, and to
+// STATE_DUMP_PATH too when it is set, for debugging a daemon that seems stuck
+// without restarting it.
+func watchStateDump(ctx context.Context, d *daemon) {
+	path := os.Getenv("STATE_DUMP_PATH")
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+			case <-ctx.Done():
+				return
+			}
+
+			var buf bytes.Buffer
+			d.dumpState(&buf)
+			os.Stderr.Write(buf.Bytes())
+			if path != "" {
+				if err := os.WriteFile(path, buf.Bytes(), 0o640); err != nil {
+					log.Error("Failed to write state dump", "path", path, "error", err)
+					continue
+				}
+			}
+			log.Info("Wrote state dump", "path", path)
+		}
+	}()
+}
+
+func (d *daemon) dumpState(w io.Writer) {
+	now := time.Now()
+	fmt.Fprintf(w, "=== cross-seed-search %s state at %s (up %s)\n",
+		version, now.UTC().Format(time.RFC3339), now.Sub(d.started).Round(time.Second))
+
+	fmt.Fprintln(w, "\n--- queues")
+	fmt.Fprintf(w, "events channel: %d/%d\n", len(d.events), cap(d.events))
+	fmt.Fprintf(w, "workers busy:   %d/%d\n", len(d.workers), cap(d.workers))
+	for _, sink := range slices.Sorted(maps.Keys(d.sinkSlots)) {
+		slots := d.sinkSlots[sink]
+		fmt.Fprintf(w, "%s sends:  %d/%d\n", sink, len(slots), cap(slots))
+	}
+	if d.digest != nil {
+		d.digest.mu.Lock()
+		fmt.Fprintf(w, "digest pending: %d\n", len(d.digest.events))
+		d.digest.mu.Unlock()
+	}
+
+	d.mu.Lock()
+	fmt.Fprintf(w, "shaped events:  %d\n", d.shaper.len())
+	fmt.Fprintln(w, "\n--- rate limiters")
+	fmt.Fprintf(w, "pushover: %.2f tokens\n", d.pushoverLimiter.TokensAt(now))
+	for _, host := range slices.Sorted(maps.Keys(d.shaper.limiters)) {
+		fmt.Fprintf(w, "%s: %.2f tokens, %d queued\n",
+			host, d.shaper.limiters[host].TokensAt(now), len(d.shaper.queues[host]))
+	}
+	fmt.Fprintln(w, "\n--- last errors")
+	if len(d.failures) == 0 {
+		fmt.Fprintln(w, "none")
+	}
+	for _, f := range d.failures[max(len(d.failures)-10, 0):] {
+		fmt.Fprintf(w, "%s %s %q: %s\n",
+			f.Time.UTC().Format(time.RFC3339), f.Sink, f.Event.Release.Name, f.Error)
+	}
+	d.mu.Unlock()
+
+	fmt.Fprintln(w, "\n--- goroutines")
+	pprof.Lookup("goroutine").WriteTo(w, 2)
+	fmt.Fprintln(w, "=== end of state dump")
+}
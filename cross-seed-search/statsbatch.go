@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statsBatcher coalesces stats lookups for torrents that finish close
+// together into a single torrents/info request per window, instead of one
+// request per event.
+type statsBatcher struct {
+	client *qbittorrentClient
+	window time.Duration
+
+	mu      sync.Mutex
+	pending map[string][]chan statsResult
+}
+
+type statsResult struct {
+	stats *torrentStats
+	err   error
+}
+
+func newStatsBatcher(client *qbittorrentClient, window time.Duration) *statsBatcher {
+	return &statsBatcher{client: client, window: window}
+}
+
+func (b *statsBatcher) get(ctx context.Context, hash string) (*torrentStats, error) {
+	hash = strings.ToLower(hash)
+	ch := make(chan statsResult, 1)
+
+	b.mu.Lock()
+	if b.pending == nil {
+		b.pending = make(map[string][]chan statsResult)
+		time.AfterFunc(b.window, b.flush)
+	}
+	b.pending[hash] = append(b.pending[hash], ch)
+	b.mu.Unlock()
+
+	select {
+	case r := <-ch:
+		return r.stats, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *statsBatcher) flush() {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	hashes := make([]string, 0, len(pending))
+	for hash := range pending {
+		hashes = append(hashes, hash)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stats, err := b.client.torrentStats(ctx, hashes)
+	log.Debug("Fetched torrent stats", "instance", b.client.name, "torrents", len(hashes), "error", err)
+
+	for hash, waiters := range pending {
+		r := statsResult{stats: stats[hash], err: err}
+		if err == nil && r.stats == nil {
+			r.err = fmt.Errorf("torrent %s not found", hash)
+		}
+		for _, ch := range waiters {
+			ch <- r
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+type sweepConfig struct {
+	Enabled    bool
+	Interval   time.Duration
+	Delay      time.Duration
+	MinAge     time.Duration
+	MaxAge     time.Duration
+	Categories []string
+}
+
+func loadSweepConfig() *sweepConfig {
+	var categories []string
+	for _, c := range strings.Split(os.Getenv("CROSS_SEED_SWEEP_CATEGORIES"), ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			categories = append(categories, c)
+		}
+	}
+
+	return &sweepConfig{
+		Enabled:    getEnvBool("CROSS_SEED_SWEEP_ENABLED", false),
+		Interval:   getEnvDuration("CROSS_SEED_SWEEP_INTERVAL", 24*time.Hour),
+		Delay:      getEnvDuration("CROSS_SEED_SWEEP_DELAY", 30*time.Second),
+		MinAge:     getEnvDuration("CROSS_SEED_SWEEP_MIN_AGE", 0),
+		MaxAge:     getEnvDuration("CROSS_SEED_SWEEP_MAX_AGE", 0),
+		Categories: categories,
+	}
+}
+
+type torrentInfo struct {
+	Hash string `json:"hash"`
+	torrentState
+}
+
+// runCrossSeedSweep periodically queues a cross-seed search for every
+// completed torrent that matches the sweep filters, one every Delay, so
+// existing libraries get backfilled without hammering the indexers.
+func runCrossSeedSweep(ctx context.Context, scfg *sweepConfig, client *qbittorrentClient, events chan<- torrentEvent) {
+	for {
+		if err := sweepOnce(ctx, scfg, client, events); err != nil && ctx.Err() == nil {
+			log.WarnContext(ctx, "Cross-seed sweep failed", "instance", client.name, "error", err)
+		}
+
+		select {
+		case <-time.After(scfg.Interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func sweepOnce(ctx context.Context, scfg *sweepConfig, client *qbittorrentClient, events chan<- torrentEvent) error {
+	// The list is filtered while it is read, so only the selected torrents
+	// are kept in memory.
+	now := time.Now()
+	var selected []torrentInfo
+	err := client.stream(ctx, "/api/v2/torrents/info", url.Values{"filter": {"completed"}}, func(dec *json.Decoder) error {
+		return decodeArray(dec, func() error {
+			var t torrentInfo
+			if err := dec.Decode(&t); err != nil {
+				return err
+			}
+			if t.CompletionOn <= 0 {
+				return nil
+			}
+			age := now.Sub(time.Unix(t.CompletionOn, 0))
+			if age < scfg.MinAge || (scfg.MaxAge > 0 && age > scfg.MaxAge) {
+				return nil
+			}
+			if len(scfg.Categories) > 0 && !slices.Contains(scfg.Categories, t.Category) {
+				return nil
+			}
+			selected = append(selected, t)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	// Newest first, so recent completions are covered even if the sweep is
+	// interrupted.
+	sort.Slice(selected, func(i, j int) bool { return selected[i].CompletionOn > selected[j].CompletionOn })
+
+	log.InfoContext(ctx, "Starting cross-seed sweep",
+		"instance", client.name,
+		"torrents", len(selected),
+		"delay", scfg.Delay)
+
+	queued := 0
+	for i, t := range selected {
+		if i > 0 {
+			select {
+			case <-time.After(scfg.Delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		release := t.release(client.name, t.Hash)
+		if err := validateRelease(release); err != nil {
+			log.DebugContext(ctx, "Skipping torrent in sweep", "hash", t.Hash, "error", err)
+			continue
+		}
+		select {
+		case events <- torrentEvent{Kind: eventSweep, Release: release, Time: time.Now()}:
+			queued++
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	log.InfoContext(ctx, "Cross-seed sweep finished", "instance", client.name, "queued", queued)
+	return nil
+}
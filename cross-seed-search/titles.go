@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/dustin/go-humanize"
+)
+
+const pushoverTitlePrefix = "PUSHOVER_TITLE"
+
+// titleData is what PUSHOVER_TITLE templates can use.
+type titleData struct {
+	Type     string
+	Event    string
+	Action   string
+	Name     string
+	Category string
+	Indexer  string
+	Instance string
+	Size     string
+}
+
+// loadPushoverTitles parses the title templates: PUSHOVER_TITLE for all
+// notifications, PUSHOVER_TITLE_<EVENT>, PUSHOVER_TITLE_<CATEGORY> and
+// PUSHOVER_TITLE_<CATEGORY>_<EVENT>. Templates are keyed by their suffix.
+func loadPushoverTitles() (map[string]*template.Template, error) {
+	titles := make(map[string]*template.Template)
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		suffix, ok := strings.CutPrefix(name, pushoverTitlePrefix)
+		if !ok || value == "" || (suffix != "" && !strings.HasPrefix(suffix, "_")) {
+			continue
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		titles[strings.TrimPrefix(suffix, "_")] = tmpl
+	}
+	return titles, nil
+}
+
+// titleEnvName turns a category or event into the form used in variable
+// names, e.g. "tv-4k" becomes TV_4K.
+func titleEnvName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// pushoverTitle renders the most specific title template for the release,
+// falling back to "<type> <action>".
+func (c *Config) pushoverTitle(release *ReleaseInfo) string {
+	kind := release.Event
+	if kind == "" {
+		kind = eventCompleted
+	}
+	data := titleData{
+		Type:     release.Type,
+		Event:    string(kind),
+		Action:   pushoverEventTitle(kind),
+		Name:     strings.TrimSuffix(release.Name, ".torrent"),
+		Category: release.Category,
+		Indexer:  release.indexerLabel(),
+		Instance: release.Instance,
+		Size:     humanize.Bytes(uint64(max(release.Size, 0))),
+	}
+	fallback := data.Type + " " + data.Action
+
+	event := titleEnvName(data.Event)
+	keys := []string{event, ""}
+	if release.Category != "" {
+		category := titleEnvName(release.Category)
+		keys = append([]string{category + "_" + event, category}, keys...)
+	}
+	for _, key := range keys {
+		tmpl := c.PushoverTitles[key]
+		if tmpl == nil {
+			continue
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			log.Warn("Failed to render notification title", "template", tmpl.Name(), "error", err)
+			return fallback
+		}
+		return b.String()
+	}
+	return fallback
+}
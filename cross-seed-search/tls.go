@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// strictCipherSuites is the "strict" TLS_CIPHER_SUITES policy, the only
+// suites offered before the policy was configurable.
+var strictCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// tlsConfig builds the TLS settings for outgoing notifications:
+// TLS_MIN_VERSION is 1.2 or 1.3, TLS_CIPHER_SUITES is "default" for Go's
+// secure defaults, "strict", or a list of suite names (TLS 1.2 only; TLS 1.3
+// suites are not configurable), and TLS_INSECURE_SKIP_VERIFY disables
+// certificate verification.
+func tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{}
+
+	switch v := getEnv("TLS_MIN_VERSION", "1.2"); v {
+	case "1.2":
+		cfg.MinVersion = tls.VersionTLS12
+	case "1.3":
+		cfg.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("invalid TLS_MIN_VERSION %q: expected 1.2 or 1.3", v)
+	}
+
+	switch policy := getEnv("TLS_CIPHER_SUITES", "default"); policy {
+	case "default":
+	case "strict":
+		cfg.CipherSuites = strictCipherSuites
+	default:
+		names := make(map[string]uint16)
+		for _, s := range tls.CipherSuites() {
+			names[s.Name] = s.ID
+		}
+		for _, name := range strings.Split(policy, ",") {
+			name = strings.TrimSpace(name)
+			id, ok := names[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown or insecure cipher suite %q in TLS_CIPHER_SUITES", name)
+			}
+			cfg.CipherSuites = append(cfg.CipherSuites, id)
+		}
+	}
+
+	if getEnvBool("TLS_INSECURE_SKIP_VERIFY", false) {
+		log.Warn("TLS CERTIFICATE VERIFICATION IS DISABLED: notifications can be intercepted or spoofed. " +
+			"Unset TLS_INSECURE_SKIP_VERIFY once the endpoint has a trusted certificate.")
+		cfg.InsecureSkipVerify = true
+	}
+
+	if cfg.MinVersion == tls.VersionTLS13 && len(cfg.CipherSuites) > 0 {
+		log.Warn("TLS_CIPHER_SUITES has no effect with TLS_MIN_VERSION=1.3")
+	}
+	return cfg, nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+type eventKind string
+
+const (
+	eventAdded     eventKind = "added"
+	eventCompleted eventKind = "completed"
+	eventErrored   eventKind = "errored"
+	eventSweep     eventKind = "sweep"
+)
+
+type torrentEvent struct {
+	ID      uint64
+	Kind    eventKind
+	Release *ReleaseInfo
+	State   string
+	Time    time.Time
+	Queued  time.Time
+	// Sinks restricts delivery to these sinks; empty means all of them.
+	Sinks []string
+}
+
+func (ev torrentEvent) wantsSink(sink string) bool {
+	return len(ev.Sinks) == 0 || slices.Contains(ev.Sinks, sink)
+}
+
+// torrentState holds the maindata fields the watcher needs. Partial updates
+// are decoded on top of the previous state, so fields that did not change
+// keep their old values.
+type torrentState struct {
+	Name         string  `json:"name"`
+	Category     string  `json:"category"`
+	Size         int64   `json:"size"`
+	TotalSize    int64   `json:"total_size"`
+	Tracker      string  `json:"tracker"`
+	State        string  `json:"state"`
+	Progress     float64 `json:"progress"`
+	CompletionOn int64   `json:"completion_on"`
+}
+
+// torrentUpdate is one torrent of a maindata response, decoded on top of a
+// copy of its previous state.
+type torrentUpdate struct {
+	hash  string
+	prev  *torrentState
+	known bool
+	cur   torrentState
+}
+
+// maindataWatcher polls /api/v2/sync/maindata with the rid of the previous
+// response and turns the incremental updates into torrent events.
+type maindataWatcher struct {
+	client      *qbittorrentClient
+	interval    time.Duration
+	rid         int64
+	torrents    map[string]*torrentState
+	initialized bool
+}
+
+func newMaindataWatcher(client *qbittorrentClient, interval time.Duration) *maindataWatcher {
+	return &maindataWatcher{
+		client:   client,
+		interval: interval,
+		torrents: make(map[string]*torrentState),
+	}
+}
+
+func (w *maindataWatcher) run(ctx context.Context, events chan<- torrentEvent) error {
+	const maxBackoff = 2 * time.Minute
+	delay := w.interval
+
+	for {
+		found, err := w.poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.WarnContext(ctx, "Failed to poll qBittorrent",
+				"instance", w.client.name,
+				"error", err,
+				"retry_in", delay)
+		} else {
+			delay = w.interval
+			paused := false
+			for _, ev := range found {
+				select {
+				case events <- ev:
+					continue
+				default:
+				}
+				// Polling stops until the sinks catch up, rather than
+				// buffering without bound.
+				if !paused {
+					log.WarnContext(ctx, "Event queue is full, pausing polling",
+						"instance", w.client.name)
+					paused = true
+				}
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err != nil {
+			delay = min(delay*2, maxBackoff)
+		}
+	}
+}
+
+// readMaindata streams a maindata response, keeping only the torrent fields
+// the watcher uses, so a full update of a large library is never held in
+// memory as a whole. Nothing is applied until the response was read
+// completely.
+func (w *maindataWatcher) readMaindata(ctx context.Context) (rid int64, full bool, updates []torrentUpdate, removed []string, err error) {
+	query := url.Values{"rid": {strconv.FormatInt(w.rid, 10)}}
+	err = w.client.stream(ctx, "/api/v2/sync/maindata", query, func(dec *json.Decoder) error {
+		return decodeObject(dec, func(key string) error {
+			switch key {
+			case "rid":
+				return dec.Decode(&rid)
+			case "full_update":
+				return dec.Decode(&full)
+			case "torrents_removed":
+				return dec.Decode(&removed)
+			case "torrents":
+				return decodeObject(dec, func(hash string) error {
+					u := torrentUpdate{hash: hash}
+					u.prev, u.known = w.torrents[hash]
+					if u.known {
+						u.cur = *u.prev
+					}
+					if err := dec.Decode(&u.cur); err != nil {
+						var typeErr *json.UnmarshalTypeError
+						if !errors.As(err, &typeErr) {
+							return err
+						}
+						log.WarnContext(ctx, "Ignoring malformed torrent update", "instance", w.client.name, "hash", hash, "error", err)
+						if !u.known {
+							return nil
+						}
+						u.cur = *u.prev
+					}
+					updates = append(updates, u)
+					return nil
+				})
+			default:
+				return skipValue(dec)
+			}
+		})
+	})
+	return rid, full, updates, removed, err
+}
+
+func (w *maindataWatcher) poll(ctx context.Context) ([]torrentEvent, error) {
+	rid, full, updates, removed, err := w.readMaindata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w.rid = rid
+
+	now := time.Now()
+	var events []torrentEvent
+	for _, u := range updates {
+		hash, prev, known := u.hash, u.prev, u.known
+		cur := u.cur
+		w.torrents[hash] = &cur
+
+		// The first response describes the existing library; only changes
+		// after it are reported.
+		if !w.initialized {
+			continue
+		}
+		for _, kind := range torrentTransitions(prev, known, &cur) {
+			events = append(events, torrentEvent{
+				Kind:    kind,
+				Release: cur.release(w.client.name, hash),
+				State:   cur.State,
+				Time:    now,
+			})
+		}
+	}
+
+	for _, hash := range removed {
+		delete(w.torrents, hash)
+	}
+	// A full update after the initial one (for example after qBittorrent
+	// restarted) lists every torrent, so anything missing was removed.
+	if full {
+		listed := make(map[string]bool, len(updates))
+		for _, u := range updates {
+			listed[u.hash] = true
+		}
+		for hash := range w.torrents {
+			if !listed[hash] {
+				delete(w.torrents, hash)
+			}
+		}
+	}
+
+	if !w.initialized {
+		w.initialized = true
+		log.InfoContext(ctx, "Watching qBittorrent for torrent events",
+			"instance", w.client.name,
+			"torrents", len(w.torrents))
+	}
+	return events, nil
+}
+
+// torrentTransitions compares the previous and current state of a torrent.
+// Torrents that are added already complete (for example by cross-seed) only
+// produce an added event, so their own injections do not trigger searches.
+func torrentTransitions(prev *torrentState, known bool, cur *torrentState) []eventKind {
+	if !known {
+		kinds := []eventKind{eventAdded}
+		if isErrorState(cur.State) {
+			kinds = append(kinds, eventErrored)
+		}
+		return kinds
+	}
+
+	var kinds []eventKind
+	wasComplete := prev.Progress >= 1 || prev.CompletionOn > 0
+	isComplete := cur.Progress >= 1 || cur.CompletionOn > 0
+	if !wasComplete && isComplete {
+		kinds = append(kinds, eventCompleted)
+	}
+	if !isErrorState(prev.State) && isErrorState(cur.State) {
+		kinds = append(kinds, eventErrored)
+	}
+	return kinds
+}
+
+func isErrorState(state string) bool {
+	return state == "error" || state == "missingFiles"
+}
+
+func (t *torrentState) release(instance, hash string) *ReleaseInfo {
+	size := t.Size
+	if size <= 0 {
+		size = t.TotalSize
+	}
+	return &ReleaseInfo{
+		Name:     t.Name,
+		InfoHash: hash,
+		Category: t.Category,
+		Size:     size,
+		Indexer:  t.Tracker,
+		Type:     "Torrent",
+		Instance: instance,
+	}
+}
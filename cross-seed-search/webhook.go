@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxWebhookBody = 64 << 10
+
+type webhookConfig struct {
+	Addr         string
+	APIKey       string
+	HMACSecret   string
+	AllowedCIDRs []netip.Prefix
+}
+
+func loadWebhookConfig() (*webhookConfig, error) {
+	cfg := &webhookConfig{
+		Addr:       os.Getenv("WEBHOOK_ADDR"),
+		APIKey:     os.Getenv("WEBHOOK_API_KEY"),
+		HMACSecret: os.Getenv("WEBHOOK_HMAC_SECRET"),
+	}
+	for _, entry := range strings.Split(os.Getenv("WEBHOOK_ALLOWED_CIDRS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid entry %q in WEBHOOK_ALLOWED_CIDRS: %w", entry, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		cfg.AllowedCIDRs = append(cfg.AllowedCIDRs, prefix.Masked())
+	}
+	return cfg, nil
+}
+
+type webhookPayload struct {
+	Event    string `json:"event"`
+	Instance string `json:"instance"`
+	Name     string `json:"name"`
+	InfoHash string `json:"info_hash"`
+	Category string `json:"category"`
+	Size     any    `json:"size"`
+	Indexer  string `json:"indexer"`
+}
+
+// startWebhookServer accepts completion events pushed by other systems, for
+// example a qBittorrent instance that cannot be polled. Requests must come
+// from an allowed network, carry the API key and, when a secret is
+// configured, an X-Signature header of the form sha256=<hex HMAC of body>.
+func startWebhookServer(ctx context.Context, cfg *webhookConfig, d *daemon) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /webhook", d.handleWebhook)
+
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           cfg.authenticate(mux),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		log.Info("Starting webhook server",
+			"addr", cfg.Addr,
+			"hmac", cfg.HMACSecret != "",
+			"allowed_cidrs", len(cfg.AllowedCIDRs))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Webhook server failed", "error", err)
+		}
+	}()
+}
+
+func (cfg *webhookConfig) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.allowed(r.RemoteAddr) {
+			log.Warn("Rejected webhook from disallowed address", "remote", r.RemoteAddr)
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Api-Key")), []byte(cfg.APIKey)) != 1 {
+			log.Warn("Rejected webhook with invalid API key", "remote", r.RemoteAddr)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid API key"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+		if err != nil {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "body too large"})
+			return
+		}
+		if cfg.HMACSecret != "" && !validSignature(cfg.HMACSecret, body, r.Header.Get("X-Signature")) {
+			log.Warn("Rejected webhook with invalid signature", "remote", r.RemoteAddr)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid signature"})
+			return
+		}
+
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cfg *webhookConfig) allowed(remoteAddr string) bool {
+	if len(cfg.AllowedCIDRs) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range cfg.AllowedCIDRs {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func validSignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// handleWebhook accepts a JSON or form encoded event. The fields mirror the
+// command line arguments of the one-shot mode.
+func (d *daemon) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	var p webhookPayload
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON: " + err.Error()})
+			return
+		}
+	} else {
+		body, _ := io.ReadAll(r.Body)
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form body"})
+			return
+		}
+		p = webhookPayload{
+			Event:    form.Get("event"),
+			Instance: form.Get("instance"),
+			Name:     form.Get("name"),
+			InfoHash: form.Get("info_hash"),
+			Category: form.Get("category"),
+			Size:     form.Get("size"),
+			Indexer:  form.Get("indexer"),
+		}
+	}
+
+	kind := eventKind(strings.ToLower(p.Event))
+	switch kind {
+	case "":
+		kind = eventCompleted
+	case eventAdded, eventCompleted, eventErrored:
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown event %q", p.Event)})
+		return
+	}
+
+	size := fmt.Sprint(p.Size)
+	if f, ok := p.Size.(float64); ok {
+		size = strconv.FormatInt(int64(f), 10)
+	}
+	release, err := parseAndValidateReleaseInfo([]string{p.Name, p.InfoHash, p.Category, size, p.Indexer})
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	release.Instance = p.Instance
+
+	ev := torrentEvent{Kind: kind, Release: release, Time: time.Now()}
+	select {
+	case d.events <- ev:
+	default:
+		log.Warn("Rejected webhook event, the queue is full", "hash", release.InfoHash, "remote", r.RemoteAddr)
+		d.rejectBusy(w)
+		return
+	}
+
+	log.Info("Accepted webhook event",
+		"event", kind,
+		"hash", release.InfoHash,
+		"remote", r.RemoteAddr)
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultArchiveDir = "/config/torrent-archive"
+
+type torrentArchiver struct {
+	dir      string
+	layout   []string
+	archived map[string]bool
+}
+
+func newTorrentArchiver() *torrentArchiver {
+	var layout []string
+	for _, part := range strings.Split(getEnv("QBT_ARCHIVE_LAYOUT", "tracker/category"), "/") {
+		switch part = strings.TrimSpace(part); part {
+		case "tracker", "category":
+			layout = append(layout, part)
+		case "":
+		default:
+			log.Warn("Ignoring unknown archive layout component", "component", part)
+		}
+	}
+
+	return &torrentArchiver{
+		dir:      getEnv("QBT_ARCHIVE_DIR", defaultArchiveDir),
+		layout:   layout,
+		archived: make(map[string]bool),
+	}
+}
+
+func (a *torrentArchiver) run(ctx context.Context, client *webUIClient) error {
+	torrents, err := client.torrents(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	var archived int
+	for _, t := range torrents {
+		if a.archived[t.Hash] || t.State == "metaDL" || t.State == "forcedMetaDL" {
+			continue
+		}
+
+		tracker := t.Tracker
+		if tracker == "" {
+			tracker, err = a.firstTracker(ctx, client, t.Hash)
+			if err != nil {
+				log.Warn("Failed to get trackers for torrent", "hash", t.Hash, "error", err)
+				continue
+			}
+		}
+
+		target := filepath.Join(a.targetDir(tracker, t.Category), exportFileName("{name} [{hash}].torrent", t))
+		if _, err := os.Stat(target); err == nil {
+			a.archived[t.Hash] = true
+			continue
+		}
+
+		data, err := client.exportTorrent(ctx, t.Hash)
+		if err != nil {
+			log.Warn("Failed to export torrent for archive", "name", t.Name, "hash", t.Hash, "error", err)
+			continue
+		}
+		if err := writeArchiveFile(target, data); err != nil {
+			return err
+		}
+
+		log.Info("Archived torrent", "name", t.Name, "hash", t.Hash, "path", target)
+		a.archived[t.Hash] = true
+		archived++
+	}
+
+	if archived > 0 {
+		log.Info("Torrent archive updated", "archived", archived, "dir", a.dir)
+	}
+	return nil
+}
+
+func (a *torrentArchiver) firstTracker(ctx context.Context, client *webUIClient, hash string) (string, error) {
+	trackers, err := client.torrentTrackers(ctx, hash)
+	if err != nil {
+		return "", err
+	}
+	for _, tr := range trackers {
+		if !isPseudoTracker(tr.URL) {
+			return tr.URL, nil
+		}
+	}
+	return "", nil
+}
+
+func (a *torrentArchiver) targetDir(tracker, category string) string {
+	parts := []string{a.dir}
+	for _, component := range a.layout {
+		var value string
+		switch component {
+		case "tracker":
+			value = trackerHost(tracker)
+			if value == "" {
+				value = "_no_tracker"
+			}
+		case "category":
+			value = category
+			if value == "" {
+				value = "_uncategorized"
+			}
+		}
+		parts = append(parts, sanitizeFileName(value))
+	}
+	return filepath.Join(parts...)
+}
+
+func writeArchiveFile(target string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to move %s into place: %w", target, err)
+	}
+	return nil
+}
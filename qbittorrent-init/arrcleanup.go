@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	arrActionKeep        = "keep"
+	arrActionDelete      = "delete"
+	arrActionDeleteFiles = "delete-files"
+
+	arrQueuePageSize = 200
+)
+
+// arrClient talks to the v3 API shared by Sonarr and Radarr.
+type arrClient struct {
+	name       string
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+type arrQueueItem struct {
+	ID                    int    `json:"id"`
+	Title                 string `json:"title"`
+	DownloadID            string `json:"downloadId"`
+	Protocol              string `json:"protocol"`
+	TrackedDownloadState  string `json:"trackedDownloadState"`
+	TrackedDownloadStatus string `json:"trackedDownloadStatus"`
+	ErrorMessage          string `json:"errorMessage"`
+}
+
+type arrQueuePage struct {
+	TotalRecords int            `json:"totalRecords"`
+	Records      []arrQueueItem `json:"records"`
+}
+
+// arrCleaner removes Sonarr and Radarr queue items that are stuck after a
+// failed or blocked import, blocklists the release so it is not grabbed
+// again, and deletes the torrent from qBittorrent according to
+// QBT_ARR_CLEANUP_ACTION. Items must stay stuck for QBT_ARR_CLEANUP_GRACE
+// first, which leaves time to fix an import by hand. Like the orphan and
+// prune jobs it only logs what it would do until QBT_ARR_CLEANUP_DRY_RUN is
+// disabled.
+type arrCleaner struct {
+	instances []*arrClient
+	states    []string
+	action    string
+	blocklist bool
+	grace     time.Duration
+	dryRun    bool
+	// stuckSince is keyed by instance name and queue item ID.
+	stuckSince map[string]time.Time
+}
+
+// newArrCleaner reads QBT_ARR_INSTANCES, a comma separated list of names,
+// each configured through QBT_ARR_<NAME>_URL and _API_KEY.
+func newArrCleaner() (*arrCleaner, error) {
+	c := &arrCleaner{
+		states:     splitList(getEnv("QBT_ARR_CLEANUP_STATES", "importBlocked,importFailed,failedPending")),
+		action:     getEnv("QBT_ARR_CLEANUP_ACTION", arrActionDelete),
+		blocklist:  getEnvBool("QBT_ARR_CLEANUP_BLOCKLIST", true),
+		grace:      getEnvDuration("QBT_ARR_CLEANUP_GRACE", time.Hour),
+		dryRun:     getEnvBool("QBT_ARR_CLEANUP_DRY_RUN", true),
+		stuckSince: make(map[string]time.Time),
+	}
+	switch c.action {
+	case arrActionKeep, arrActionDelete, arrActionDeleteFiles:
+	default:
+		return nil, fmt.Errorf("invalid QBT_ARR_CLEANUP_ACTION %q: expected keep, delete or delete-files", c.action)
+	}
+
+	for _, name := range splitList(os.Getenv("QBT_ARR_INSTANCES")) {
+		prefix := "QBT_ARR_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name)) + "_"
+		inst := &arrClient{
+			name:       name,
+			baseURL:    strings.TrimRight(os.Getenv(prefix+"URL"), "/"),
+			apiKey:     os.Getenv(prefix + "API_KEY"),
+			httpClient: &http.Client{Timeout: 30 * time.Second},
+		}
+		if inst.baseURL == "" || inst.apiKey == "" {
+			return nil, fmt.Errorf("arr instance %q needs %sURL and %sAPI_KEY", name, prefix, prefix)
+		}
+		c.instances = append(c.instances, inst)
+	}
+	if len(c.instances) == 0 {
+		return nil, errors.New("QBT_ARR_INSTANCES is empty")
+	}
+	return c, nil
+}
+
+func (c *arrCleaner) run(ctx context.Context, client *webUIClient) error {
+	torrents, err := client.torrents(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to list torrents: %w", err)
+	}
+	byHash := make(map[string]torrentInfo, len(torrents))
+	for _, t := range torrents {
+		byHash[strings.ToLower(t.Hash)] = t
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool)
+	var errs []error
+	for _, inst := range c.instances {
+		items, err := inst.queue(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", inst.name, err))
+			// Keep the timers of an instance that is briefly unreachable.
+			for key := range c.stuckSince {
+				if strings.HasPrefix(key, inst.name+"/") {
+					seen[key] = true
+				}
+			}
+			continue
+		}
+
+		for _, item := range items {
+			t, ours := byHash[strings.ToLower(item.DownloadID)]
+			if item.Protocol != "torrent" || !ours || !slices.Contains(c.states, item.TrackedDownloadState) {
+				continue
+			}
+			key := inst.name + "/" + strconv.Itoa(item.ID)
+			seen[key] = true
+			since, ok := c.stuckSince[key]
+			if !ok {
+				c.stuckSince[key] = now
+				since = now
+			}
+			if now.Sub(since) < c.grace {
+				continue
+			}
+
+			if err := c.clean(ctx, client, inst, item, t); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", inst.name, item.Title, err))
+				continue
+			}
+			delete(c.stuckSince, key)
+		}
+	}
+	for key := range c.stuckSince {
+		if !seen[key] {
+			delete(c.stuckSince, key)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *arrCleaner) clean(ctx context.Context, client *webUIClient, inst *arrClient, item arrQueueItem, t torrentInfo) error {
+	action := c.action
+	if action == arrActionDeleteFiles {
+		linked, err := torrentHasExtraHardlinks(ctx, client, t)
+		if err != nil {
+			return fmt.Errorf("hardlink check failed: %w", err)
+		}
+		if linked {
+			action = arrActionDelete
+		}
+	}
+
+	log.Info("Removing stuck download from arr queue",
+		"instance", inst.name,
+		"title", item.Title,
+		"hash", t.Hash,
+		"state", item.TrackedDownloadState,
+		"error", item.ErrorMessage,
+		"blocklist", c.blocklist,
+		"action", action,
+		"dry_run", c.dryRun)
+	if c.dryRun {
+		return nil
+	}
+
+	if err := inst.removeQueueItem(ctx, item.ID, c.blocklist); err != nil {
+		return fmt.Errorf("failed to remove queue item: %w", err)
+	}
+	if action == arrActionKeep {
+		return nil
+	}
+	if err := client.deleteTorrents(ctx, []string{t.Hash}, action == arrActionDeleteFiles); err != nil {
+		return fmt.Errorf("failed to delete torrent: %w", err)
+	}
+	return nil
+}
+
+func (a *arrClient) queue(ctx context.Context) ([]arrQueueItem, error) {
+	var items []arrQueueItem
+	for page := 1; ; page++ {
+		query := url.Values{
+			"page":     {strconv.Itoa(page)},
+			"pageSize": {strconv.Itoa(arrQueuePageSize)},
+			// Sonarr and Radarr each ignore the other's parameter.
+			"includeUnknownSeriesItems": {"true"},
+			"includeUnknownMovieItems":  {"true"},
+		}
+		var result arrQueuePage
+		if err := a.do(ctx, http.MethodGet, "/api/v3/queue", query, &result); err != nil {
+			return nil, err
+		}
+		items = append(items, result.Records...)
+		if len(result.Records) < arrQueuePageSize || len(items) >= result.TotalRecords {
+			return items, nil
+		}
+	}
+}
+
+// removeQueueItem leaves the torrent alone, since its removal is decided
+// here with the hardlink check.
+func (a *arrClient) removeQueueItem(ctx context.Context, id int, blocklist bool) error {
+	query := url.Values{
+		"removeFromClient": {"false"},
+		"blocklist":        {strconv.FormatBool(blocklist)},
+	}
+	return a.do(ctx, http.MethodDelete, "/api/v3/queue/"+strconv.Itoa(id), query, nil)
+}
+
+func (a *arrClient) do(ctx context.Context, method, apiPath string, query url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+apiPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Api-Key", a.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, apiPath)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 32<<20)).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", apiPath, err)
+	}
+	return nil
+}
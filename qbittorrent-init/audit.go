@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// preferenceAuditor snapshots the WebUI preferences and appends what changed
+// since the previous snapshot to preferences.jsonl in QBT_AUDIT_DIR, giving a
+// history of settings changes on a shared instance.
+type preferenceAuditor struct {
+	dir string
+}
+
+type preferenceChange struct {
+	Old any `json:"old,omitempty"`
+	New any `json:"new,omitempty"`
+}
+
+type auditEntry struct {
+	Time    time.Time                   `json:"time"`
+	Changes map[string]preferenceChange `json:"changes"`
+}
+
+func newPreferenceAuditor() *preferenceAuditor {
+	return &preferenceAuditor{dir: getEnv("QBT_AUDIT_DIR", "/config/audit")}
+}
+
+func (a *preferenceAuditor) run(ctx context.Context, client *webUIClient) error {
+	prefs, err := client.preferences(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read preferences: %w", err)
+	}
+	current := make(map[string]json.RawMessage, len(prefs))
+	for key, value := range prefs {
+		data, err := json.Marshal(redactPreference(key, value))
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", key, err)
+		}
+		current[key] = data
+	}
+
+	if err := os.MkdirAll(a.dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	snapshotPath := filepath.Join(a.dir, "preferences.json")
+
+	var previous map[string]json.RawMessage
+	data, err := os.ReadFile(snapshotPath)
+	switch {
+	case os.IsNotExist(err):
+		log.Info("Recording baseline preference snapshot", "path", snapshotPath)
+		return writeSnapshot(snapshotPath, current)
+	case err != nil:
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if err := json.Unmarshal(data, &previous); err != nil {
+		log.Warn("Replacing unreadable preference snapshot", "path", snapshotPath, "error", err)
+		return writeSnapshot(snapshotPath, current)
+	}
+
+	changes := make(map[string]preferenceChange)
+	for key, value := range current {
+		if old, ok := previous[key]; !ok || !bytes.Equal(old, value) {
+			changes[key] = preferenceChange{Old: rawOrNil(old), New: value}
+		}
+	}
+	for key, old := range previous {
+		if _, ok := current[key]; !ok {
+			changes[key] = preferenceChange{Old: old}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	entry, err := json.Marshal(auditEntry{Time: time.Now().UTC(), Changes: changes})
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(a.dir, "preferences.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	_, err = f.Write(append(entry, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	log.Info("Preferences changed", "keys", slices.Sorted(maps.Keys(changes)))
+	return writeSnapshot(snapshotPath, current)
+}
+
+func redactPreference(key string, value any) any {
+	if s, ok := value.(string); ok {
+		return redactConfigValue(key, s)
+	}
+	return value
+}
+
+func rawOrNil(raw json.RawMessage) any {
+	if raw == nil {
+		return nil
+	}
+	return raw
+}
+
+func writeSnapshot(path string, snapshot map[string]json.RawMessage) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
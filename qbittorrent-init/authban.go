@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var loginFailurePattern = regexp.MustCompile(`WebAPI login failure\. Reason: ([^,]+), attempt count: \d+, IP: ([^,\s]+)`)
+
+// authBanner polls qBittorrent's log for failed WebUI logins and bans
+// addresses that fail QBT_AUTH_BAN_MAX_FAILURES times within
+// QBT_AUTH_BAN_WINDOW for QBT_AUTH_BAN_DURATION. Bans are announced through
+// the lifecycle webhook and, with QBT_AUTH_BAN_NOTIFY, Pushover, but only
+// the gateway enforces them, so the list needs QBT_GATEWAY_ADDR.
+// qBittorrent's own ban is configured with the same limits for clients that
+// reach the WebUI directly.
+type authBanner struct {
+	maxFailures int
+	window      time.Duration
+	duration    time.Duration
+	ignore      []netip.Prefix
+	notify      bool
+	interval    time.Duration
+
+	mu       sync.Mutex
+	failures map[netip.Addr][]time.Time
+	banned   map[netip.Addr]time.Time
+}
+
+// newAuthBanner returns nil unless QBT_AUTH_BAN_ENABLED is set.
+func newAuthBanner() (*authBanner, error) {
+	if !getEnvBool("QBT_AUTH_BAN_ENABLED", false) {
+		return nil, nil
+	}
+	b := &authBanner{
+		maxFailures: max(getEnvInt("QBT_AUTH_BAN_MAX_FAILURES", 5), 1),
+		window:      getEnvDuration("QBT_AUTH_BAN_WINDOW", 10*time.Minute),
+		duration:    getEnvDuration("QBT_AUTH_BAN_DURATION", time.Hour),
+		notify:      getEnvBool("QBT_AUTH_BAN_NOTIFY", false),
+		interval:    getEnvDuration("QBT_AUTH_BAN_POLL_INTERVAL", 5*time.Second),
+		failures:    make(map[netip.Addr][]time.Time),
+		banned:      make(map[netip.Addr]time.Time),
+	}
+	for _, entry := range splitList(os.Getenv("QBT_AUTH_BAN_IGNORE")) {
+		prefix, err := parsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid QBT_AUTH_BAN_IGNORE: %w", err)
+		}
+		b.ignore = append(b.ignore, prefix)
+	}
+	return b, nil
+}
+
+// watch reads new warnings from /api/v2/log/main every interval until ctx
+// ends. Log IDs start again with each qBittorrent process, so watch runs
+// once per process.
+func (b *authBanner) watch(ctx context.Context, client *webUIClient) {
+	lastID := -1
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		var entries []struct {
+			ID      int    `json:"id"`
+			Message string `json:"message"`
+		}
+		query := url.Values{
+			"normal":        {"false"},
+			"info":          {"false"},
+			"warning":       {"true"},
+			"critical":      {"false"},
+			"last_known_id": {strconv.Itoa(lastID)},
+		}
+		if err := client.getJSON(ctx, "/api/v2/log/main", query, &entries); err != nil {
+			if ctx.Err() == nil {
+				log.Debug("Failed to read qBittorrent log for login failures", "error", err)
+			}
+		} else {
+			b.mu.Lock()
+			for _, e := range entries {
+				lastID = max(lastID, e.ID)
+				if m := loginFailurePattern.FindStringSubmatch(e.Message); m != nil {
+					b.recordFailure(m[2], m[1])
+				}
+			}
+			b.mu.Unlock()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// recordFailure must be called with b.mu held.
+func (b *authBanner) recordFailure(ip, reason string) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return
+	}
+	addr = addr.Unmap()
+	// Requests through the gateway come from loopback; never ban it.
+	if addr.IsLoopback() || b.ignored(addr) {
+		return
+	}
+
+	now := time.Now()
+	if until, ok := b.banned[addr]; ok && now.Before(until) {
+		return
+	}
+
+	recent := b.failures[addr][:0]
+	for _, t := range b.failures[addr] {
+		if now.Sub(t) < b.window {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	log.Debug("WebUI login failure", "ip", addr, "reason", reason, "failures", len(recent))
+
+	if len(recent) < b.maxFailures {
+		b.failures[addr] = recent
+		return
+	}
+	delete(b.failures, addr)
+	until := now.Add(b.duration)
+	b.banned[addr] = until
+	log.Warn("Banned address after failed WebUI logins", "ip", addr, "failures", len(recent), "until", until)
+	go b.announce(addr, len(recent), until)
+}
+
+func (b *authBanner) ignored(addr netip.Addr) bool {
+	for _, p := range b.ignore {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *authBanner) isBanned(addr netip.Addr) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	addr = addr.Unmap()
+	until, ok := b.banned[addr]
+	if ok && time.Now().After(until) {
+		delete(b.banned, addr)
+		return false
+	}
+	return ok
+}
+
+func (b *authBanner) announce(addr netip.Addr, failures int, until time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	lifecycle.emit(ctx, eventAuthBanned, map[string]any{
+		"ip":       addr.String(),
+		"failures": failures,
+		"until":    until,
+	})
+	if !b.notify {
+		return
+	}
+	message := fmt.Sprintf("%s was banned until %s after %d failed WebUI logins", addr, until.Format(time.RFC3339), failures)
+	if err := sendPushover(ctx, "qBittorrent login ban", message, 0); err != nil {
+		log.Error("Ban notification failed", "error", err)
+	}
+}
+
+// applyAuthBanSettings configures qBittorrent's built-in WebUI ban with the
+// same limits. It reports whether conf changed.
+func applyAuthBanSettings(conf *iniFile) (bool, error) {
+	if !getEnvBool("QBT_AUTH_BAN_ENABLED", false) {
+		return false, nil
+	}
+	changed := false
+	for _, kv := range [][2]string{
+		{`WebUI\MaxAuthenticationFailCount`, strconv.Itoa(max(getEnvInt("QBT_AUTH_BAN_MAX_FAILURES", 5), 1))},
+		{`WebUI\BanDuration`, strconv.Itoa(int(getEnvDuration("QBT_AUTH_BAN_DURATION", time.Hour).Seconds()))},
+	} {
+		if current, ok := conf.get("Preferences", kv[0]); ok && current == kv[1] {
+			continue
+		}
+		conf.set("Preferences", kv[0], kv[1])
+		changed = true
+	}
+	return changed, nil
+}
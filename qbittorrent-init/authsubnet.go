@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// internalNetworks are the ranges authentication may be bypassed for without
+// QBT_WEBUI_AUTH_SUBNET_ALLOW_PUBLIC.
+var internalNetworks = []netip.Prefix{
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("::1/128"),
+}
+
+// applyAuthSubnetWhitelist enables the WebUI's authentication bypass for the
+// subnets in QBT_WEBUI_AUTH_SUBNET_WHITELIST, so a reverse proxy that already
+// authenticates users doesn't ask twice. Subnets outside private ranges are
+// refused unless QBT_WEBUI_AUTH_SUBNET_ALLOW_PUBLIC is set. It reports
+// whether conf changed.
+func applyAuthSubnetWhitelist(conf *iniFile) (bool, error) {
+	raw := os.Getenv("QBT_WEBUI_AUTH_SUBNET_WHITELIST")
+	if raw == "" {
+		return false, nil
+	}
+	subnets, err := parseAuthSubnets(splitList(raw), getEnvBool("QBT_WEBUI_AUTH_SUBNET_ALLOW_PUBLIC", false))
+	if err != nil {
+		return false, fmt.Errorf("invalid QBT_WEBUI_AUTH_SUBNET_WHITELIST: %w", err)
+	}
+
+	changed := false
+	for _, kv := range [][2]string{
+		{`WebUI\AuthSubnetWhitelistEnabled`, "true"},
+		{`WebUI\AuthSubnetWhitelist`, strings.Join(subnets, ", ")},
+	} {
+		key, value := kv[0], kv[1]
+		if current, ok := conf.get("Preferences", key); ok && current == value {
+			continue
+		}
+		conf.set("Preferences", key, value)
+		changed = true
+	}
+	if changed {
+		log.Info("Applied WebUI authentication bypass subnets", "subnets", subnets)
+	}
+	return changed, nil
+}
+
+func parseAuthSubnets(entries []string, allowPublic bool) ([]string, error) {
+	var subnets []string
+	for _, entry := range entries {
+		prefix, err := parsePrefix(entry)
+		if err != nil {
+			return nil, err
+		}
+		if !allowPublic && !isInternalPrefix(prefix) {
+			return nil, fmt.Errorf("%s is not a private network", prefix)
+		}
+		subnets = append(subnets, prefix.String())
+	}
+	return subnets, nil
+}
+
+// parsePrefix parses a subnet in CIDR notation or a single address.
+func parsePrefix(entry string) (netip.Prefix, error) {
+	if prefix, err := netip.ParsePrefix(entry); err == nil {
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%q is not a subnet or address", entry)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func isInternalPrefix(prefix netip.Prefix) bool {
+	for _, n := range internalNetworks {
+		if n.Bits() <= prefix.Bits() && n.Contains(prefix.Addr()) {
+			return true
+		}
+	}
+	return false
+}
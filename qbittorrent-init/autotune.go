@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	cgroupMemoryMaxPath = "/sys/fs/cgroup/memory.max"
+	cgroupCPUMaxPath    = "/sys/fs/cgroup/cpu.max"
+)
+
+// cgroupMemoryLimit returns the cgroup v2 memory limit in bytes, or 0 when
+// there is none.
+func cgroupMemoryLimit() int64 {
+	data, err := os.ReadFile(cgroupMemoryMaxPath)
+	if err != nil {
+		return 0
+	}
+	limit, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return limit
+}
+
+// autotuneCache sizes the disk cache, disk queue and file pool for the
+// container's memory limit, so the template's values don't get a small
+// deployment OOM killed. Settings that no longer have the template value are
+// left alone. It reports whether conf changed.
+func autotuneCache(conf *iniFile) bool {
+	if !getEnvBool("QBT_CACHE_AUTOTUNE", true) {
+		return false
+	}
+	limit := cgroupMemoryLimit()
+	if limit <= 0 {
+		return false
+	}
+	mib := limit >> 20
+
+	return applyTuned(conf, []tunedSetting{
+		{key: `Session\DiskCacheSize`, value: min(max(mib/8, 16), 1024), auto: true},
+		{key: `Session\DiskQueueSize`, value: min(max(limit/128, 512<<10), 4<<20), auto: true},
+		{key: `Session\FilePoolSize`, value: min(max(mib/16, 10), 40), auto: true},
+	}, "memory_limit_mib", mib)
+}
+
+// cgroupCPULimit returns the number of CPUs available to the container: the
+// cgroup v2 CPU quota rounded up, capped at the CPUs the process may run on.
+func cgroupCPULimit() int64 {
+	cpus := int64(runtime.NumCPU())
+	data, err := os.ReadFile(cgroupCPUMaxPath)
+	if err != nil {
+		return cpus
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return cpus
+	}
+	quota, err1 := strconv.ParseInt(fields[0], 10, 64)
+	period, err2 := strconv.ParseInt(fields[1], 10, 64)
+	if err1 != nil || err2 != nil || quota <= 0 || period <= 0 {
+		return cpus
+	}
+	return min(cpus, max((quota+period-1)/period, 1))
+}
+
+// autotuneThreads sizes the hashing and asynchronous I/O thread pools for the
+// CPUs available to the container. QBT_HASHING_THREADS and
+// QBT_ASYNC_IO_THREADS set them explicitly. It reports whether conf changed.
+func autotuneThreads(conf *iniFile) bool {
+	cpus := int64(0)
+	if getEnvBool("QBT_CPU_AUTOTUNE", true) {
+		cpus = cgroupCPULimit()
+	}
+	return applyTuned(conf, []tunedSetting{
+		{key: `Session\HashingThreadsCount`, value: min(max(cpus/2, 1), 8), env: "QBT_HASHING_THREADS", auto: cpus > 0},
+		{key: `Session\AsyncIOThreadsCount`, value: min(max(cpus*2, 4), 16), env: "QBT_ASYNC_IO_THREADS", auto: cpus > 0},
+	}, "cpus", cpus)
+}
+
+// tunedSetting is a computed value for a BitTorrent setting. A positive
+// integer in env overrides it; otherwise it is only applied when auto is set
+// and the config still has the template value.
+type tunedSetting struct {
+	key   string
+	value int64
+	env   string
+	auto  bool
+}
+
+func applyTuned(conf *iniFile, settings []tunedSetting, attrs ...any) bool {
+	template := parseINI([]byte(defaultConfigTemplate))
+	changed := false
+	for _, t := range settings {
+		current, ok := conf.get("BitTorrent", t.key)
+		value := strconv.FormatInt(t.value, 10)
+		if override := getEnvInt(t.env, 0); t.env != "" && override > 0 {
+			value = strconv.Itoa(override)
+		} else if def, _ := template.get("BitTorrent", t.key); !t.auto || (ok && current != def) {
+			continue
+		}
+		if current == value {
+			continue
+		}
+		conf.set("BitTorrent", t.key, value)
+		changed = true
+		log.Info("Tuned setting", append([]any{"key", t.key, "value", value}, attrs...)...)
+	}
+	return changed
+}
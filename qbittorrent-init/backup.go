@@ -0,0 +1,300 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBackupDir  = "/config/backups"
+	backupRoot        = "/config"
+	backupPrefix      = "qbittorrent-backup-"
+	backupSuffix      = ".tar.gz"
+	backupManifestKey = "manifest.json"
+)
+
+type backupManifest struct {
+	CreatedAt   time.Time `json:"created_at"`
+	InitVersion string    `json:"init_version"`
+	Files       []string  `json:"files"`
+}
+
+type backupJob struct {
+	dir      string
+	keep     int
+	sources  []string
+	uploader backupUploader
+}
+
+func qbtConfigDir() string {
+	return filepath.Dir(defaultConfigPath)
+}
+
+func qbtDataDir() string {
+	return getEnv("QBT_DATA_DIR", qbtConfigDir())
+}
+
+func backupSources() []string {
+	return []string{
+		defaultConfigPath,
+		filepath.Join(qbtConfigDir(), "categories.json"),
+		filepath.Join(qbtDataDir(), "BT_backup"),
+		filepath.Join(qbtDataDir(), "torrents.db"),
+	}
+}
+
+func newBackupJob() (*backupJob, error) {
+	uploader, err := newBackupUploader()
+	if err != nil {
+		return nil, err
+	}
+	return &backupJob{
+		dir:      getEnv("QBT_BACKUP_DIR", defaultBackupDir),
+		keep:     getEnvInt("QBT_BACKUP_KEEP", 7),
+		sources:  backupSources(),
+		uploader: uploader,
+	}, nil
+}
+
+func (b *backupJob) run(ctx context.Context, _ *webUIClient) error {
+	archivePath, err := createBackupArchive(b.dir, b.sources)
+	if err != nil {
+		return err
+	}
+
+	if b.uploader != nil {
+		if err := b.uploader.upload(ctx, archivePath); err != nil {
+			log.Error("Backup upload failed", "path", archivePath, "error", err)
+		} else {
+			log.Info("Backup uploaded", "path", archivePath, "target", b.uploader.String())
+		}
+	}
+
+	rotateBackups(b.dir, b.keep)
+	return nil
+}
+
+func createBackupArchive(dir string, sources []string) (string, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	now := time.Now().UTC()
+	archivePath := filepath.Join(dir, backupPrefix+now.Format("20060102T150405Z")+backupSuffix)
+
+	tmp, err := os.CreateTemp(dir, ".backup-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+
+	manifest := backupManifest{CreatedAt: now, InitVersion: version}
+	for _, src := range sources {
+		files, err := addToArchive(tw, src, dir)
+		if err != nil {
+			tmp.Close()
+			return "", fmt.Errorf("failed to archive %s: %w", src, err)
+		}
+		manifest.Files = append(manifest.Files, files...)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := writeTarFile(tw, backupManifestKey, data, now); err != nil {
+		tmp.Close()
+		return "", err
+	}
+
+	if err := tw.Close(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to finalize tar: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to finalize gzip: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to close archive: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), archivePath); err != nil {
+		return "", fmt.Errorf("failed to install archive: %w", err)
+	}
+
+	log.Info("Backup archive created", "path", archivePath, "files", len(manifest.Files))
+	return archivePath, nil
+}
+
+// addToArchive adds src, walking it when it is a directory. Files that
+// disappear during the walk, such as resume data of a torrent removed
+// meanwhile, are skipped. SQLite databases are archived as a snapshot taken
+// in scratch.
+func addToArchive(tw *tar.Writer, src, scratch string) ([]string, error) {
+	if _, err := os.Lstat(src); os.IsNotExist(err) {
+		log.Debug("Backup source does not exist, skipping", "path", src)
+		return nil, nil
+	}
+
+	var files []string
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(backupRoot, p)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("path %s is outside %s", p, backupRoot)
+		}
+		name := filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			info, err := d.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			hdr, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = name + "/"
+			return tw.WriteHeader(hdr)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		open := p
+		if filepath.Base(p) == "torrents.db" {
+			snapshot, err := snapshotSQLite(p, scratch)
+			if err != nil {
+				return fmt.Errorf("failed to snapshot %s: %w", p, err)
+			}
+			defer os.RemoveAll(filepath.Dir(snapshot))
+			open = snapshot
+		}
+
+		f, err := os.Open(open)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer f.Close()
+		// The header comes from the open file, so a file replaced after the
+		// walk listed it is archived whole rather than torn.
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(tw, f, info.Size()); err != nil {
+			return err
+		}
+		files = append(files, hdr.Name)
+		return nil
+	})
+	return files, err
+}
+
+// snapshotSQLite writes a consistent copy of a live database with VACUUM
+// INTO, which includes changes still in its write-ahead log.
+func snapshotSQLite(dbPath, scratch string) (string, error) {
+	tmpDir, err := os.MkdirTemp(scratch, ".snapshot-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	snapshot := filepath.Join(tmpDir, filepath.Base(dbPath))
+
+	db, err := openSQLite(dbPath, true)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	defer db.Close()
+	if _, err := db.Exec("VACUUM INTO ?", snapshot); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	return snapshot, nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}); err != nil {
+		return fmt.Errorf("failed to write %s header: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func listBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func rotateBackups(dir string, keep int) {
+	if keep <= 0 {
+		return
+	}
+
+	backups, err := listBackups(dir)
+	if err != nil {
+		log.Warn("Failed to list backups for rotation", "error", err)
+		return
+	}
+
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil {
+			log.Warn("Failed to remove old backup", "path", backups[0], "error", err)
+		} else {
+			log.Info("Removed old backup", "path", backups[0])
+		}
+		backups = backups[1:]
+	}
+}
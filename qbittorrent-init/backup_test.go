@@ -0,0 +1,81 @@
+package main
+
+import (
+	"archive/tar"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotSQLiteIncludesLog(t *testing.T) {
+	dbPath := createResumeDB(t, 20)
+
+	// Keep a writer open with changes that are only in the log, as
+	// qBittorrent does while running.
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{
+		`PRAGMA wal_autocheckpoint = 0`,
+		`UPDATE torrents SET name = 'changed'`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scratch := t.TempDir()
+	snapshot, err := snapshotSQLite(dbPath, scratch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(snapshot + "-wal"); !os.IsNotExist(err) {
+		t.Fatalf("snapshot has a write-ahead log: %v", err)
+	}
+	if err := checkSQLiteIntegrity(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := readSQLiteTable(snapshot, "torrents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 20 {
+		t.Fatalf("snapshot has %d rows, want 20", len(rows))
+	}
+	for _, row := range rows {
+		if row["name"] != "changed" {
+			t.Fatalf("row %v has name %v, want the logged change", row["id"], row["name"])
+		}
+	}
+	if filepath.Dir(filepath.Dir(snapshot)) != scratch {
+		t.Fatalf("snapshot %s not taken in %s", snapshot, scratch)
+	}
+}
+
+func TestValidateArchiveEntryDataDir(t *testing.T) {
+	t.Setenv("QBT_DATA_DIR", "/config/data")
+	for name, want := range map[string]bool{
+		"qBittorrent/qBittorrent.conf":         true,
+		"qBittorrent/categories.json":          true,
+		"data/BT_backup/":                      true,
+		"data/BT_backup/abc.fastresume":        true,
+		"data/torrents.db":                     true,
+		"qBittorrent/BT_backup/abc.fastresume": false,
+		"data/other":                           false,
+		"../etc/passwd":                        false,
+	} {
+		typ := byte(tar.TypeReg)
+		if strings.HasSuffix(name, "/") {
+			typ = tar.TypeDir
+		}
+		err := validateArchiveEntry(&tar.Header{Name: name, Typeflag: typ})
+		if got := err == nil; got != want {
+			t.Errorf("%s: got %v, want allowed=%v", name, err, want)
+		}
+	}
+}
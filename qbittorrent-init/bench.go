@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	mathrand "math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+)
+
+const benchBlockSize = 16 << 10 // libtorrent writes 16 KiB blocks
+
+type benchResult struct {
+	sequential float64 // MiB/s
+	random     float64 // MiB/s
+}
+
+// runBenchCommand measures write throughput on the download volume and
+// prints disk settings suited to it. --apply writes them to the config, which
+// only works while qBittorrent is stopped since it saves its own settings on
+// exit.
+func runBenchCommand(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	dir := flags.String("dir", "", "directory to benchmark (default: the configured save path)")
+	sizeMiB := flags.Int("size", 256, "amount of data to write in MiB")
+	apply := flags.Bool("apply", false, "write the recommended settings to the config")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *sizeMiB < 16 {
+		return errors.New("--size must be at least 16 MiB")
+	}
+
+	conf, err := readINIFile(defaultConfigPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	if *dir == "" {
+		*dir = "/downloads"
+		if conf != nil {
+			if p, ok := conf.get("BitTorrent", `Session\DefaultSavePath`); ok && p != "" {
+				*dir = p
+			}
+		}
+	}
+	if *apply {
+		if conf == nil {
+			return fmt.Errorf("--apply needs an existing config at %s", defaultConfigPath)
+		}
+		if _, err := os.Stat(runningMarkerPath); err == nil {
+			return errors.New("--apply needs qBittorrent to be stopped, it would overwrite the settings on exit")
+		}
+	}
+
+	fmt.Printf("Benchmarking %s with %d MiB...\n", *dir, *sizeMiB)
+	result, err := benchmarkDisk(*dir, int64(*sizeMiB)<<20)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Sequential write: %8.1f MiB/s\n", result.sequential)
+	fmt.Printf("Random write:     %8.1f MiB/s (%d KiB blocks)\n\n", result.random, benchBlockSize>>10)
+
+	class, settings := recommendDiskSettings(result, cgroupCPULimit(), cgroupMemoryLimit() > 0)
+	fmt.Printf("The volume looks %s. Recommended settings:\n", class)
+	for _, s := range settings {
+		current := "unset"
+		if conf != nil {
+			if v, ok := conf.get("BitTorrent", s[0]); ok {
+				current = v
+			}
+		}
+		fmt.Printf("  %-32s %-18s (currently %s)\n", s[0], s[1], current)
+	}
+
+	if !*apply {
+		fmt.Println("\nRun with --apply to write them to the config.")
+		return nil
+	}
+	for _, s := range settings {
+		conf.set("BitTorrent", s[0], s[1])
+	}
+	if err := conf.writeFile(defaultConfigPath); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	fmt.Printf("\nApplied to %s.\n", defaultConfigPath)
+	return nil
+}
+
+// benchmarkDisk writes size bytes sequentially, then rewrites blocks of the
+// same file at random offsets. Both runs end with an fsync, so the page cache
+// does not hide the device's speed.
+func benchmarkDisk(dir string, size int64) (benchResult, error) {
+	f, err := os.CreateTemp(dir, ".qbt-bench-*")
+	if err != nil {
+		return benchResult{}, fmt.Errorf("failed to create test file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	buf := make([]byte, 1<<20)
+	rand.Read(buf)
+
+	start := time.Now()
+	for written := int64(0); written < size; written += int64(len(buf)) {
+		if _, err := f.Write(buf); err != nil {
+			return benchResult{}, fmt.Errorf("sequential write failed: %w", err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return benchResult{}, fmt.Errorf("sync failed: %w", err)
+	}
+	var result benchResult
+	result.sequential = mibPerSecond(size, time.Since(start))
+
+	blocks := size / benchBlockSize
+	writes := min(blocks, 8192)
+	start = time.Now()
+	for i := int64(0); i < writes; i++ {
+		off := mathrand.Int64N(blocks) * benchBlockSize
+		if _, err := f.WriteAt(buf[:benchBlockSize], off); err != nil {
+			return benchResult{}, fmt.Errorf("random write failed: %w", err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return benchResult{}, fmt.Errorf("sync failed: %w", err)
+	}
+	result.random = mibPerSecond(writes*benchBlockSize, time.Since(start))
+	return result, nil
+}
+
+func mibPerSecond(n int64, d time.Duration) float64 {
+	return float64(n) / (1 << 20) / max(d.Seconds(), 1e-9)
+}
+
+// recommendDiskSettings picks I/O settings for the measured throughput.
+// Random writes decide the class since that is what seeding and downloading
+// many torrents at once looks like. Slow disks get fewer I/O and hashing
+// threads to limit seeking and a deeper queue to coalesce writes; fast ones
+// get as many threads as the CPUs can use. Under a memory limit, memory
+// mapped I/O would count the mapped files against the limit, so plain
+// pread/pwrite is used.
+func recommendDiskSettings(r benchResult, cpus int64, memoryLimited bool) (string, [][2]string) {
+	ioType := "Default"
+	if memoryLimited {
+		ioType = "SimplePreadPwrite"
+	}
+
+	var class string
+	var asyncIO, hashing, queue int64
+	switch {
+	case r.random < 10:
+		class = "slow (spinning disk or network storage)"
+		asyncIO, hashing, queue = 4, 1, 8<<20
+	case r.random < 100:
+		class = "moderate (SATA SSD or disk array)"
+		asyncIO, hashing, queue = min(max(cpus*2, 4), 16), min(max(cpus/2, 1), 8), 4<<20
+	default:
+		class = "fast (NVMe)"
+		asyncIO, hashing, queue = 16, min(max(cpus/2, 1), 8), 4<<20
+	}
+
+	return class, [][2]string{
+		{`Session\DiskIOType`, ioType},
+		{`Session\AsyncIOThreadsCount`, strconv.FormatInt(asyncIO, 10)},
+		{`Session\HashingThreadsCount`, strconv.FormatInt(hashing, 10)},
+		{`Session\DiskQueueSize`, strconv.FormatInt(queue, 10)},
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var errBencodeSyntax = errors.New("invalid bencode data")
+
+func bdecode(data []byte) (any, error) {
+	v, rest, err := bdecodeValue(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", errBencodeSyntax, len(rest))
+	}
+	return v, nil
+}
+
+func bdecodeValue(data []byte, depth int) (any, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("%w: unexpected end of data", errBencodeSyntax)
+	}
+	if depth > 64 {
+		return nil, nil, fmt.Errorf("%w: nesting too deep", errBencodeSyntax)
+	}
+
+	switch c := data[0]; {
+	case c == 'i':
+		end := bytes.IndexByte(data, 'e')
+		if end < 0 {
+			return nil, nil, fmt.Errorf("%w: unterminated integer", errBencodeSyntax)
+		}
+		n, err := strconv.ParseInt(string(data[1:end]), 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", errBencodeSyntax, err)
+		}
+		return n, data[end+1:], nil
+
+	case c == 'l':
+		var list []any
+		data = data[1:]
+		for len(data) > 0 && data[0] != 'e' {
+			v, rest, err := bdecodeValue(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			list = append(list, v)
+			data = rest
+		}
+		if len(data) == 0 {
+			return nil, nil, fmt.Errorf("%w: unterminated list", errBencodeSyntax)
+		}
+		return list, data[1:], nil
+
+	case c == 'd':
+		dict := make(map[string]any)
+		data = data[1:]
+		for len(data) > 0 && data[0] != 'e' {
+			k, rest, err := bdecodeValue(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: dictionary key is not a string", errBencodeSyntax)
+			}
+			v, rest, err := bdecodeValue(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			dict[key] = v
+			data = rest
+		}
+		if len(data) == 0 {
+			return nil, nil, fmt.Errorf("%w: unterminated dictionary", errBencodeSyntax)
+		}
+		return dict, data[1:], nil
+
+	case c >= '0' && c <= '9':
+		colon := bytes.IndexByte(data, ':')
+		if colon < 0 {
+			return nil, nil, fmt.Errorf("%w: missing string length separator", errBencodeSyntax)
+		}
+		n, err := strconv.Atoi(string(data[:colon]))
+		if err != nil || n < 0 || colon+1+n > len(data) {
+			return nil, nil, fmt.Errorf("%w: invalid string length", errBencodeSyntax)
+		}
+		return string(data[colon+1 : colon+1+n]), data[colon+1+n:], nil
+
+	default:
+		return nil, nil, fmt.Errorf("%w: unexpected byte %q", errBencodeSyntax, c)
+	}
+}
+
+func bdictString(d map[string]any, key string) string {
+	s, _ := d[key].(string)
+	return s
+}
+
+func bdictInt(d map[string]any, key string) int64 {
+	n, _ := d[key].(int64)
+	return n
+}
+
+func bdictDict(d map[string]any, key string) map[string]any {
+	m, _ := d[key].(map[string]any)
+	return m
+}
+
+func bdictList(d map[string]any, key string) []any {
+	l, _ := d[key].([]any)
+	return l
+}
+
+// brewriteStrings replaces top-level string values of a bencoded dictionary
+// in place, copying every other value byte for byte so nested structures such
+// as the info dictionary keep their original encoding.
+func brewriteStrings(data []byte, fn func(key, value string) (string, bool)) ([]byte, bool, error) {
+	if len(data) == 0 || data[0] != 'd' {
+		return nil, false, fmt.Errorf("%w: not a dictionary", errBencodeSyntax)
+	}
+
+	out := []byte{'d'}
+	changed := false
+	rest := data[1:]
+	for len(rest) > 0 && rest[0] != 'e' {
+		k, afterKey, err := bdecodeValue(rest, 1)
+		if err != nil {
+			return nil, false, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, false, fmt.Errorf("%w: dictionary key is not a string", errBencodeSyntax)
+		}
+		v, afterValue, err := bdecodeValue(afterKey, 1)
+		if err != nil {
+			return nil, false, err
+		}
+
+		out = append(out, rest[:len(rest)-len(afterKey)]...)
+		raw := afterKey[:len(afterKey)-len(afterValue)]
+		if s, ok := v.(string); ok {
+			if replaced, ok := fn(key, s); ok && replaced != s {
+				raw = []byte(strconv.Itoa(len(replaced)) + ":" + replaced)
+				changed = true
+			}
+		}
+		out = append(out, raw...)
+		rest = afterValue
+	}
+	if len(rest) == 0 {
+		return nil, false, fmt.Errorf("%w: unterminated dictionary", errBencodeSyntax)
+	}
+	out = append(out, rest...)
+	return out, changed, nil
+}
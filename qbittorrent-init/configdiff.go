@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+)
+
+// argOverrides maps command line options to the config keys they override.
+var argOverrides = map[string]struct{ section, key string }{
+	"--webui-port":      {"Preferences", `WebUI\Port`},
+	"--torrenting-port": {"BitTorrent", `Session\Port`},
+}
+
+var sensitiveConfigKeys = []string{"password", "secret", "token", "apikey", "api_key", "cookie", "pbkdf2", "_ha1"}
+
+// logConfigDiff logs how the effective configuration differs from the
+// built-in template: keys changed, added or removed on disk, and keys
+// overridden by command line options. Values of sensitive keys are redacted.
+func logConfigDiff(configPath string, args []string) {
+	if !getEnvBool("QBT_CONFIG_DIFF_ENABLED", true) {
+		return
+	}
+	conf, err := readINIFile(configPath)
+	if err != nil {
+		log.Warn("Unable to read configuration for diff", "path", configPath, "error", err)
+		return
+	}
+
+	defaults := iniValues(defaultConfig(false))
+	effective := iniValues(conf)
+
+	changed := make(map[string]string)
+	added := make(map[string]string)
+	removed := make(map[string]string)
+	for key, value := range effective {
+		def, ok := defaults[key]
+		switch {
+		case !ok:
+			added[key] = redactConfigValue(key, value)
+		case def != value:
+			changed[key] = redactConfigValue(key, def) + " -> " + redactConfigValue(key, value)
+		}
+	}
+	for key, def := range defaults {
+		if _, ok := effective[key]; !ok {
+			removed[key] = redactConfigValue(key, def)
+		}
+	}
+
+	overridden := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			break
+		}
+		target, ok := argOverrides[args[i]]
+		if !ok || i+1 >= len(args) {
+			continue
+		}
+		key := target.section + "/" + target.key
+		overridden[key] = args[i] + " " + args[i+1]
+		if value, ok := effective[key]; !ok || value != args[i+1] {
+			changed[key] = redactConfigValue(key, effective[key]) + " -> " + args[i+1] + " (" + args[i] + ")"
+		}
+		i++
+	}
+
+	log.Info("Effective configuration compared to defaults",
+		"path", configPath,
+		"changed", changed,
+		"added", added,
+		"removed", removed,
+		"overridden_by_args", overridden)
+}
+
+func iniValues(f *iniFile) map[string]string {
+	values := make(map[string]string)
+	for _, s := range f.sections {
+		for _, e := range s.entries {
+			if e.key != "" {
+				values[s.name+"/"+e.key] = e.value
+			}
+		}
+	}
+	return values
+}
+
+func redactConfigValue(key, value string) string {
+	if value == "" {
+		return value
+	}
+	lower := strings.ToLower(key)
+	for _, s := range sensitiveConfigKeys {
+		if strings.Contains(lower, s) {
+			return "[redacted]"
+		}
+	}
+	return value
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var qbittorrentBinary = getEnv("QBT_BINARY", "/usr/bin/qbittorrent-nox")
+
+type confMigration struct {
+	since     string
+	section   string
+	key       string
+	toSection string
+	toKey     string
+	convert   func(string) (string, bool)
+	note      string
+}
+
+var confMigrations = []confMigration{
+	{
+		since:   "4.2.0",
+		section: "Preferences",
+		key:     `WebUI\Password_ha1`,
+		note:    "MD5 WebUI password hashes are no longer supported, set a new password (a temporary one is printed in the qBittorrent log)",
+	},
+	{since: "4.3.0", section: "Preferences", key: `Downloads\SavePath`, toSection: "BitTorrent", toKey: `Session\DefaultSavePath`},
+	{since: "4.3.0", section: "Preferences", key: `Downloads\TempPath`, toSection: "BitTorrent", toKey: `Session\TempPath`},
+	{since: "4.3.0", section: "Preferences", key: `Downloads\TempPathEnabled`, toSection: "BitTorrent", toKey: `Session\TempPathEnabled`},
+	{since: "4.3.0", section: "Preferences", key: `Connection\PortRangeMin`, toSection: "BitTorrent", toKey: `Session\Port`},
+	{since: "4.3.0", section: "Preferences", key: `Bittorrent\MaxConnecs`, toSection: "BitTorrent", toKey: `Session\MaxConnections`},
+	{since: "4.3.0", section: "Preferences", key: `Bittorrent\MaxConnecsPerTorrent`, toSection: "BitTorrent", toKey: `Session\MaxConnectionsPerTorrent`},
+	{since: "4.3.0", section: "Preferences", key: `Bittorrent\MaxUploads`, toSection: "BitTorrent", toKey: `Session\MaxUploads`},
+	{since: "4.3.0", section: "Preferences", key: `Bittorrent\MaxUploadsPerTorrent`, toSection: "BitTorrent", toKey: `Session\MaxUploadsPerTorrent`},
+	{since: "4.3.0", section: "Preferences", key: `Bittorrent\DHT`, toSection: "BitTorrent", toKey: `Session\DHTEnabled`},
+	{since: "4.3.0", section: "Preferences", key: `Bittorrent\PeX`, toSection: "BitTorrent", toKey: `Session\PeXEnabled`},
+	{since: "4.3.0", section: "Preferences", key: `Bittorrent\LSD`, toSection: "BitTorrent", toKey: `Session\LSDEnabled`},
+	{since: "4.3.0", section: "Preferences", key: `Queueing\QueueingEnabled`, toSection: "BitTorrent", toKey: `Session\QueueingSystemEnabled`},
+	{
+		since:     "4.3.2",
+		section:   "BitTorrent",
+		key:       `Session\CreateTorrentSubfolder`,
+		toSection: "BitTorrent",
+		toKey:     `Session\TorrentContentLayout`,
+		convert: func(v string) (string, bool) {
+			switch strings.ToLower(v) {
+			case "true":
+				return "Original", true
+			case "false":
+				return "NoSubfolder", true
+			}
+			return "", false
+		},
+	},
+	{
+		since:     "4.4.0",
+		section:   "BitTorrent",
+		key:       `Session\UseOSCache`,
+		toSection: "BitTorrent",
+		toKey:     `Session\DiskIOReadMode`,
+		convert: func(v string) (string, bool) {
+			switch strings.ToLower(v) {
+			case "true":
+				return "EnableOSCache", true
+			case "false":
+				return "DisableOSCache", true
+			}
+			return "", false
+		},
+	},
+	{since: "5.0.0", section: "BitTorrent", key: `Session\AddTorrentPaused`, toSection: "BitTorrent", toKey: `Session\AddTorrentStopped`},
+}
+
+var (
+	versionPattern    = regexp.MustCompile(`v?(\d+)\.(\d+)\.(\d+)`)
+	libtorrentPattern = regexp.MustCompile(`(?i)libtorrent(?:-rasterbar)?\D*(\d+)\.(\d+)\.(\d+)`)
+)
+
+type binaryVersions struct {
+	qbittorrent string
+	// libtorrent is empty when --version does not report it.
+	libtorrent string
+}
+
+// qbittorrentVersions runs --version once and caches the result for the
+// preflight check and the config migration.
+var qbittorrentVersions = sync.OnceValues(func() (binaryVersions, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := verifyBinary(qbittorrentBinary); err != nil {
+		return binaryVersions{}, fmt.Errorf("binary integrity check failed: %w", err)
+	}
+	out, err := exec.CommandContext(ctx, qbittorrentBinary, "--version").Output()
+	if err != nil {
+		return binaryVersions{}, fmt.Errorf("failed to run %s --version: %w", qbittorrentBinary, err)
+	}
+
+	var v binaryVersions
+	if m := libtorrentPattern.FindStringSubmatch(string(out)); m != nil {
+		v.libtorrent = strings.Join(m[1:], ".")
+		out = []byte(strings.Replace(string(out), m[0], "", 1))
+	}
+	m := versionPattern.FindStringSubmatch(string(out))
+	if m == nil {
+		return binaryVersions{}, fmt.Errorf("unrecognised version output: %q", strings.TrimSpace(string(out)))
+	}
+	v.qbittorrent = strings.Join(m[1:], ".")
+	return v, nil
+})
+
+func compareVersions(a, b string) int {
+	pa := strings.Split(a, ".")
+	pb := strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			y, _ = strconv.Atoi(pb[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func migrateConfig(configPath string) error {
+	versions, err := qbittorrentVersions()
+	current := versions.qbittorrent
+	if err != nil {
+		log.Warn("Unable to determine qBittorrent version, skipping config migration", "error", err)
+		return nil
+	}
+
+	stateFile := filepath.Join(filepath.Dir(configPath), ".qbt-version")
+	previous := ""
+	if data, err := os.ReadFile(stateFile); err == nil {
+		previous = strings.TrimSpace(string(data))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read version state: %w", err)
+	}
+
+	if previous == current {
+		log.Debug("qBittorrent version unchanged, skipping config migration", "version", current)
+		return nil
+	}
+	log.Info("qBittorrent version changed, checking configuration for deprecated keys",
+		"previous", previous,
+		"current", current)
+
+	conf, err := readINIFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if changed := applyConfMigrations(conf, current); changed > 0 {
+		backupPath := configPath + ".pre-" + current
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to read config: %w", err)
+		}
+		if err := os.WriteFile(backupPath, data, 0600); err != nil {
+			return fmt.Errorf("failed to back up config: %w", err)
+		}
+		if err := conf.writeFile(configPath); err != nil {
+			return err
+		}
+		log.Info("Configuration migrated", "changes", changed, "backup", backupPath)
+	}
+
+	if err := os.WriteFile(stateFile, []byte(current+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write version state: %w", err)
+	}
+	return nil
+}
+
+func applyConfMigrations(conf *iniFile, current string) int {
+	changed := 0
+	for _, m := range confMigrations {
+		if compareVersions(current, m.since) < 0 {
+			continue
+		}
+		value, ok := conf.get(m.section, m.key)
+		if !ok {
+			continue
+		}
+		from := m.section + `\` + m.key
+
+		if m.toKey == "" {
+			conf.delete(m.section, m.key)
+			log.Warn("Removed deprecated config key", "key", from, "since", m.since, "note", m.note)
+			changed++
+			continue
+		}
+
+		to := m.toSection + `\` + m.toKey
+		if _, exists := conf.get(m.toSection, m.toKey); exists {
+			conf.delete(m.section, m.key)
+			log.Info("Removed config key superseded by its replacement", "key", from, "replacement", to, "since", m.since)
+			changed++
+			continue
+		}
+
+		newValue := value
+		if m.convert != nil {
+			converted, ok := m.convert(value)
+			if !ok {
+				log.Warn("Leaving deprecated config key with unrecognised value", "key", from, "value", value)
+				continue
+			}
+			newValue = converted
+		}
+
+		conf.delete(m.section, m.key)
+		conf.set(m.toSection, m.toKey, newValue)
+		log.Info("Renamed deprecated config key",
+			"key", from,
+			"replacement", to,
+			"old_value", value,
+			"new_value", newValue,
+			"since", m.since)
+		changed++
+	}
+	return changed
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defaultCrashReportDir = "/config/qBittorrent/crash-reports"
+
+type lineRing struct {
+	mu      sync.Mutex
+	lines   []string
+	size    int
+	next    int
+	full    bool
+	partial bytes.Buffer
+}
+
+func newLineRing(size int) *lineRing {
+	if size < 1 {
+		size = 1
+	}
+	return &lineRing{lines: make([]string, size), size: size}
+}
+
+func (r *lineRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.partial.Write(p)
+	for {
+		line, err := r.partial.ReadString('\n')
+		if err != nil {
+			r.partial.Reset()
+			r.partial.WriteString(line)
+			break
+		}
+		r.push(line[:len(line)-1])
+	}
+	return len(p), nil
+}
+
+func (r *lineRing) push(line string) {
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % r.size
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *lineRing) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []string
+	if r.full {
+		out = append(out, r.lines[r.next:]...)
+	}
+	out = append(out, r.lines[:r.next]...)
+	if r.partial.Len() > 0 {
+		out = append(out, r.partial.String())
+	}
+	return out
+}
+
+type crashReport struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Command       string    `json:"command"`
+	ExitCode      int       `json:"exit_code"`
+	Signal        string    `json:"signal,omitempty"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	MaxRSSBytes   int64     `json:"max_rss_bytes,omitempty"`
+	UserCPUMs     int64     `json:"user_cpu_ms"`
+	SystemCPUMs   int64     `json:"system_cpu_ms"`
+	InitMemory    struct {
+		HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+		SysBytes       uint64 `json:"sys_bytes"`
+	} `json:"init_memory"`
+	Error    string   `json:"error,omitempty"`
+	LogLines []string `json:"log_lines"`
+}
+
+func isAbnormalExit(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr)
+}
+
+func buildCrashReport(cmd *exec.Cmd, started time.Time, waitErr error, logs *lineRing) *crashReport {
+	report := &crashReport{
+		Timestamp:     time.Now().UTC(),
+		Command:       cmd.String(),
+		ExitCode:      -1,
+		UptimeSeconds: time.Since(started).Seconds(),
+		LogLines:      logs.snapshot(),
+	}
+	if waitErr != nil {
+		report.Error = waitErr.Error()
+	}
+
+	if state := cmd.ProcessState; state != nil {
+		report.ExitCode = state.ExitCode()
+		report.UserCPUMs = state.UserTime().Milliseconds()
+		report.SystemCPUMs = state.SystemTime().Milliseconds()
+
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			report.Signal = ws.Signal().String()
+		}
+		if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+			report.MaxRSSBytes = ru.Maxrss * 1024
+		}
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	report.InitMemory.HeapAllocBytes = mem.HeapAlloc
+	report.InitMemory.SysBytes = mem.Sys
+
+	return report
+}
+
+func writeCrashReport(dir string, report *crashReport) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create crash report directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal crash report: %w", err)
+	}
+
+	name := fmt.Sprintf("crash-%s.json", report.Timestamp.Format("20060102T150405Z"))
+	reportPath := filepath.Join(dir, name)
+	if err := os.WriteFile(reportPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write crash report: %w", err)
+	}
+	return reportPath, nil
+}
+
+func handleCrash(ctx context.Context, cmd *exec.Cmd, started time.Time, waitErr error, logs *lineRing) {
+	report := buildCrashReport(cmd, started, waitErr, logs)
+
+	reportPath, err := writeCrashReport(getEnv("QBT_CRASH_REPORT_DIR", defaultCrashReportDir), report)
+	if err != nil {
+		log.Error("Failed to capture crash report", "error", err)
+	} else {
+		log.Error("qBittorrent exited abnormally, crash report written",
+			"path", reportPath,
+			"exit_code", report.ExitCode,
+			"signal", report.Signal,
+			"uptime_seconds", report.UptimeSeconds)
+	}
+
+	if !getEnvBool("QBT_CRASH_NOTIFY", false) {
+		return
+	}
+
+	message := fmt.Sprintf("qbittorrent-nox exited with code %d", report.ExitCode)
+	if report.Signal != "" {
+		message += fmt.Sprintf(" (signal: %s)", report.Signal)
+	}
+	message += fmt.Sprintf(" after %s", time.Duration(report.UptimeSeconds*float64(time.Second)).Round(time.Second))
+	if reportPath != "" {
+		message += fmt.Sprintf("\nReport: %s", reportPath)
+	}
+
+	notifyCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if err := sendPushover(notifyCtx, "qBittorrent crashed", message, 1); err != nil {
+		log.Error("Crash notification failed", "error", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// configToggle is a setting the container relaxes by default for ease of use
+// behind a reverse proxy or on a container network. Each can be tightened
+// through its environment variable. pref is the matching WebUI API
+// preference, which is the negation of the config key when invert is set.
+type configToggle struct {
+	env     string
+	section string
+	key     string
+	def     bool
+	pref    string
+	invert  bool
+}
+
+var configToggles = []configToggle{
+	{"QBT_WEBUI_LOCALHOST_AUTH", "Preferences", `WebUI\LocalHostAuth`, false, "bypass_local_auth", true},
+	{"QBT_WEBUI_CSRF_PROTECTION", "Preferences", `WebUI\CSRFProtection`, false, "web_ui_csrf_protection_enabled", false},
+	{"QBT_WEBUI_HOST_HEADER_VALIDATION", "Preferences", `WebUI\HostHeaderValidation`, false, "web_ui_host_header_validation_enabled", false},
+	{"QBT_UPNP", "Preferences", `Connection\UPnP`, false, "upnp", false},
+	{"QBT_WEBUI_UPNP", "Preferences", `WebUI\UseUPnP`, false, "web_ui_upnp", false},
+	{"QBT_RANDOM_PORT", "Preferences", `General\UseRandomPort`, false, "random_port", false},
+}
+
+var errLegalNoticeNotAccepted = errors.New("qBittorrent's legal notice has not been accepted: " +
+	"read it at https://www.qbittorrent.org and set QBT_LEGAL_NOTICE_ACCEPTED=true")
+
+// defaultConfig returns the template with the toggles at their built-in
+// defaults, or at the values from the environment when withEnv is set.
+func defaultConfig(withEnv bool) *iniFile {
+	conf := parseINI([]byte(defaultConfigTemplate))
+	for _, t := range configToggles {
+		value := t.def
+		if withEnv {
+			value = getEnvBool(t.env, t.def)
+		}
+		conf.set(t.section, t.key, strconv.FormatBool(value))
+	}
+	return conf
+}
+
+// applyConfigToggles applies toggles that are set in the environment and the
+// legal notice acceptance to conf, returning whether anything changed. Toggles
+// that are not set keep whatever value the config already has.
+func applyConfigToggles(conf *iniFile) (bool, error) {
+	changed := false
+	set := func(section, key, value string) {
+		if current, ok := conf.get(section, key); !ok || current != value {
+			conf.set(section, key, value)
+			log.Info("Applied config setting from environment", "key", section+`\`+key, "value", value)
+			changed = true
+		}
+	}
+
+	switch accepted := strings.ToLower(getEnv("QBT_LEGAL_NOTICE_ACCEPTED", "")); accepted {
+	case "true":
+		set("LegalNotice", "Accepted", "true")
+	case "":
+		if value, _ := conf.get("LegalNotice", "Accepted"); value != "true" {
+			return false, errLegalNoticeNotAccepted
+		}
+	default:
+		return false, errLegalNoticeNotAccepted
+	}
+
+	for _, t := range configToggles {
+		raw := getEnv(t.env, "")
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return false, fmt.Errorf("invalid %s=%q: expected true or false", t.env, raw)
+		}
+		set(t.section, t.key, strconv.FormatBool(value))
+	}
+	return changed, nil
+}
+
+// applyEnvSettings applies everything the environment declares about
+// qBittorrent.conf to conf, returning whether anything changed.
+func applyEnvSettings(conf *iniFile) (bool, error) {
+	changed := false
+	for _, apply := range []func(*iniFile) (bool, error){
+		applyConfigToggles,
+		applyQueueSettings,
+		applySessionSettings,
+		applyProxySettings,
+		applyAuthBanSettings,
+		applyAuthSubnetWhitelist,
+	} {
+		c, err := apply(conf)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || c
+	}
+	if autotuneCache(conf) {
+		changed = true
+	}
+	if autotuneThreads(conf) {
+		changed = true
+	}
+	return changed, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// declaredSetting is a setting pinned by the environment, with its value as
+// written in qBittorrent.conf and as reported by the WebUI API.
+type declaredSetting struct {
+	env       string
+	section   string
+	key       string
+	value     string
+	pref      string
+	prefValue any
+}
+
+func declaredSettings() []declaredSetting {
+	var settings []declaredSetting
+	for _, t := range configToggles {
+		value, err := strconv.ParseBool(os.Getenv(t.env))
+		if err != nil {
+			continue
+		}
+		settings = append(settings, declaredSetting{
+			env: t.env, section: t.section, key: t.key, value: strconv.FormatBool(value),
+			pref: t.pref, prefValue: value != t.invert,
+		})
+	}
+	for _, p := range []struct{ env, section, key, pref string }{
+		{"QBT_WEBUI_PORT", "Preferences", `WebUI\Port`, "web_ui_port"},
+		{"QBT_TORRENTING_PORT", "BitTorrent", `Session\Port`, "listen_port"},
+	} {
+		raw := os.Getenv(p.env)
+		if !isValidPort(raw) {
+			continue
+		}
+		port, _ := strconv.Atoi(raw)
+		settings = append(settings, declaredSetting{
+			env: p.env, section: p.section, key: p.key, value: raw,
+			pref: p.pref, prefValue: port,
+		})
+	}
+	return settings
+}
+
+// driftChecker compares the settings declared in the environment with
+// qBittorrent.conf and the running instance's preferences, so changes made
+// through the WebUI don't silently diverge from the deployment. With
+// reconcile set, drifted preferences are set back through the API.
+type driftChecker struct {
+	configPath string
+	reconcile  bool
+	last       string
+}
+
+func newDriftChecker() *driftChecker {
+	return &driftChecker{
+		configPath: defaultConfigPath,
+		reconcile:  getEnvBool("QBT_DRIFT_RECONCILE", false),
+	}
+}
+
+func (d *driftChecker) run(ctx context.Context, client *webUIClient) error {
+	declared := declaredSettings()
+	if len(declared) == 0 {
+		return nil
+	}
+
+	fileDrift := make(map[string]string)
+	if conf, err := readINIFile(d.configPath); err != nil {
+		log.Warn("Unable to read configuration for drift check", "path", d.configPath, "error", err)
+	} else {
+		for _, s := range declared {
+			if actual, _ := conf.get(s.section, s.key); actual != s.value {
+				fileDrift[s.section+"/"+s.key] = fmt.Sprintf("%s (%s) -> %s", s.value, s.env, actual)
+			}
+		}
+	}
+
+	prefs, err := client.preferences(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read preferences: %w", err)
+	}
+	apiDrift := make(map[string]string)
+	fix := make(map[string]any)
+	for _, s := range declared {
+		actual, ok := prefs[s.pref]
+		if !ok {
+			continue
+		}
+		if want := fmt.Sprint(s.prefValue); fmt.Sprint(actual) != want {
+			apiDrift[s.pref] = fmt.Sprintf("%s (%s) -> %v", want, s.env, actual)
+			fix[s.pref] = s.prefValue
+		}
+	}
+
+	// Only log when the drift changes, not on every interval.
+	state := fmt.Sprint(fileDrift, apiDrift)
+	switch {
+	case len(fileDrift) == 0 && len(apiDrift) == 0:
+		if d.last != "" {
+			log.Info("Configuration drift resolved")
+		}
+		d.last = ""
+	case state != d.last:
+		log.Warn("Configuration drift detected", "config_file", fileDrift, "api", apiDrift)
+		d.last = state
+	}
+
+	if !d.reconcile || len(fix) == 0 {
+		return nil
+	}
+	if err := client.setPreferences(ctx, fix); err != nil {
+		return fmt.Errorf("failed to reconcile preferences: %w", err)
+	}
+	log.Info("Reconciled configuration drift", "preferences", len(fix))
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// dryRun is set by --dry-run or QBT_INIT_DRY_RUN. Validation then reports
+// what it would create or change instead of doing it.
+var dryRun bool
+
+// initArgs removes the initializer's own --dry-run flag from the arguments
+// meant for qbittorrent-nox.
+func initArgs(args []string) ([]string, bool) {
+	dry := getEnvBool("QBT_INIT_DRY_RUN", false)
+	end := len(args)
+	if i := slices.Index(args, "--"); i >= 0 {
+		end = i
+	}
+	if i := slices.Index(args[:end], "--dry-run"); i >= 0 {
+		args = slices.Delete(slices.Clone(args), i, i+1)
+		dry = true
+	}
+	return args, dry
+}
+
+// runDryRun validates everything a real start would and prints the config,
+// command line and environment qbittorrent-nox would get, without writing
+// anything or starting it.
+func runDryRun(rawArgs []string) error {
+	var problems []error
+
+	if err := preflightBinary(); err != nil {
+		problems = append(problems, fmt.Errorf("preflight: %w", err))
+	}
+
+	conf, state, err := dryRunConfig(defaultConfigPath)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("config: %w", err))
+	}
+
+	safeArgs, err := qbittorrentArgs(rawArgs)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("arguments: %w", err))
+	}
+	if _, err := speedSchedulePrefs(); err != nil {
+		problems = append(problems, fmt.Errorf("speed schedule: %w", err))
+	}
+	if _, err := newAuthBanner(); err != nil {
+		problems = append(problems, fmt.Errorf("login bans: %w", err))
+	}
+	if _, err := newGateway(); err != nil {
+		problems = append(problems, fmt.Errorf("gateway: %w", err))
+	}
+	name, args := hardenedCommand(qbittorrentBinary, safeArgs)
+	env := childEnv(os.Environ())
+
+	out := os.Stdout
+	fmt.Fprintf(out, "# Configuration: %s (%s)\n", defaultConfigPath, state)
+	if conf != nil {
+		for _, line := range strings.Split(strings.TrimRight(string(redactedINI(conf).bytes()), "\n"), "\n") {
+			fmt.Fprintln(out, line)
+		}
+	}
+	fmt.Fprintf(out, "\n# Command\n%s\n", strings.Join(append([]string{name}, args...), " "))
+	fmt.Fprintln(out, "\n# Environment")
+	deny := splitList(defaultEnvDeny)
+	for _, kv := range env {
+		key, value, _ := strings.Cut(kv, "=")
+		if matchesAny(key, deny) && value != "" {
+			value = "[redacted]"
+		}
+		fmt.Fprintf(out, "%s=%s\n", key, value)
+	}
+
+	if len(problems) > 0 {
+		fmt.Fprintln(out, "\n# Problems")
+		for _, p := range problems {
+			fmt.Fprintln(out, p)
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// dryRunConfig returns the configuration as qBittorrent would see it: the
+// initial template if none exists yet, otherwise the file with pending
+// migrations applied in memory.
+func dryRunConfig(path string) (*iniFile, string, error) {
+	conf, err := readINIFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		conf, err := initialConfig()
+		if err != nil {
+			return nil, "would be written from the template", err
+		}
+		_, err = applyEnvSettings(conf)
+		return conf, "would be written from the template", err
+	}
+	if err != nil {
+		return nil, "unreadable", err
+	}
+	if _, err := applyEnvSettings(conf); err != nil {
+		return conf, "existing", err
+	}
+
+	versions, err := qbittorrentVersions()
+	if err != nil || !getEnvBool("QBT_CONFIG_MIGRATION_ENABLED", true) {
+		return conf, "existing", nil
+	}
+	if changed := applyConfMigrations(conf, versions.qbittorrent); changed > 0 {
+		return conf, fmt.Sprintf("existing, %d migrations would be applied", changed), nil
+	}
+	return conf, "existing", nil
+}
+
+func redactedINI(f *iniFile) *iniFile {
+	out := &iniFile{}
+	for _, s := range f.sections {
+		section := &iniSection{name: s.name}
+		for _, e := range s.entries {
+			entry := *e
+			if entry.key != "" {
+				entry.value = redactConfigValue(entry.key, entry.value)
+			}
+			section.entries = append(section.entries, &entry)
+		}
+		out.sections = append(out.sections, section)
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// Secrets the initializer and the notifier read that qBittorrent does not
+// need. Anything qBittorrent can see is readable from /proc/<pid>/environ
+// and by every program it runs. CONFIG_YAML is denied as a whole, since it
+// may hold any of them.
+const defaultEnvDeny = "*PASSWORD*,*SECRET*,*TOKEN*,*API_KEY*,*ACCESS_KEY*,*USER_KEY*,*_WEBHOOK_URL,QBT_BACKUP_UPLOAD_URL,CONFIG_YAML"
+
+// childEnv returns the environment for qbittorrent-nox. With QBT_ENV_SCRUB
+// enabled, variables matching QBT_ENV_DENY (glob patterns, defaulting to
+// common secret names) are removed unless they also match QBT_ENV_ALLOW.
+// Setting QBT_ENV_ALLOW_ONLY passes only the variables matching
+// QBT_ENV_ALLOW. Scrubbing is off by default because the notifier, when run
+// from qBittorrent's "run external program", reads its Pushover and
+// cross-seed keys from this environment; enable it together with
+// QBT_ENV_ALLOW=PUSHOVER_*,CROSS_SEED_* or when the notifier runs as a daemon.
+func childEnv(environ []string) []string {
+	if !getEnvBool("QBT_ENV_SCRUB", false) {
+		return environ
+	}
+
+	deny := splitList(getEnv("QBT_ENV_DENY", defaultEnvDeny))
+	allow := splitList(os.Getenv("QBT_ENV_ALLOW"))
+	allowOnly := getEnvBool("QBT_ENV_ALLOW_ONLY", false)
+
+	var env, removed []string
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		keep := matchesAny(name, allow) || (!allowOnly && !matchesAny(name, deny))
+		if allowOnly && isBaseEnv(name) {
+			keep = true
+		}
+		if keep {
+			env = append(env, kv)
+		} else {
+			removed = append(removed, name)
+		}
+	}
+
+	if len(removed) > 0 {
+		log.Info("Removed variables from qBittorrent's environment", "variables", removed)
+	}
+	return env
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isBaseEnv reports whether a variable is always passed in allow-only mode,
+// since qBittorrent cannot run sensibly without it.
+func isBaseEnv(name string) bool {
+	switch name {
+	case "PATH", "HOME", "USER", "TZ", "LANG", "TMPDIR", "XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_CACHE_HOME":
+		return true
+	}
+	return strings.HasPrefix(name, "LC_")
+}
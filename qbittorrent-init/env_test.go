@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHardenedExecEnvironment follows the environment from the initializer
+// through the harden-exec child, which hands it to qbittorrent-nox as is.
+func TestHardenedExecEnvironment(t *testing.T) {
+	secrets := t.TempDir()
+	if err := os.WriteFile(filepath.Join(secrets, "PUSHOVER_TOKEN"), []byte("from-secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("QBT_ENV_SCRUB", "true")
+	// The notifier, run by qBittorrent, may read its own keys from there.
+	t.Setenv("QBT_ENV_ALLOW", "SECRETS_DIR")
+	t.Setenv("SECRETS_DIR", secrets)
+	t.Setenv("CONFIG_YAML", "qbt_webui_password: from-yaml\n")
+	t.Setenv("QBT_PASSWORD", "from-env")
+
+	child := childEnv(os.Environ())
+
+	original := os.Environ()
+	t.Cleanup(func() {
+		os.Clearenv()
+		setEnviron(original)
+	})
+	os.Clearenv()
+	setEnviron(child)
+
+	if err := loadConfiguration([]string{hardenedExecCommand, qbittorrentBinary}); err != nil {
+		t.Fatal(err)
+	}
+	deny := splitList(defaultEnvDeny)
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); matchesAny(name, deny) && name != "SECRETS_DIR" {
+			t.Errorf("%s reaches qbittorrent-nox", name)
+		}
+	}
+
+}
+
+func setEnviron(environ []string) {
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		os.Setenv(name, value)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+func runExportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	dir := flags.String("dir", "", "directory to write .torrent files into")
+	nameTemplate := flags.String("name", "{name} [{hash}].torrent", "file name template ({name}, {hash}, {category}, {tracker})")
+	filter := flags.String("filter", "", "qBittorrent status filter (e.g. completed, seeding)")
+	category := flags.String("category", "", "only export torrents in this category")
+	tag := flags.String("tag", "", "only export torrents with this tag")
+	overwrite := flags.Bool("overwrite", false, "overwrite existing files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return errors.New("usage: qbittorrent-init export --dir <path> [--name template] [--filter f] [--category c] [--tag t]")
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	query := url.Values{}
+	if *filter != "" {
+		query.Set("filter", *filter)
+	}
+	if *category != "" {
+		query.Set("category", *category)
+	}
+	if *tag != "" {
+		query.Set("tag", *tag)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	client := newWebUIClient()
+	torrents, err := client.torrents(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	var exported, skipped, failed int
+	for _, t := range torrents {
+		target := filepath.Join(*dir, exportFileName(*nameTemplate, t))
+		if _, err := os.Stat(target); err == nil && !*overwrite {
+			skipped++
+			continue
+		}
+
+		data, err := client.exportTorrent(ctx, t.Hash)
+		if err != nil {
+			log.Warn("Failed to export torrent", "name", t.Name, "hash", t.Hash, "error", err)
+			failed++
+			continue
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		exported++
+	}
+
+	log.Info("Torrent export completed",
+		"dir", *dir,
+		"exported", exported,
+		"skipped", skipped,
+		"failed", failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d torrents failed to export", failed)
+	}
+	return nil
+}
+
+func exportFileName(template string, t torrentInfo) string {
+	name := strings.NewReplacer(
+		"{name}", t.Name,
+		"{hash}", t.Hash,
+		"{category}", t.Category,
+		"{tracker}", trackerHost(t.Tracker),
+	).Replace(template)
+	return sanitizeFileName(name)
+}
+
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', 0:
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(name, ".")
+	if len(name) > 240 {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		// Cut at a rune boundary so multi-byte names stay valid UTF-8.
+		cut := 240 - len(ext)
+		for cut > 0 && !utf8.RuneStart(name[cut]) {
+			cut--
+		}
+		name = name[:cut] + ext
+	}
+	if name == "" {
+		name = "_"
+	}
+	return name
+}
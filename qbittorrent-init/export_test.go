@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFileNameTruncatesAtRuneBoundary(t *testing.T) {
+	// Three-byte runes put the 240 byte cut in the middle of one.
+	name := strings.Repeat("音", 100) + " [abcdef].torrent"
+	got := sanitizeFileName(name)
+	if len(got) > 240 {
+		t.Errorf("len = %d, want at most 240", len(got))
+	}
+	if !utf8.ValidString(got) {
+		t.Errorf("%q is not valid UTF-8", got)
+	}
+	if !strings.HasSuffix(got, ".torrent") {
+		t.Errorf("%q lost its extension", got)
+	}
+}
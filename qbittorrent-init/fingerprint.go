@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+const configFingerprintPath = "/config/qBittorrent/.config-fingerprint"
+
+// checkConfigFingerprint warns when the config no longer matches the hash
+// recorded after init last wrote it or qBittorrent last exited, meaning it
+// was edited by hand or by something that shouldn't touch it. After an
+// unclean shutdown qBittorrent may have saved the file without a new
+// fingerprint being recorded, so the check is skipped.
+func checkConfigFingerprint(configPath string) {
+	if !getEnvBool("QBT_CONFIG_FINGERPRINT_ENABLED", true) {
+		return
+	}
+	data, err := os.ReadFile(configFingerprintPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("Failed to read config fingerprint", "path", configFingerprintPath, "error", err)
+		}
+		return
+	}
+	if _, err := os.Stat(runningMarkerPath); err == nil {
+		log.Debug("Previous run did not shut down cleanly, skipping config fingerprint check")
+		return
+	}
+
+	current, err := fileSHA256(configPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("Failed to hash config file", "path", configPath, "error", err)
+		}
+		return
+	}
+	if recorded := strings.TrimSpace(string(data)); current != recorded {
+		log.Warn("Configuration file was modified outside of qbittorrent-init and qBittorrent",
+			"path", configPath,
+			"recorded_sha256", recorded,
+			"current_sha256", current)
+	}
+}
+
+// recordConfigFingerprint stores the hash of the config as known-good.
+func recordConfigFingerprint(configPath string) {
+	if !getEnvBool("QBT_CONFIG_FINGERPRINT_ENABLED", true) {
+		return
+	}
+	sum, err := fileSHA256(configPath)
+	if err == nil {
+		err = os.WriteFile(configFingerprintPath, []byte(sum+"\n"), 0o600)
+	}
+	if err != nil {
+		log.Warn("Failed to record config fingerprint", "path", configPath, "error", err)
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gatewaySessionCookie = "qbt_gateway_session"
+	gatewayLogoutPath    = "/gateway/logout"
+)
+
+// gatewayIdentity is an authenticated gateway user.
+type gatewayIdentity struct {
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// gatewayAuthenticator authenticates a request. When it returns false it has
+// already written the response, such as a login redirect or an error.
+type gatewayAuthenticator interface {
+	authenticate(w http.ResponseWriter, r *http.Request) (*gatewayIdentity, bool)
+}
+
+// gateway is a reverse proxy in front of the WebUI that requires trusted
+// header or OIDC authentication before passing requests on. qBittorrent sees
+// them coming from localhost, so it bypasses its own login unless
+// LocalHostAuth is enabled, in which case the gateway logs in with the same
+// credentials as the WebUI client and injects the session cookie itself.
+type gateway struct {
+	addr          string
+	target        *url.URL
+	auth          gatewayAuthenticator
+	allowedUsers  []string
+	allowedGroups []string
+	qbtUsername   string
+	qbtPassword   string
+	// qbtPasswordFile is re-read on each login so it follows rotation.
+	qbtPasswordFile string
+	httpClient      *http.Client
+	bans            *authBanner
+
+	mu  sync.Mutex
+	sid string
+}
+
+// newGateway returns the gateway configured by QBT_GATEWAY_ADDR, or nil if
+// it is not enabled.
+func newGateway() (*gateway, error) {
+	addr := os.Getenv("QBT_GATEWAY_ADDR")
+	if addr == "" {
+		return nil, nil
+	}
+	client := newWebUIClient()
+	target, err := url.Parse(client.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebUI URL: %w", err)
+	}
+
+	passwordFile := getEnvAlias("QBT_PASSWORD_FILE", "QBT_GATEWAY_QBT_PASSWORD_FILE", "QBT_WEBUI_PASSWORD_FILE")
+	if passwordFile == "" {
+		passwordFile = client.passwordFile
+	}
+	g := &gateway{
+		addr:            addr,
+		target:          target,
+		allowedUsers:    splitList(os.Getenv("QBT_GATEWAY_ALLOWED_USERS")),
+		allowedGroups:   splitList(os.Getenv("QBT_GATEWAY_ALLOWED_GROUPS")),
+		qbtUsername:     getEnvAlias("QBT_USERNAME", "QBT_GATEWAY_QBT_USERNAME", "QBT_WEBUI_USERNAME"),
+		qbtPassword:     getEnvAlias("QBT_PASSWORD", "QBT_GATEWAY_QBT_PASSWORD", "QBT_WEBUI_PASSWORD"),
+		qbtPasswordFile: passwordFile,
+		httpClient:      &http.Client{Timeout: 10 * time.Second},
+	}
+
+	switch mode := getEnv("QBT_GATEWAY_AUTH", "header"); mode {
+	case "header":
+		g.auth, err = newHeaderAuth()
+	case "oidc":
+		var sessions *sessionCodec
+		if sessions, err = newSessionCodec(); err == nil {
+			g.auth, err = newOIDCAuth(sessions)
+		}
+	default:
+		err = fmt.Errorf("unknown QBT_GATEWAY_AUTH %q: expected header or oidc", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *gateway) start(ctx context.Context) {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(g.target)
+			setRequestCookies(pr.Out, func(c *http.Cookie) bool {
+				return c.Name != "SID" && !strings.HasPrefix(c.Name, "qbt_gateway_")
+			})
+			if sid, _ := pr.In.Context().Value(gatewaySIDKey{}).(string); sid != "" {
+				pr.Out.AddCookie(&http.Cookie{Name: "SID", Value: sid})
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.StatusCode == http.StatusForbidden {
+				g.resetSID()
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Debug("Gateway request to WebUI failed", "path", r.URL.Path, "error", err)
+			http.Error(w, "qBittorrent is not available", http.StatusBadGateway)
+		},
+	}
+
+	srv := &http.Server{
+		Addr:              g.addr,
+		Handler:           g.handler(proxy),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		log.Info("Starting WebUI gateway", "addr", g.addr, "target", g.target.String())
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("WebUI gateway failed", "error", err)
+		}
+	}()
+}
+
+type gatewaySIDKey struct{}
+
+func (g *gateway) handler(proxy http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if remote, err := netip.ParseAddrPort(r.RemoteAddr); err == nil && g.bans != nil && g.bans.isBanned(remote.Addr()) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		id, ok := g.auth.authenticate(w, r)
+		if !ok {
+			return
+		}
+		if !g.allowed(id) {
+			log.Warn("Gateway denied user", "user", id.User, "remote", r.RemoteAddr)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if g.qbtUsername != "" {
+			sid, err := g.session(r.Context())
+			if err != nil {
+				log.Error("Gateway failed to log in to qBittorrent", "error", err)
+				http.Error(w, "qBittorrent login failed", http.StatusBadGateway)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), gatewaySIDKey{}, sid))
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+func (g *gateway) allowed(id *gatewayIdentity) bool {
+	if len(g.allowedUsers) == 0 && len(g.allowedGroups) == 0 {
+		return true
+	}
+	if slices.Contains(g.allowedUsers, id.User) {
+		return true
+	}
+	for _, group := range id.Groups {
+		if slices.Contains(g.allowedGroups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// session returns the qBittorrent session ID, logging in if there is none.
+func (g *gateway) session(ctx context.Context) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.sid != "" {
+		return g.sid, nil
+	}
+
+	password, err := loginPassword(g.qbtPassword, g.qbtPasswordFile)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{"username": {g.qbtUsername}, "password": {password}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.target.JoinPath("/api/v2/auth/login").String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", g.target.String())
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1024))
+
+	for _, c := range resp.Cookies() {
+		if c.Name == "SID" && c.Value != "" {
+			g.sid = c.Value
+			return g.sid, nil
+		}
+	}
+	return "", fmt.Errorf("login rejected with status %d", resp.StatusCode)
+}
+
+func (g *gateway) resetSID() {
+	g.mu.Lock()
+	g.sid = ""
+	g.mu.Unlock()
+}
+
+// setRequestCookies rewrites the Cookie header of r to the cookies keep
+// accepts.
+func setRequestCookies(r *http.Request, keep func(*http.Cookie) bool) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if keep(c) {
+			r.AddCookie(c)
+		}
+	}
+}
+
+// headerAuth trusts the user and group headers set by a forward-auth proxy
+// such as Authelia or authentik, but only on requests from
+// QBT_GATEWAY_TRUSTED_PROXIES.
+type headerAuth struct {
+	trusted      []netip.Prefix
+	userHeader   string
+	groupsHeader string
+}
+
+func newHeaderAuth() (*headerAuth, error) {
+	entries := splitList(os.Getenv("QBT_GATEWAY_TRUSTED_PROXIES"))
+	if len(entries) == 0 {
+		return nil, errors.New("QBT_GATEWAY_TRUSTED_PROXIES is required for header authentication")
+	}
+	a := &headerAuth{
+		userHeader:   getEnv("QBT_GATEWAY_USER_HEADER", "Remote-User"),
+		groupsHeader: getEnv("QBT_GATEWAY_GROUPS_HEADER", "Remote-Groups"),
+	}
+	for _, entry := range entries {
+		prefix, err := parsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid QBT_GATEWAY_TRUSTED_PROXIES: %w", err)
+		}
+		a.trusted = append(a.trusted, prefix)
+	}
+	return a, nil
+}
+
+func (a *headerAuth) authenticate(w http.ResponseWriter, r *http.Request) (*gatewayIdentity, bool) {
+	remote, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil || !slices.ContainsFunc(a.trusted, func(p netip.Prefix) bool {
+		return p.Contains(remote.Addr().Unmap())
+	}) {
+		log.Warn("Gateway request from untrusted address", "remote", r.RemoteAddr)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	user := strings.TrimSpace(r.Header.Get(a.userHeader))
+	if user == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return &gatewayIdentity{User: user, Groups: splitList(r.Header.Get(a.groupsHeader))}, true
+}
+
+// sessionCodec signs and verifies cookie values with an HMAC key from
+// QBT_GATEWAY_SESSION_SECRET, or a random key that invalidates sessions on
+// restart if none is set.
+type sessionCodec struct {
+	key []byte
+	ttl time.Duration
+}
+
+func newSessionCodec() (*sessionCodec, error) {
+	key := []byte(os.Getenv("QBT_GATEWAY_SESSION_SECRET"))
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate session key: %w", err)
+		}
+	} else if len(key) < 32 {
+		return nil, errors.New("QBT_GATEWAY_SESSION_SECRET must be at least 32 characters")
+	}
+	return &sessionCodec{key: key, ttl: getEnvDuration("QBT_GATEWAY_SESSION_TTL", 12*time.Hour)}, nil
+}
+
+type signedValue struct {
+	Expires int64           `json:"exp"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// encode signs v for purpose, so a value issued for one cookie can't be
+// passed off as another.
+func (c *sessionCodec) encode(purpose string, v any, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(signedValue{Expires: time.Now().Add(ttl).Unix(), Data: data})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.sign(purpose, encoded)), nil
+}
+
+func (c *sessionCodec) decode(purpose, value string, v any) bool {
+	encoded, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, c.sign(purpose, encoded)) {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	var signed signedValue
+	if json.Unmarshal(payload, &signed) != nil || time.Now().Unix() >= signed.Expires {
+		return false
+	}
+	return json.Unmarshal(signed.Data, v) == nil
+}
+
+func (c *sessionCodec) sign(purpose, s string) []byte {
+	h := hmac.New(sha256.New, c.key)
+	h.Write([]byte(purpose + "\x00" + s))
+	return h.Sum(nil)
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gatewayStateCookie = "qbt_gateway_oidc"
+	oidcStateTTL       = 10 * time.Minute
+)
+
+// oidcAuth authenticates users with the OpenID Connect authorization code
+// flow and PKCE, then keeps them logged in with a signed session cookie.
+//
+// The ID token is taken straight from the token endpoint over TLS, which lets
+// its issuer be trusted without verifying the signature (OIDC Core 3.1.3.7).
+type oidcAuth struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirect     *url.URL
+	scopes       string
+	userClaim    string
+	groupsClaim  string
+	sessions     *sessionCodec
+	httpClient   *http.Client
+
+	mu        sync.Mutex
+	endpoints *oidcEndpoints
+}
+
+type oidcEndpoints struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+// oidcState is kept in a cookie between the login redirect and the callback.
+type oidcState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Return   string `json:"return"`
+}
+
+func newOIDCAuth(sessions *sessionCodec) (*oidcAuth, error) {
+	a := &oidcAuth{
+		issuer:       strings.TrimRight(os.Getenv("QBT_GATEWAY_OIDC_ISSUER"), "/"),
+		clientID:     os.Getenv("QBT_GATEWAY_OIDC_CLIENT_ID"),
+		clientSecret: os.Getenv("QBT_GATEWAY_OIDC_CLIENT_SECRET"),
+		scopes:       getEnv("QBT_GATEWAY_OIDC_SCOPES", "openid profile email groups"),
+		userClaim:    getEnv("QBT_GATEWAY_OIDC_USER_CLAIM", "preferred_username"),
+		groupsClaim:  getEnv("QBT_GATEWAY_OIDC_GROUPS_CLAIM", "groups"),
+		sessions:     sessions,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+	if a.issuer == "" || a.clientID == "" {
+		return nil, errors.New("QBT_GATEWAY_OIDC_ISSUER and QBT_GATEWAY_OIDC_CLIENT_ID are required for OIDC authentication")
+	}
+	redirect, err := url.Parse(os.Getenv("QBT_GATEWAY_OIDC_REDIRECT_URL"))
+	if err != nil || !redirect.IsAbs() || strings.Trim(redirect.Path, "/") == "" {
+		return nil, errors.New("QBT_GATEWAY_OIDC_REDIRECT_URL must be an absolute URL with a callback path")
+	}
+	a.redirect = redirect
+	return a, nil
+}
+
+func (a *oidcAuth) authenticate(w http.ResponseWriter, r *http.Request) (*gatewayIdentity, bool) {
+	switch r.URL.Path {
+	case a.redirect.Path:
+		a.callback(w, r)
+		return nil, false
+	case gatewayLogoutPath:
+		a.setCookie(w, gatewaySessionCookie, "", -1)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return nil, false
+	}
+
+	if c, err := r.Cookie(gatewaySessionCookie); err == nil {
+		var id gatewayIdentity
+		if a.sessions.decode(gatewaySessionCookie, c.Value, &id) && id.User != "" {
+			return &id, true
+		}
+	}
+
+	// API clients can't follow a login redirect.
+	if r.Method != http.MethodGet || strings.HasPrefix(r.URL.Path, "/api/") {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	a.login(w, r)
+	return nil, false
+}
+
+func (a *oidcAuth) login(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := a.discover(r.Context())
+	if err != nil {
+		log.Error("OIDC discovery failed", "issuer", a.issuer, "error", err)
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	state := oidcState{
+		State:    randomToken(),
+		Nonce:    randomToken(),
+		Verifier: randomToken(),
+		Return:   r.URL.RequestURI(),
+	}
+	value, err := a.sessions.encode(gatewayStateCookie, state, oidcStateTTL)
+	if err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	a.setCookie(w, gatewayStateCookie, value, oidcStateTTL)
+
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {a.clientID},
+		"redirect_uri":          {a.redirect.String()},
+		"scope":                 {a.scopes},
+		"state":                 {state.State},
+		"nonce":                 {state.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	http.Redirect(w, r, endpoints.AuthorizationEndpoint+"?"+query.Encode(), http.StatusFound)
+}
+
+func (a *oidcAuth) callback(w http.ResponseWriter, r *http.Request) {
+	var state oidcState
+	c, err := r.Cookie(gatewayStateCookie)
+	if err != nil || !a.sessions.decode(gatewayStateCookie, c.Value, &state) || r.URL.Query().Get("state") != state.State {
+		http.Error(w, "Invalid login state, please try again", http.StatusBadRequest)
+		return
+	}
+	a.setCookie(w, gatewayStateCookie, "", -1)
+
+	if e := r.URL.Query().Get("error"); e != "" {
+		log.Warn("OIDC login failed", "error", e, "description", r.URL.Query().Get("error_description"))
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := a.exchange(r.Context(), r.URL.Query().Get("code"), state)
+	if err != nil {
+		log.Warn("OIDC login failed", "error", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	value, err := a.sessions.encode(gatewaySessionCookie, id, a.sessions.ttl)
+	if err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	a.setCookie(w, gatewaySessionCookie, value, a.sessions.ttl)
+	log.Info("Gateway user logged in", "user", id.User)
+
+	target := state.Return
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") {
+		target = "/"
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// exchange redeems the authorization code and returns the identity from the
+// ID token after checking its issuer, audience, expiry and nonce.
+func (a *oidcAuth) exchange(ctx context.Context, code string, state oidcState) (*gatewayIdentity, error) {
+	endpoints, err := a.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {a.redirect.String()},
+		"code_verifier": {state.Verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil || tokens.IDToken == "" {
+		return nil, errors.New("token response has no ID token")
+	}
+	parts := strings.Split(tokens.IDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed ID token: %w", err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed ID token: %w", err)
+	}
+
+	if iss, _ := claims["iss"].(string); iss != endpoints.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !slices.Contains(stringClaims(claims["aud"]), a.clientID) {
+		return nil, errors.New("ID token is not for this client")
+	}
+	if exp, _ := claims["exp"].(float64); time.Now().Unix() >= int64(exp) {
+		return nil, errors.New("ID token has expired")
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != state.Nonce {
+		return nil, errors.New("ID token nonce does not match")
+	}
+
+	user, _ := claims[a.userClaim].(string)
+	if user == "" {
+		user, _ = claims["sub"].(string)
+	}
+	if user == "" {
+		return nil, errors.New("ID token has no subject")
+	}
+	return &gatewayIdentity{User: user, Groups: stringClaims(claims[a.groupsClaim])}, nil
+}
+
+// discover fetches the provider's endpoints on first use, retrying on later
+// requests if it failed.
+func (a *oidcAuth) discover(ctx context.Context) (*oidcEndpoints, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.endpoints != nil {
+		return a.endpoints, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var endpoints oidcEndpoints
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&endpoints); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery document: %w", err)
+	}
+	if strings.TrimRight(endpoints.Issuer, "/") != a.issuer {
+		return nil, fmt.Errorf("discovery document is for issuer %q", endpoints.Issuer)
+	}
+	if endpoints.AuthorizationEndpoint == "" || endpoints.TokenEndpoint == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+	a.endpoints = &endpoints
+	return a.endpoints, nil
+}
+
+func (a *oidcAuth) setCookie(w http.ResponseWriter, name, value string, ttl time.Duration) {
+	maxAge := int(ttl.Seconds())
+	if ttl < 0 {
+		maxAge = -1
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   a.redirect.Scheme == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// stringClaims returns a claim that may be a single string or a list.
+func stringClaims(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	prSetSeccomp         = 22
+	prCapBSetDrop        = 24
+	prSetNoNewPrivs      = 38
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+	seccompModeFilter    = 2
+
+	seccompRetAllow = 0x7fff0000
+	seccompRetErrno = 0x00050000
+
+	linuxCapabilityVersion3 = 0x20080522
+)
+
+var capabilityNames = []string{
+	"CHOWN", "DAC_OVERRIDE", "DAC_READ_SEARCH", "FOWNER", "FSETID", "KILL",
+	"SETGID", "SETUID", "SETPCAP", "LINUX_IMMUTABLE", "NET_BIND_SERVICE",
+	"NET_BROADCAST", "NET_ADMIN", "NET_RAW", "IPC_LOCK", "IPC_OWNER",
+	"SYS_MODULE", "SYS_RAWIO", "SYS_CHROOT", "SYS_PTRACE", "SYS_PACCT",
+	"SYS_ADMIN", "SYS_BOOT", "SYS_NICE", "SYS_RESOURCE", "SYS_TIME",
+	"SYS_TTY_CONFIG", "MKNOD", "LEASE", "AUDIT_WRITE", "AUDIT_CONTROL",
+	"SETFCAP", "MAC_OVERRIDE", "MAC_ADMIN", "SYSLOG", "WAKE_ALARM",
+	"BLOCK_SUSPEND", "AUDIT_READ", "PERFMON", "BPF", "CHECKPOINT_RESTORE",
+}
+
+// hardenedCommand wraps qbittorrent-nox in the harden-exec subcommand, which
+// restricts its own thread and then execs the binary in place. Hardening the
+// initializer itself is avoided, since its maintenance jobs keep running.
+func hardenedCommand(binary string, args []string) (string, []string) {
+	if !getEnvBool("QBT_HARDENING_ENABLED", true) {
+		return binary, args
+	}
+	self, err := os.Executable()
+	if err != nil {
+		log.Warn("Cannot locate own executable, starting qBittorrent without hardening", "error", err)
+		return binary, args
+	}
+	return self, append([]string{hardenedExecCommand, binary}, args...)
+}
+
+func runHardenedExec(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: harden-exec <binary> [args...]")
+	}
+	keep, err := parseCapabilities(os.Getenv("QBT_KEEP_CAPS"))
+	if err != nil {
+		return err
+	}
+
+	// Everything below applies to this thread only; execve replaces the
+	// process with it.
+	runtime.LockOSThread()
+
+	prctl(prCapAmbient, prCapAmbientClearAll, 0)
+	dropped, err := dropBoundingCapabilities(keep)
+	if err != nil {
+		return err
+	}
+	if err := limitCapabilities(keep); err != nil {
+		return err
+	}
+	if err := prctl(prSetNoNewPrivs, 1, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+
+	seccomp := getEnvBool("QBT_SECCOMP_ENABLED", false)
+	if seccomp {
+		if err := installSeccompFilter(); err != nil {
+			return err
+		}
+	}
+
+	log.Info("Applied process hardening",
+		"no_new_privs", true,
+		"dropped_bounding_caps", dropped,
+		"kept_caps", capabilityList(keep),
+		"seccomp", seccomp)
+
+	if err := syscall.Exec(args[0], args, os.Environ()); err != nil {
+		return fmt.Errorf("failed to exec %s: %w", args[0], err)
+	}
+	return nil
+}
+
+func prctl(option, arg2, arg3 uintptr) error {
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, option, arg2, arg3, 0, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// parseCapabilities parses QBT_KEEP_CAPS, e.g. "NET_BIND_SERVICE" or
+// "cap_chown,cap_fowner", into a bit mask.
+func parseCapabilities(spec string) (uint64, error) {
+	var mask uint64
+	for _, name := range splitList(spec) {
+		name = strings.TrimPrefix(strings.ToUpper(name), "CAP_")
+		idx := slices.Index(capabilityNames, name)
+		if idx < 0 {
+			return 0, fmt.Errorf("unknown capability %q in QBT_KEEP_CAPS", name)
+		}
+		mask |= 1 << idx
+	}
+	return mask, nil
+}
+
+func capabilityList(mask uint64) []string {
+	var names []string
+	for i, name := range capabilityNames {
+		if mask&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// dropBoundingCapabilities removes every capability not kept from the
+// bounding set, so that not even a root qBittorrent can regain it. Without
+// CAP_SETPCAP the set cannot be changed; that only happens for non-root
+// users, whose capabilities are cleared by execve anyway.
+func dropBoundingCapabilities(keep uint64) (int, error) {
+	last := len(capabilityNames) - 1
+	if data, err := os.ReadFile("/proc/sys/kernel/cap_last_cap"); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && n < 64 {
+			last = n
+		}
+	}
+
+	dropped := 0
+	for c := 0; c <= last; c++ {
+		if keep&(1<<c) != 0 {
+			continue
+		}
+		switch err := prctl(prCapBSetDrop, uintptr(c), 0); {
+		case err == nil:
+			dropped++
+		case errors.Is(err, syscall.EPERM):
+			log.Debug("Not permitted to change the capability bounding set")
+			return dropped, nil
+		default:
+			return dropped, fmt.Errorf("failed to drop capability %d: %w", c, err)
+		}
+	}
+	return dropped, nil
+}
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+// limitCapabilities reduces the effective, permitted and inheritable sets to
+// the kept capabilities.
+func limitCapabilities(keep uint64) error {
+	hdr := capHeader{version: linuxCapabilityVersion3}
+	var data [2]capData
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPGET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("capget failed: %w", errno)
+	}
+	for i := range data {
+		m := uint32(keep >> (32 * i))
+		data[i].effective &= m
+		data[i].permitted &= m
+		data[i].inheritable &= m
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("capset failed: %w", errno)
+	}
+	return nil
+}
+
+// installSeccompFilter denies, with EPERM, system calls a BitTorrent client
+// has no use for: mounting, module loading, tracing other processes, keyring
+// and namespace manipulation, and the like. Everything else is allowed.
+func installSeccompFilter() error {
+	if seccompAuditArch == 0 {
+		return fmt.Errorf("seccomp filter is not supported on %s", runtime.GOARCH)
+	}
+
+	const (
+		ldAbs  = 0x00 | 0x00 | 0x20 // BPF_LD | BPF_W | BPF_ABS
+		jeqK   = 0x05 | 0x10 | 0x00 // BPF_JMP | BPF_JEQ | BPF_K
+		jgeK   = 0x05 | 0x30 | 0x00 // BPF_JMP | BPF_JGE | BPF_K
+		retK   = 0x06 | 0x00        // BPF_RET | BPF_K
+		x32Bit = 0x40000000
+	)
+
+	nrs := make([]uint32, 0, len(seccompDeniedSyscalls))
+	for _, nr := range seccompDeniedSyscalls {
+		nrs = append(nrs, nr)
+	}
+	slices.Sort(nrs)
+
+	// Layout: arch check, syscall number checks, allow, deny.
+	deny := 5 + len(nrs)
+	filter := []syscall.SockFilter{
+		{Code: ldAbs, K: 4}, // seccomp_data.arch
+		{Code: jeqK, Jt: 0, Jf: uint8(deny - 2), K: seccompAuditArch},
+		{Code: ldAbs, K: 0}, // seccomp_data.nr
+		{Code: jgeK, Jt: uint8(deny - 4), Jf: 0, K: x32Bit},
+	}
+	for _, nr := range nrs {
+		filter = append(filter, syscall.SockFilter{Code: jeqK, Jt: uint8(deny - len(filter) - 1), K: nr})
+	}
+	filter = append(filter,
+		syscall.SockFilter{Code: retK, K: seccompRetAllow},
+		syscall.SockFilter{Code: retK, K: seccompRetErrno | uint32(syscall.EPERM)},
+	)
+
+	prog := syscall.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := prctl(prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&prog))); err != nil {
+		return fmt.Errorf("failed to install seccomp filter: %w", err)
+	}
+	runtime.KeepAlive(filter)
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func hardenedCommand(binary string, args []string) (string, []string) {
+	return binary, args
+}
+
+func runHardenedExec(args []string) error {
+	return errors.New("process hardening is only supported on Linux")
+}
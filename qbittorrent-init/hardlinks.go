@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+func torrentHasExtraHardlinks(ctx context.Context, client *webUIClient, t torrentInfo) (bool, error) {
+	files, err := client.torrentFiles(ctx, t.Hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	for _, f := range files {
+		info, err := os.Stat(filepath.Join(t.SavePath, f.Name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return false, err
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Nlink > 1 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type hardlinkTagger struct {
+	linkedTag  string
+	noHLTag    string
+	missingTag string
+}
+
+func newHardlinkTagger() *hardlinkTagger {
+	return &hardlinkTagger{
+		linkedTag:  getEnv("QBT_HARDLINK_TAG", "hardlinked"),
+		noHLTag:    getEnv("QBT_NOHL_TAG", "noHL"),
+		missingTag: getEnv("QBT_MISSING_TAG", "missing"),
+	}
+}
+
+func (h *hardlinkTagger) run(ctx context.Context, client *webUIClient) error {
+	torrents, err := client.torrents(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	desired := map[string]map[string]bool{
+		h.linkedTag:  {},
+		h.noHLTag:    {},
+		h.missingTag: {},
+	}
+
+	// complete holds the torrents whose tags are reconciled. One whose
+	// check failed is left out, so it keeps the tags it has.
+	var complete []torrentInfo
+	for _, t := range torrents {
+		if t.Progress < 1 {
+			continue
+		}
+
+		if t.State == "missingFiles" {
+			complete = append(complete, t)
+			desired[h.missingTag][t.Hash] = true
+			continue
+		}
+
+		linked, err := torrentHasExtraHardlinks(ctx, client, t)
+		if err != nil {
+			log.Warn("Hardlink check failed, keeping current tags", "hash", t.Hash, "error", err)
+			continue
+		}
+		complete = append(complete, t)
+		if linked {
+			desired[h.linkedTag][t.Hash] = true
+		} else {
+			desired[h.noHLTag][t.Hash] = true
+		}
+	}
+
+	for tag, want := range desired {
+		var add, remove []string
+		for _, t := range complete {
+			tagged := hasTag(t.Tags, tag)
+			switch {
+			case want[t.Hash] && !tagged:
+				add = append(add, t.Hash)
+			case !want[t.Hash] && tagged:
+				remove = append(remove, t.Hash)
+			}
+		}
+
+		if len(add) > 0 {
+			if err := client.addTags(ctx, add, tag); err != nil {
+				return fmt.Errorf("failed to add %s tag: %w", tag, err)
+			}
+		}
+		if len(remove) > 0 {
+			if err := client.removeTags(ctx, remove, tag); err != nil {
+				return fmt.Errorf("failed to remove %s tag: %w", tag, err)
+			}
+		}
+
+		log.Info("Link tagging completed", "tag", tag, "tagged", len(add), "untagged", len(remove))
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type iniEntry struct {
+	key   string
+	value string
+	raw   string
+}
+
+type iniSection struct {
+	name    string
+	entries []*iniEntry
+}
+
+type iniFile struct {
+	sections []*iniSection
+}
+
+func parseINI(data []byte) *iniFile {
+	f := &iniFile{}
+	current := &iniSection{}
+	f.sections = append(f.sections, current)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			current = &iniSection{name: trimmed[1 : len(trimmed)-1]}
+			f.sections = append(f.sections, current)
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || trimmed == "" || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") {
+			current.entries = append(current.entries, &iniEntry{raw: line})
+			continue
+		}
+		current.entries = append(current.entries, &iniEntry{key: strings.TrimSpace(key), value: value})
+	}
+	return f
+}
+
+func readINIFile(path string) (*iniFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseINI(data), nil
+}
+
+func (f *iniFile) section(name string, create bool) *iniSection {
+	for _, s := range f.sections {
+		if s.name == name {
+			return s
+		}
+	}
+	if !create {
+		return nil
+	}
+
+	if last := f.sections[len(f.sections)-1]; len(last.entries) > 0 {
+		if tail := last.entries[len(last.entries)-1]; tail.key != "" || strings.TrimSpace(tail.raw) != "" {
+			last.entries = append(last.entries, &iniEntry{})
+		}
+	}
+	s := &iniSection{name: name}
+	f.sections = append(f.sections, s)
+	return s
+}
+
+func (s *iniSection) find(key string) *iniEntry {
+	for _, e := range s.entries {
+		if e.key == key {
+			return e
+		}
+	}
+	return nil
+}
+
+func (f *iniFile) get(section, key string) (string, bool) {
+	s := f.section(section, false)
+	if s == nil {
+		return "", false
+	}
+	if e := s.find(key); e != nil {
+		return e.value, true
+	}
+	return "", false
+}
+
+func (f *iniFile) set(section, key, value string) {
+	s := f.section(section, true)
+	if e := s.find(key); e != nil {
+		e.value = value
+		return
+	}
+
+	insertAt := len(s.entries)
+	for insertAt > 0 && s.entries[insertAt-1].key == "" && strings.TrimSpace(s.entries[insertAt-1].raw) == "" {
+		insertAt--
+	}
+	s.entries = append(s.entries, nil)
+	copy(s.entries[insertAt+1:], s.entries[insertAt:])
+	s.entries[insertAt] = &iniEntry{key: key, value: value}
+}
+
+func (f *iniFile) delete(section, key string) bool {
+	s := f.section(section, false)
+	if s == nil {
+		return false
+	}
+	for i, e := range s.entries {
+		if e.key == key {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (f *iniFile) keys() []string {
+	var keys []string
+	for _, s := range f.sections {
+		for _, e := range s.entries {
+			if e.key != "" {
+				keys = append(keys, s.name+"/"+e.key)
+			}
+		}
+	}
+	return keys
+}
+
+func (f *iniFile) bytes() []byte {
+	var buf bytes.Buffer
+	for _, s := range f.sections {
+		if s.name != "" {
+			fmt.Fprintf(&buf, "[%s]\n", s.name)
+		}
+		for _, e := range s.entries {
+			if e.key == "" {
+				buf.WriteString(e.raw)
+			} else {
+				buf.WriteString(e.key + "=" + e.value)
+			}
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func (f *iniFile) writeFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".qbt-conf-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(f.bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set config permissions: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+//go:embed binaries.sha256
+var binaryManifest string
+
+// parseBinaryManifest reads sha256sum output into a map of path to checksum.
+func parseBinaryManifest(manifest string) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(manifest))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, path, ok := strings.Cut(line, " ")
+		path = strings.TrimPrefix(strings.TrimSpace(path), "*")
+		if !ok || path == "" || len(sum) != sha256.Size*2 || !isHex(sum) {
+			return nil, fmt.Errorf("invalid manifest line %d: %q", n, line)
+		}
+		sums[path] = strings.ToLower(sum)
+	}
+	return sums, scanner.Err()
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// binaryChecksums returns the checksums embedded at build time, or nil
+// unless QBT_INTEGRITY_CHECK enables verification. The check is opt-in until
+// the image build generates the manifest; once enabled, an empty manifest is
+// an error, so binaries are never run unverified by mistake.
+var binaryChecksums = sync.OnceValues(func() (map[string]string, error) {
+	if !getEnvBool("QBT_INTEGRITY_CHECK", false) {
+		log.Debug("Binary integrity verification is disabled")
+		return nil, nil
+	}
+	sums, err := parseBinaryManifest(binaryManifest)
+	if err != nil {
+		return nil, fmt.Errorf("embedded checksum manifest is corrupt: %w", err)
+	}
+	if len(sums) == 0 {
+		return nil, errors.New("QBT_INTEGRITY_CHECK is enabled but no checksums are embedded; build with a generated binaries.sha256")
+	}
+	return sums, nil
+})
+
+// verifyBinaries checks every bundled binary against the embedded
+// checksums, so a tampered layer or a binary mounted over the image is
+// caught before startup.
+func verifyBinaries() error {
+	sums, err := binaryChecksums()
+	if err != nil || sums == nil {
+		return err
+	}
+	for path := range sums {
+		if err := verifyBinary(path); err != nil {
+			return err
+		}
+	}
+	log.Info("Verified bundled binaries", "count", len(sums))
+	return nil
+}
+
+// verifyBinary checks path against the embedded checksums right before it
+// is executed. A binary the manifest does not list is refused.
+func verifyBinary(path string) error {
+	sums, err := binaryChecksums()
+	if err != nil || sums == nil {
+		return err
+	}
+	want, ok := sums[path]
+	if !ok {
+		return fmt.Errorf("%s is not covered by the embedded checksums", path)
+	}
+	got, err := fileSHA256(path)
+	if err != nil {
+		return fmt.Errorf("cannot verify %s: %w", path, err)
+	}
+	if got != want {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", path, want, got)
+	}
+	return nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
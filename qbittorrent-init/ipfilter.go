@@ -0,0 +1,154 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ipFilterUpdater struct {
+	sourceURL  string
+	path       string
+	httpClient *http.Client
+}
+
+// newIPFilterUpdater stores the list where qBittorrent expects its format,
+// since it picks the parser from the file extension: QBT_IPFILTER_FORMAT, or
+// the source URL's extension, selects dat, p2p or p2b.
+func newIPFilterUpdater() *ipFilterUpdater {
+	sourceURL := os.Getenv("QBT_IPFILTER_URL")
+	format := strings.ToLower(os.Getenv("QBT_IPFILTER_FORMAT"))
+	if format == "" {
+		format = "dat"
+		if u, err := url.Parse(sourceURL); err == nil {
+			switch ext := strings.TrimPrefix(path.Ext(strings.TrimSuffix(u.Path, ".gz")), "."); ext {
+			case "p2p", "p2b":
+				format = ext
+			}
+		}
+	}
+	return &ipFilterUpdater{
+		sourceURL:  sourceURL,
+		path:       getEnv("QBT_IPFILTER_PATH", filepath.Join(qbtConfigDir(), "ipfilter."+format)),
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func (u *ipFilterUpdater) run(ctx context.Context, client *webUIClient) error {
+	if u.sourceURL == "" {
+		return fmt.Errorf("QBT_IPFILTER_URL is not set")
+	}
+
+	changed, err := u.download(ctx)
+	if err != nil {
+		return err
+	}
+
+	prefs, err := client.preferences(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read preferences: %w", err)
+	}
+	enabled, _ := prefs["ip_filter_enabled"].(bool)
+	current, _ := prefs["ip_filter_path"].(string)
+	if enabled && current == u.path {
+		if !changed {
+			return nil
+		}
+		// qBittorrent only reads the list again when the path changes or
+		// the filter is switched on, so switch it off first.
+		if err := client.setPreferences(ctx, map[string]any{"ip_filter_enabled": false}); err != nil {
+			return fmt.Errorf("failed to reload IP filter: %w", err)
+		}
+	}
+
+	if err := client.setPreferences(ctx, map[string]any{
+		"ip_filter_enabled": true,
+		"ip_filter_path":    u.path,
+	}); err != nil {
+		return fmt.Errorf("failed to enable IP filter: %w", err)
+	}
+	log.Info("IP filter applied", "path", u.path, "updated", changed)
+	return nil
+}
+
+func (u *ipFilterUpdater) download(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.sourceURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	etagPath := u.path + ".etag"
+	if _, err := os.Stat(u.path); err == nil {
+		if etag, err := os.ReadFile(etagPath); err == nil && len(etag) > 0 {
+			req.Header.Set("If-None-Match", strings.TrimSpace(string(etag)))
+		}
+	}
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("blocklist download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		log.Debug("IP filter blocklist not modified", "url", u.sourceURL)
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var body io.Reader = resp.Body
+	if strings.HasSuffix(req.URL.Path, ".gz") || resp.Header.Get("Content-Type") == "application/gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return false, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	if err := os.MkdirAll(filepath.Dir(u.path), 0755); err != nil {
+		return false, fmt.Errorf("failed to create blocklist directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(u.path), ".ipfilter-*")
+	if err != nil {
+		return false, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to write blocklist: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return false, fmt.Errorf("failed to set blocklist permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), u.path); err != nil {
+		return false, fmt.Errorf("failed to install blocklist: %w", err)
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		if err := os.WriteFile(etagPath, []byte(etag), 0644); err != nil {
+			log.Warn("Failed to store blocklist ETag", "error", err)
+		}
+	} else {
+		os.Remove(etagPath)
+	}
+
+	log.Info("IP filter blocklist downloaded", "url", u.sourceURL, "path", u.path, "bytes", written)
+	return true, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubeEventReasons maps lifecycle events to Event reasons. Events not listed
+// are only sent to the webhook.
+var kubeEventReasons = map[string]string{
+	eventStarted:     "Started",
+	eventReady:       "Ready",
+	eventPortChanged: "PortChanged",
+	eventRestarted:   "Restarted",
+	eventShutdown:    "ShuttingDown",
+	eventAuthBanned:  "AuthBanned",
+}
+
+// kubeEventSink records lifecycle events as Kubernetes Events on the pod, so
+// they show up in kubectl describe pod. The service account needs create on
+// events, and get on pods unless POD_UID is set through the downward API.
+type kubeEventSink struct {
+	baseURL   string
+	namespace string
+	pod       string
+	podUID    string
+	client    *http.Client
+}
+
+// newKubeEventSink returns nil outside Kubernetes or when
+// QBT_KUBERNETES_EVENTS is false.
+func newKubeEventSink(ctx context.Context) (*kubeEventSink, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" || !getEnvBool("QBT_KUBERNETES_EVENTS", true) {
+		return nil, nil
+	}
+	if _, err := os.Stat(serviceAccountDir + "/token"); err != nil {
+		return nil, nil
+	}
+
+	namespace, err := os.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pod namespace: %w", err)
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates found in cluster CA")
+	}
+	pod := os.Getenv("POD_NAME")
+	if pod == "" {
+		pod, _ = os.Hostname()
+	}
+
+	s := &kubeEventSink{
+		baseURL:   "https://" + net.JoinHostPort(host, port),
+		namespace: strings.TrimSpace(string(namespace)),
+		pod:       pod,
+		podUID:    os.Getenv("POD_UID"),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+			},
+		},
+	}
+	// kubectl describe only lists events carrying the pod's UID.
+	if s.podUID == "" {
+		uid, err := s.lookupPodUID(ctx)
+		if err != nil {
+			log.Warn("Pod UID unknown, events may not show in kubectl describe; set POD_UID from metadata.uid", "error", err)
+		}
+		s.podUID = uid
+	}
+	return s, nil
+}
+
+func (s *kubeEventSink) lookupPodUID(ctx context.Context) (string, error) {
+	var pod struct {
+		Metadata struct {
+			UID string `json:"uid"`
+		} `json:"metadata"`
+	}
+	resp, err := s.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/namespaces/%s/pods/%s", s.namespace, s.pod), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", kubeStatusError(resp)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&pod); err != nil {
+		return "", fmt.Errorf("failed to decode pod: %w", err)
+	}
+	return pod.Metadata.UID, nil
+}
+
+func (s *kubeEventSink) record(ctx context.Context, event string, data map[string]any) error {
+	reason, ok := kubeEventReasons[event]
+	if !ok {
+		return nil
+	}
+	eventType := "Normal"
+	if event == eventRestarted || event == eventAuthBanned {
+		eventType = "Warning"
+	}
+
+	message := "qBittorrent " + strings.ReplaceAll(event, "-", " ")
+	if len(data) > 0 {
+		var details []string
+		for _, key := range slices.Sorted(maps.Keys(data)) {
+			details = append(details, fmt.Sprintf("%s=%v", key, data[key]))
+		}
+		message += ": " + strings.Join(details, ", ")
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Event",
+		"metadata": map[string]any{
+			"generateName": s.pod + ".",
+			"namespace":    s.namespace,
+		},
+		"involvedObject": map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"name":       s.pod,
+			"namespace":  s.namespace,
+			"uid":        s.podUID,
+		},
+		"reason":             reason,
+		"message":            message,
+		"type":               eventType,
+		"source":             map[string]any{"component": "qbittorrent-init"},
+		"reportingComponent": "qbittorrent-init",
+		"reportingInstance":  s.pod,
+		"firstTimestamp":     now,
+		"lastTimestamp":      now,
+		"count":              1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	resp, err := s.do(ctx, http.MethodPost, fmt.Sprintf("/api/v1/namespaces/%s/events", s.namespace), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return kubeStatusError(resp)
+	}
+	return nil
+}
+
+func (s *kubeEventSink) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	// The kubelet rotates the token, so it is read for every request.
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp, nil
+}
+
+func kubeStatusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %d from the Kubernetes API: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadEventLabels reads the static labels attached to lifecycle events, so
+// events from several instances can be told apart. QBT_EVENT_LABELS_FILE
+// points at a Kubernetes downward API file such as metadata.labels, and
+// QBT_EVENT_LABELS adds or overrides labels as key=value pairs separated by
+// commas.
+func loadEventLabels() (map[string]string, error) {
+	labels := make(map[string]string)
+
+	if path := os.Getenv("QBT_EVENT_LABELS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read QBT_EVENT_LABELS_FILE: %w", err)
+		}
+		if err := parseDownwardAPILabels(data, labels); err != nil {
+			return nil, fmt.Errorf("invalid QBT_EVENT_LABELS_FILE %s: %w", path, err)
+		}
+	}
+
+	for _, pair := range strings.Split(os.Getenv("QBT_EVENT_LABELS"), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			return nil, fmt.Errorf("invalid entry %q in QBT_EVENT_LABELS: expected key=value", pair)
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	return labels, nil
+}
+
+// parseDownwardAPILabels reads the key="value" lines the downward API writes
+// for labels and annotations. Values are Go quoted strings.
+func parseDownwardAPILabels(data []byte, labels map[string]string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		key, quoted, ok := strings.Cut(text, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("line %d: expected key=\"value\"", line)
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid value for %s: %w", line, key, err)
+		}
+		labels[key] = value
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	eventStarted     = "started"
+	eventReady       = "ready"
+	eventPortChanged = "port-changed"
+	eventRestarted   = "restarted"
+	eventShutdown    = "shutdown"
+	eventAuthBanned  = "auth-banned"
+)
+
+const runningMarkerPath = "/config/qBittorrent/.init-running"
+
+type lifecycleEvent struct {
+	Event     string            `json:"event"`
+	Timestamp time.Time         `json:"timestamp"`
+	Hostname  string            `json:"hostname,omitempty"`
+	Version   string            `json:"version"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Data      map[string]any    `json:"data,omitempty"`
+}
+
+type lifecycleEmitter struct {
+	webhookURL string
+	client     *http.Client
+	hostname   string
+	labels     map[string]string
+	kube       *kubeEventSink
+	wg         sync.WaitGroup
+}
+
+var lifecycle = newLifecycleEmitter()
+
+func newLifecycleEmitter() *lifecycleEmitter {
+	hostname, _ := os.Hostname()
+	return &lifecycleEmitter{
+		webhookURL: os.Getenv("QBT_LIFECYCLE_WEBHOOK_URL"),
+		client:     &http.Client{Timeout: 10 * time.Second},
+		hostname:   hostname,
+	}
+}
+
+func (e *lifecycleEmitter) enabled() bool {
+	return e.webhookURL != "" || e.kube != nil
+}
+
+func (e *lifecycleEmitter) emit(ctx context.Context, event string, data map[string]any) {
+	if !e.enabled() {
+		return
+	}
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		if e.kube != nil {
+			if err := e.kube.record(sendCtx, event, data); err != nil {
+				log.Warn("Kubernetes event not recorded", "event", event, "error", err)
+			}
+		}
+		if e.webhookURL == "" {
+			return
+		}
+		if err := e.send(sendCtx, event, data); err != nil {
+			log.Warn("Lifecycle webhook delivery failed", "event", event, "error", err)
+		}
+	}()
+}
+
+func (e *lifecycleEmitter) flush(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Warn("Timed out waiting for lifecycle webhooks to be delivered")
+	}
+}
+
+func (e *lifecycleEmitter) send(ctx context.Context, event string, data map[string]any) error {
+	payload, err := json.Marshal(lifecycleEvent{
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Hostname:  e.hostname,
+		Version:   version,
+		Labels:    e.labels,
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		err = e.post(ctx, payload)
+		if err == nil {
+			log.Debug("Lifecycle event delivered", "event", event)
+			return nil
+		}
+		if attempt == 3 {
+			return fmt.Errorf("failed after %d attempts: %w", attempt, err)
+		}
+
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (e *lifecycleEmitter) post(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func markRunning() (previousUnclean bool) {
+	if _, err := os.Stat(runningMarkerPath); err == nil {
+		previousUnclean = true
+	}
+
+	if err := os.MkdirAll(filepath.Dir(runningMarkerPath), 0755); err != nil {
+		log.Debug("Failed to create directory for running marker", "error", err)
+		return previousUnclean
+	}
+	if err := os.WriteFile(runningMarkerPath, []byte(time.Now().UTC().Format(time.RFC3339)), 0644); err != nil {
+		log.Debug("Failed to write running marker", "error", err)
+	}
+	return previousUnclean
+}
+
+func clearRunningMarker() {
+	if err := os.Remove(runningMarkerPath); err != nil && !os.IsNotExist(err) {
+		log.Debug("Failed to remove running marker", "error", err)
+	}
+}
+
+func watchListenPort(ctx context.Context, client *webUIClient, interval time.Duration) {
+	var lastPort float64
+
+	check := func() {
+		prefs, err := client.preferences(ctx)
+		if err != nil {
+			log.Debug("Failed to read preferences for port check", "error", err)
+			return
+		}
+		port, _ := prefs["listen_port"].(float64)
+		if port == 0 || port == lastPort {
+			return
+		}
+		if lastPort != 0 {
+			log.Info("Torrenting port changed", "old_port", int(lastPort), "new_port", int(port))
+			lifecycle.emit(ctx, eventPortChanged, map[string]any{
+				"old_port": int(lastPort),
+				"new_port": int(port),
+			})
+		}
+		lastPort = port
+	}
+
+	check()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
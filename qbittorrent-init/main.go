@@ -3,18 +3,23 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
 
+const hardenedExecCommand = "harden-exec"
+
 const (
 	defaultConfigPath = "/config/qBittorrent/qBittorrent.conf"
 	defaultLogPath    = "/config/qBittorrent/logs/qbittorrent.log"
@@ -75,9 +80,6 @@ const defaultConfigTemplate = `[AutoRun]
 enabled=false
 program=
 
-[LegalNotice]
-Accepted=true
-
 [BitTorrent]
 Session\AsyncIOThreadsCount=10
 Session\DiskCacheSize=-1
@@ -93,18 +95,25 @@ Session\UseOSCache=true
 
 [Preferences]
 Connection\PortRangeMin=6881
-Connection\UPnP=false
 General\Locale=en
-General\UseRandomPort=false
 WebUI\Address=*
-WebUI\CSRFProtection=false
-WebUI\HostHeaderValidation=false
-WebUI\LocalHostAuth=false
 WebUI\Port=8080
 WebUI\ServerDomains=*
-WebUI\UseUPnP=false
 `
 
+// loadConfiguration adds SECRETS_DIR and CONFIG_YAML to the environment.
+// The harden-exec child skips both: it passes its environment, already
+// scrubbed for qBittorrent, straight to exec.
+func loadConfiguration(args []string) error {
+	if len(args) > 0 && args[0] == hardenedExecCommand {
+		return nil
+	}
+	if err := loadSecretsDir(); err != nil {
+		return fmt.Errorf("failed to load secrets: %w", err)
+	}
+	return loadConfigYAML()
+}
+
 func main() {
 	log := slog.New(slog.NewTextHandler(os.Stderr, nil))
 	defer func() {
@@ -118,6 +127,50 @@ func main() {
 
 	configureLogger()
 
+	if err := loadConfiguration(os.Args[1:]); err != nil {
+		log.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+	// LOG_LEVEL may have come from a secret or CONFIG_YAML.
+	configureLogger()
+	// The emitter read its webhook URL before the secrets and CONFIG_YAML
+	// were loaded.
+	lifecycle = newLifecycleEmitter()
+	labels, err := loadEventLabels()
+	if err != nil {
+		log.Error("Invalid event labels", "error", err)
+		os.Exit(1)
+	}
+	lifecycle.labels = labels
+
+	if len(os.Args) > 1 {
+		if handled, err := runSubcommand(os.Args[1], os.Args[2:]); handled {
+			if err != nil {
+				log.Error("Command failed", "command", os.Args[1], "error", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	if versionJSONRequested(os.Args[1:]) {
+		if err := printVersionJSON(); err != nil {
+			log.Error("Command failed", "command", "--version", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	qbtArgs, dry := initArgs(os.Args[1:])
+	if dry {
+		dryRun = true
+		if err := runDryRun(qbtArgs); err != nil {
+			log.Error("Dry run found problems", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(),
 		syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -127,12 +180,48 @@ func main() {
 		"commit", commit,
 		"date", date)
 
+	startup.record("env_setup", startup.start, nil)
+	watchStateDump(ctx)
+
+	if addr := os.Getenv("HEALTH_ADDR"); addr != "" {
+		startHealthServer(ctx, addr)
+	}
+
+	kube, err := newKubeEventSink(ctx)
+	if err != nil {
+		log.Warn("Kubernetes events disabled", "error", err)
+	}
+	lifecycle.kube = kube
+	lifecycle.emit(ctx, eventStarted, nil)
+
 	if err := initializeConfig(); err != nil {
 		log.Error("Configuration initialization failed", "error", err)
+		lifecycle.flush(10 * time.Second)
+		os.Exit(1)
+	}
+
+	if err := startup.track("vpn_wait", func() error { return waitForVPN(ctx) }); err != nil {
+		log.Error("VPN is not ready, not starting qBittorrent", "error", err)
+		lifecycle.flush(10 * time.Second)
 		os.Exit(1)
 	}
 
-	if err := runQBittorrent(ctx); err != nil {
+	if markRunning() {
+		lifecycle.emit(ctx, eventRestarted, map[string]any{
+			"reason": "previous run did not shut down cleanly",
+		})
+	}
+
+	err = runQBittorrent(ctx, qbtArgs)
+	// qBittorrent saves its settings on exit.
+	recordConfigFingerprint(defaultConfigPath)
+	if ctx.Err() != nil {
+		clearRunningMarker()
+		lifecycle.emit(ctx, eventShutdown, nil)
+	}
+	lifecycle.flush(10 * time.Second)
+
+	if err != nil {
 		log.Error("qBittorrent process failed", "error", err)
 		os.Exit(1)
 	}
@@ -140,6 +229,29 @@ func main() {
 	log.Info("qBittorrent initializer completed successfully")
 }
 
+func runSubcommand(name string, args []string) (bool, error) {
+	switch name {
+	case "restore":
+		return true, runRestoreCommand(args)
+	case "check-resume":
+		return true, runCheckResumeCommand(args)
+	case "export":
+		return true, runExportCommand(args)
+	case "migrate":
+		return true, runMigrateCommand(args)
+	case "remap-paths":
+		return true, runRemapPathsCommand(args)
+	case "bench":
+		return true, runBenchCommand(args)
+	case "prestop":
+		return true, runPrestopCommand(args)
+	case hardenedExecCommand:
+		return true, runHardenedExec(args)
+	default:
+		return false, nil
+	}
+}
+
 func configureLogger() {
 	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level:     getLogLevel(),
@@ -178,33 +290,130 @@ func getLogLevel() slog.Level {
 	}
 }
 
+func getEnv(key, defaultValue string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defaultValue
+}
+
+var warnedAliases sync.Map
+
+// getEnvAlias returns the first of key and its deprecated aliases that is
+// set, warning once for each alias still in use.
+func getEnvAlias(key string, aliases ...string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	for _, alias := range aliases {
+		if val := os.Getenv(alias); val != "" {
+			if _, warned := warnedAliases.LoadOrStore(alias, true); !warned {
+				log.Warn("Environment variable is deprecated", "name", alias, "replacement", key)
+			}
+			return val
+		}
+	}
+	return ""
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue
+	}
+	return strings.ToLower(val) == "true"
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue
+	}
+	result, err := strconv.Atoi(val)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue
+	}
+	result, err := time.ParseDuration(val)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
+
 func initializeConfig() error {
-	if err := ensureConfigFile(defaultConfigPath); err != nil {
+	if err := startup.track("preflight", preflightBinary); err != nil {
+		return fmt.Errorf("preflight check failed: %w", err)
+	}
+	checkConfigFingerprint(defaultConfigPath)
+	if err := startup.track("config", func() error {
+		return ensureConfigFile(defaultConfigPath)
+	}); err != nil {
 		return fmt.Errorf("config file setup failed: %w", err)
 	}
-	if err := ensureLogSymlink(defaultLogPath); err != nil {
+	if err := startup.track("config_migration", func() error {
+		if !getEnvBool("QBT_CONFIG_MIGRATION_ENABLED", true) {
+			return nil
+		}
+		return migrateConfig(defaultConfigPath)
+	}); err != nil {
+		return fmt.Errorf("config migration failed: %w", err)
+	}
+	if err := startup.track("db_check", func() error {
+		if !getEnvBool("QBT_DB_CHECK_ENABLED", true) {
+			return nil
+		}
+		return verifyResumeDatabase()
+	}); err != nil {
+		return fmt.Errorf("resume database check failed: %w", err)
+	}
+	if err := startup.track("symlink", func() error {
+		return ensureLogSymlink(defaultLogPath)
+	}); err != nil {
 		return fmt.Errorf("log setup failed: %w", err)
 	}
+	recordConfigFingerprint(defaultConfigPath)
 	return nil
 }
 
 func ensureConfigFile(configPath string) error {
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+	conf, err := readINIFile(configPath)
+	fresh := os.IsNotExist(err)
+	switch {
+	case fresh:
 		log.Info("Configuration file does not exist, writing default configuration", "path", configPath)
-
-		if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
-			return fmt.Errorf("failed to create config directory: %w", err)
+		if conf, err = initialConfig(); err != nil {
+			return err
 		}
+	case err != nil:
+		return fmt.Errorf("failed to check config file: %w", err)
+	default:
+		log.Info("Configuration file already exists, skipping write", "path", configPath)
+	}
 
-		if err := os.WriteFile(configPath, []byte(defaultConfigTemplate), 0644); err != nil {
-			return fmt.Errorf("failed to write config file: %w", err)
-		}
+	changed, err := applyEnvSettings(conf)
+	if err != nil {
+		return err
+	}
+	if !fresh && !changed {
+		return nil
+	}
 
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := conf.writeFile(configPath); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if fresh {
 		log.Info("Default configuration written successfully")
-	} else if err != nil {
-		return fmt.Errorf("failed to check config file: %w", err)
-	} else {
-		log.Info("Configuration file already exists, skipping write", "path", configPath)
 	}
 	return nil
 }
@@ -256,7 +465,8 @@ func sanitizeArgs(args []string) []string {
 			break
 		}
 
-		opt, exists := allowedOptions[arg]
+		name, _, _ := strings.Cut(arg, "=")
+		opt, exists := allowedOptions[name]
 		if !exists || !strings.HasPrefix(arg, "-") {
 			sanitized = append(sanitized, originalArg)
 			i++
@@ -303,48 +513,206 @@ func sanitizeArgs(args []string) []string {
 	return sanitized
 }
 
+// appendPortArgs adds --webui-port and --torrenting-port from QBT_WEBUI_PORT
+// and QBT_TORRENTING_PORT unless the arguments already set them.
+func appendPortArgs(args []string) []string {
+	end := len(args)
+	if i := slices.Index(args, "--"); i >= 0 {
+		end = i
+	}
+
+	var ports []string
+	for _, p := range []struct{ env, option string }{
+		{"QBT_WEBUI_PORT", "--webui-port"},
+		{"QBT_TORRENTING_PORT", "--torrenting-port"},
+	} {
+		value := os.Getenv(p.env)
+		if value == "" || slices.Contains(args[:end], p.option) {
+			continue
+		}
+		if !isValidPort(value) {
+			log.Warn("Ignoring invalid port from environment", "variable", p.env, "value", value)
+			continue
+		}
+		ports = append(ports, p.option, value)
+	}
+	return slices.Insert(args, end, ports...)
+}
+
 func isValidPort(port string) bool {
 	p, err := strconv.Atoi(port)
 	return err == nil && p > 0 && p <= 65535
 }
 
-func isValidPath(path string) bool {
-	return !strings.Contains(path, "..") &&
-		!strings.HasPrefix(path, "/") &&
-		!strings.Contains(path, "$")
+// qbittorrentArgs returns the validated arguments for qbittorrent-nox.
+func qbittorrentArgs(args []string) ([]string, error) {
+	if err := loadExtraOptions(); err != nil {
+		return nil, err
+	}
+	return appendPortArgs(sanitizeArgs(args)), nil
+}
+
+func runQBittorrent(ctx context.Context, rawArgs []string) error {
+	safeArgs, err := qbittorrentArgs(rawArgs)
+	if err != nil {
+		return err
+	}
+	schedule, err := speedSchedulePrefs()
+	if err != nil {
+		return err
+	}
+	profile, err := profilePrefs()
+	if err != nil {
+		return err
+	}
+	if err := checkWebUICredentials(defaultConfigPath); err != nil {
+		return err
+	}
+	gw, err := newGateway()
+	if err != nil {
+		return fmt.Errorf("invalid gateway configuration: %w", err)
+	}
+	bans, err := newAuthBanner()
+	if err != nil {
+		return err
+	}
+	logConfigDiff(defaultConfigPath, safeArgs)
+	name, args := hardenedCommand(qbittorrentBinary, safeArgs)
+	watchdog := loadWatchdogConfig()
+
+	if gw != nil {
+		gw.bans = bans
+		gw.start(ctx)
+	} else if bans != nil {
+		log.Warn("QBT_AUTH_BAN_ENABLED without QBT_GATEWAY_ADDR: only qBittorrent's built-in ban applies")
+	}
+
+	for restarts := 0; ; restarts++ {
+		hung, err := runQBittorrentProcess(ctx, name, args, profile, schedule, bans, watchdog, restarts == 0)
+		if !hung {
+			return err
+		}
+		if err != nil {
+			log.Warn("Hung qBittorrent process did not stop cleanly", "error", err)
+		}
+		if restarts >= watchdog.maxRestarts {
+			return fmt.Errorf("WebUI unresponsive after %d restarts", restarts)
+		}
+		log.Warn("Restarting qBittorrent, the WebUI stopped responding", "restarts", restarts+1)
+		lifecycle.emit(ctx, eventRestarted, map[string]any{
+			"reason": "WebUI stopped responding",
+		})
+	}
 }
 
-func runQBittorrent(ctx context.Context) error {
-	safeArgs := sanitizeArgs(os.Args[1:])
-	cmd := exec.CommandContext(ctx, "/usr/bin/qbittorrent-nox", safeArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// runQBittorrentProcess runs qBittorrent until it exits or ctx ends. It
+// reports hung when the watchdog stopped the process because the WebUI
+// stopped responding, so the caller can start it again.
+func runQBittorrentProcess(ctx context.Context, name string, args []string, profile, schedule map[string]any, bans *authBanner, watchdog *watchdogConfig, first bool) (bool, error) {
+	if err := verifyBinary(qbittorrentBinary); err != nil {
+		return false, fmt.Errorf("binary integrity check failed: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	recentLogs := newLineRing(getEnvInt("QBT_CRASH_LOG_LINES", 100))
+	cmd.Stdout = io.MultiWriter(os.Stdout, recentLogs)
+	cmd.Stderr = io.MultiWriter(os.Stderr, recentLogs)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Env = childEnv(os.Environ())
 
 	log.Info("Starting qBittorrent process", "command", cmd.String())
 
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start process: %w", err)
+		return false, fmt.Errorf("failed to start process: %w", err)
 	}
+	started := time.Now()
 
 	done := make(chan error, 1)
 	go func() {
 		done <- cmd.Wait()
 	}()
 
+	// Everything started for this process stops with it, so a restart
+	// does not leave a second set of maintenance jobs running.
+	procCtx, stopProc := context.WithCancel(ctx)
+	defer stopProc()
+	hung := make(chan struct{})
+
+	go func() {
+		timeout := getEnvDuration("QBT_READY_TIMEOUT", 2*time.Minute)
+		client := newWebUIClient()
+		wait := func() error {
+			return waitForWebUI(procCtx, client, timeout)
+		}
+		var err error
+		if first {
+			err = startup.track("api_ready", wait)
+		} else {
+			err = wait()
+		}
+		if err != nil {
+			log.Warn("WebUI readiness check failed", "error", err)
+			return
+		}
+		if first {
+			startup.complete()
+		}
+		lifecycle.emit(procCtx, eventReady, map[string]any{
+			"startup_ms": startup.snapshot().TotalMs,
+		})
+
+		if err := resumePrestopTorrents(procCtx, client); err != nil {
+			log.Error("Torrents stopped by the prestop hook not resumed", "error", err)
+		}
+
+		if err := applyProfile(procCtx, client, profile); err != nil {
+			log.Error("Preference profile not applied", "error", err)
+		}
+
+		if err := applySpeedSchedule(procCtx, client, schedule); err != nil {
+			log.Error("Speed schedule not applied", "error", err)
+		}
+
+		if bans != nil {
+			go bans.watch(procCtx, client)
+		}
+
+		if lifecycle.enabled() {
+			go watchListenPort(procCtx, client, getEnvDuration("QBT_PORT_CHECK_INTERVAL", time.Minute))
+		}
+
+		startMaintenance(procCtx, client, configuredMaintenanceJobs())
+
+		if watchdog != nil && watchWebUI(procCtx, watchdog, client) {
+			close(hung)
+		}
+	}()
+
 	select {
 	case err := <-done:
-		return fmt.Errorf("process exited unexpectedly: %w", err)
+		if isAbnormalExit(err) {
+			handleCrash(ctx, cmd, started, err, recentLogs)
+		}
+		return false, fmt.Errorf("process exited unexpectedly: %w", err)
+	case <-hung:
+		log.Error("WebUI stopped responding, stopping qBittorrent", "pid", cmd.Process.Pid)
+		return true, stopProcess(cmd, done)
 	case <-ctx.Done():
 		log.Info("Received termination signal, shutting down")
-		syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+		return false, stopProcess(cmd, done)
+	}
+}
 
-		select {
-		case <-time.After(30 * time.Second):
-			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
-			return fmt.Errorf("forced shutdown after timeout")
-		case err := <-done:
-			return err
-		}
+// stopProcess sends SIGTERM to qBittorrent's process group so it saves its
+// state, and SIGKILL if it hasn't exited after 30 seconds.
+func stopProcess(cmd *exec.Cmd, done <-chan error) error {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+
+	select {
+	case <-time.After(30 * time.Second):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return fmt.Errorf("forced shutdown after timeout")
+	case err := <-done:
+		return err
 	}
 }
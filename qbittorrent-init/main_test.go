@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	configureLogger()
+	os.Exit(m.Run())
+}
+
+func TestGetEnvAlias(t *testing.T) {
+	t.Setenv("QBT_WEBUI_USERNAME", "old")
+	t.Setenv("QBT_USERNAME", "")
+	if got := getEnvAlias("QBT_USERNAME", "QBT_WEBUI_USERNAME"); got != "old" {
+		t.Errorf("alias only: got %q, want %q", got, "old")
+	}
+	t.Setenv("QBT_USERNAME", "new")
+	if got := getEnvAlias("QBT_USERNAME", "QBT_WEBUI_USERNAME"); got != "new" {
+		t.Errorf("both set: got %q, want %q", got, "new")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+type maintenanceJob struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context, client *webUIClient) error
+}
+
+// jobStatus is the outcome of a maintenance job's runs, kept for state dumps.
+type jobStatus struct {
+	runs      int
+	lastRun   time.Time
+	lastError string
+	errorAt   time.Time
+}
+
+type jobStatuses struct {
+	mu   sync.Mutex
+	jobs map[string]*jobStatus
+}
+
+var maintenanceStatus = &jobStatuses{jobs: make(map[string]*jobStatus)}
+
+func (s *jobStatuses) record(name string, start time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.jobs[name]
+	if st == nil {
+		st = &jobStatus{}
+		s.jobs[name] = st
+	}
+	st.runs++
+	st.lastRun = start
+	if err != nil {
+		st.lastError = err.Error()
+		st.errorAt = time.Now()
+	}
+}
+
+func configuredMaintenanceJobs() []maintenanceJob {
+	var jobs []maintenanceJob
+
+	if getEnvBool("QBT_ORPHAN_SCAN_ENABLED", false) {
+		jobs = append(jobs, maintenanceJob{
+			name:     "orphan-scan",
+			interval: getEnvDuration("QBT_ORPHAN_SCAN_INTERVAL", 24*time.Hour),
+			run:      newOrphanScanner().run,
+		})
+	}
+
+	if getEnvBool("QBT_UNREGISTERED_SCAN_ENABLED", false) {
+		jobs = append(jobs, maintenanceJob{
+			name:     "unregistered-scan",
+			interval: getEnvDuration("QBT_UNREGISTERED_SCAN_INTERVAL", 30*time.Minute),
+			run:      newUnregisteredScanner().run,
+		})
+	}
+
+	if getEnvBool("QBT_REANNOUNCE_ENABLED", false) {
+		jobs = append(jobs, maintenanceJob{
+			name:     "stalled-reannounce",
+			interval: getEnvDuration("QBT_REANNOUNCE_INTERVAL", 10*time.Second),
+			run:      newStalledReannouncer().run,
+		})
+	}
+
+	if getEnvBool("QBT_HARDLINK_TAG_ENABLED", false) {
+		jobs = append(jobs, maintenanceJob{
+			name:     "hardlink-tag",
+			interval: getEnvDuration("QBT_HARDLINK_TAG_INTERVAL", time.Hour),
+			run:      newHardlinkTagger().run,
+		})
+	}
+
+	if getEnvBool("QBT_TRACKERS_INJECT_ENABLED", false) {
+		jobs = append(jobs, maintenanceJob{
+			name:     "trackers-inject",
+			interval: getEnvDuration("QBT_TRACKERS_INJECT_INTERVAL", 6*time.Hour),
+			run:      newTrackerInjector().run,
+		})
+	}
+
+	if getEnvBool("QBT_ARCHIVE_ENABLED", false) {
+		jobs = append(jobs, maintenanceJob{
+			name:     "torrent-archive",
+			interval: getEnvDuration("QBT_ARCHIVE_INTERVAL", time.Minute),
+			run:      newTorrentArchiver().run,
+		})
+	}
+
+	if os.Getenv("QBT_IPFILTER_URL") != "" {
+		jobs = append(jobs, maintenanceJob{
+			name:     "ipfilter-update",
+			interval: getEnvDuration("QBT_IPFILTER_INTERVAL", 24*time.Hour),
+			run:      newIPFilterUpdater().run,
+		})
+	}
+
+	if getEnvBool("QBT_BACKUP_ENABLED", false) {
+		backup, err := newBackupJob()
+		if err != nil {
+			log.Error("Backups disabled", "error", err)
+		} else {
+			jobs = append(jobs, maintenanceJob{
+				name:     "backup",
+				interval: getEnvDuration("QBT_BACKUP_INTERVAL", 24*time.Hour),
+				run:      backup.run,
+			})
+		}
+	}
+
+	if getEnvBool("QBT_PRUNE_ENABLED", false) {
+		engine, err := newPruneEngine()
+		if err != nil {
+			log.Error("Prune policies disabled", "error", err)
+		} else {
+			jobs = append(jobs, maintenanceJob{
+				name:     "prune",
+				interval: getEnvDuration("QBT_PRUNE_INTERVAL", time.Hour),
+				run:      engine.run,
+			})
+		}
+	}
+
+	if getEnvBool("QBT_DRIFT_CHECK_ENABLED", false) {
+		jobs = append(jobs, maintenanceJob{
+			name:     "config-drift",
+			interval: getEnvDuration("QBT_DRIFT_CHECK_INTERVAL", 5*time.Minute),
+			run:      newDriftChecker().run,
+		})
+	}
+
+	if getEnvBool("QBT_PASSWORD_ROTATION_ENABLED", false) {
+		jobs = append(jobs, maintenanceJob{
+			name:     "password-rotation",
+			interval: getEnvDuration("QBT_PASSWORD_ROTATION_CHECK_INTERVAL", time.Hour),
+			run:      newPasswordRotator().run,
+		})
+	}
+
+	if getEnvBool("QBT_ARR_CLEANUP_ENABLED", false) {
+		cleaner, err := newArrCleaner()
+		if err != nil {
+			log.Error("Arr queue cleanup disabled", "error", err)
+		} else {
+			jobs = append(jobs, maintenanceJob{
+				name:     "arr-cleanup",
+				interval: getEnvDuration("QBT_ARR_CLEANUP_INTERVAL", 15*time.Minute),
+				run:      cleaner.run,
+			})
+		}
+	}
+
+	if getEnvBool("QBT_AUDIT_ENABLED", false) {
+		jobs = append(jobs, maintenanceJob{
+			name:     "preference-audit",
+			interval: getEnvDuration("QBT_AUDIT_INTERVAL", 5*time.Minute),
+			run:      newPreferenceAuditor().run,
+		})
+	}
+
+	return jobs
+}
+
+func startMaintenance(ctx context.Context, client *webUIClient, jobs []maintenanceJob) {
+	for _, job := range jobs {
+		go runMaintenanceJob(ctx, client, job)
+	}
+}
+
+func runMaintenanceJob(ctx context.Context, client *webUIClient, job maintenanceJob) {
+	log.Info("Scheduling maintenance job", "job", job.name, "interval", job.interval)
+
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		err := job.run(ctx, client)
+		if err != nil {
+			log.Error("Maintenance job failed", "job", job.name, "error", err)
+		} else {
+			log.Debug("Maintenance job finished", "job", job.name, "duration", time.Since(start))
+		}
+		maintenanceStatus.record(job.name, start, err)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type migratedTorrent struct {
+	Hash      string
+	Name      string
+	FileName  string
+	Torrent   []byte
+	SavePath  string
+	Category  string
+	SourceRef string
+	// Complete is set when the source client has every piece, so the data
+	// can be trusted without a recheck.
+	Complete bool
+}
+
+type pathMapping struct {
+	from string
+	to   string
+}
+
+func parsePathMappings(values []string) ([]pathMapping, error) {
+	var mappings []pathMapping
+	for _, v := range values {
+		from, to, ok := strings.Cut(v, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid path mapping %q (expected old=new)", v)
+		}
+		mappings = append(mappings, pathMapping{from: filepath.Clean(from), to: filepath.Clean(to)})
+	}
+	sort.Slice(mappings, func(i, j int) bool { return len(mappings[i].from) > len(mappings[j].from) })
+	return mappings, nil
+}
+
+func applyPathMappings(p string, mappings []pathMapping) string {
+	clean := filepath.Clean(p)
+	for _, m := range mappings {
+		if clean == m.from {
+			return m.to
+		}
+		if strings.HasPrefix(clean, m.from+string(filepath.Separator)) {
+			return m.to + clean[len(m.from):]
+		}
+	}
+	return p
+}
+
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
+func runMigrateCommand(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	from := flags.String("from", "", "source client: transmission, deluge or rtorrent")
+	dir := flags.String("dir", "", "source client session/state directory")
+	dryRun := flags.Bool("dry-run", false, "print what would be added without adding anything")
+	paused := flags.Bool("paused", false, "add torrents in stopped state")
+	defaultCategory := flags.String("category", "", "category for torrents without a source label")
+	var mappingFlags stringList
+	flags.Var(&mappingFlags, "path-map", "rewrite save paths, old=new (repeatable)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *dir == "" {
+		return errors.New("usage: qbittorrent-init migrate --from <transmission|deluge|rtorrent> --dir <path> [--path-map old=new] [--dry-run]")
+	}
+
+	mappings, err := parsePathMappings(mappingFlags)
+	if err != nil {
+		return err
+	}
+
+	var torrents []migratedTorrent
+	switch strings.ToLower(*from) {
+	case "transmission":
+		torrents, err = readTransmissionSession(*dir)
+	case "deluge":
+		torrents, err = readDelugeSession(*dir)
+	case "rtorrent":
+		torrents, err = readRTorrentSession(*dir)
+	default:
+		return fmt.Errorf("unsupported source client: %s", *from)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s session: %w", *from, err)
+	}
+	log.Info("Read source session", "client", *from, "torrents", len(torrents))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	client := newWebUIClient()
+
+	var added, failed int
+	for _, t := range torrents {
+		savePath := applyPathMappings(t.SavePath, mappings)
+		category := t.Category
+		if category == "" {
+			category = *defaultCategory
+		}
+
+		log.Info("Migrating torrent",
+			"name", t.Name,
+			"hash", t.Hash,
+			"save_path", savePath,
+			"category", category,
+			"complete", t.Complete,
+			"dry_run", *dryRun)
+		if *dryRun {
+			continue
+		}
+
+		options := map[string]string{
+			"savepath":      savePath,
+			"skip_checking": fmt.Sprint(t.Complete),
+			"autoTMM":       "false",
+			"contentLayout": "Original",
+			"paused":        fmt.Sprint(*paused),
+			"stopped":       fmt.Sprint(*paused),
+		}
+		if category != "" {
+			options["category"] = category
+		}
+
+		if err := client.addTorrentFile(ctx, t.FileName, t.Torrent, options); err != nil {
+			log.Error("Failed to add torrent", "name", t.Name, "source", t.SourceRef, "error", err)
+			failed++
+			continue
+		}
+		added++
+	}
+
+	log.Info("Migration completed", "added", added, "failed", failed, "total", len(torrents))
+	if failed > 0 {
+		return fmt.Errorf("%d torrents failed to migrate", failed)
+	}
+	return nil
+}
+
+func readBencodedDict(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	v, err := bdecode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	d, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a dictionary", path)
+	}
+	return d, nil
+}
+
+func torrentIsMultiFile(data []byte) (string, bool, error) {
+	v, err := bdecode(data)
+	if err != nil {
+		return "", false, err
+	}
+	t, _ := v.(map[string]any)
+	info := bdictDict(t, "info")
+	if info == nil {
+		return "", false, errors.New("torrent has no info dictionary")
+	}
+	name := bdictString(info, "name")
+	if bdictList(info, "files") != nil {
+		return name, true, nil
+	}
+	if tree := bdictDict(info, "file tree"); tree != nil {
+		node := bdictDict(tree, name)
+		return name, len(tree) != 1 || node == nil || bdictDict(node, "") == nil, nil
+	}
+	return name, false, nil
+}
+
+func readTransmissionSession(dir string) ([]migratedTorrent, error) {
+	torrentDir := filepath.Join(dir, "torrents")
+	resumeDir := filepath.Join(dir, "resume")
+
+	entries, err := os.ReadDir(resumeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var torrents []migratedTorrent
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) != ".resume" {
+			continue
+		}
+		base := strings.TrimSuffix(e.Name(), ".resume")
+		resumePath := filepath.Join(resumeDir, e.Name())
+
+		resume, err := readBencodedDict(resumePath)
+		if err != nil {
+			log.Warn("Skipping unreadable resume file", "path", resumePath, "error", err)
+			continue
+		}
+
+		torrentPath := filepath.Join(torrentDir, base+".torrent")
+		data, err := os.ReadFile(torrentPath)
+		if err != nil {
+			log.Warn("Skipping resume entry without .torrent", "path", torrentPath, "error", err)
+			continue
+		}
+
+		var category string
+		if labels := bdictList(resume, "labels"); len(labels) > 0 {
+			category, _ = labels[0].(string)
+		}
+		if category == "" {
+			category = bdictString(resume, "group")
+		}
+
+		// Transmission records "all" instead of a bitfield once every
+		// piece is downloaded.
+		progress := bdictDict(resume, "progress")
+		complete := bdictString(progress, "have") == "all" || bdictString(progress, "blocks") == "all"
+
+		torrents = append(torrents, migratedTorrent{
+			Hash:      base,
+			Name:      bdictString(resume, "name"),
+			FileName:  base + ".torrent",
+			Torrent:   data,
+			SavePath:  bdictString(resume, "destination"),
+			Category:  category,
+			SourceRef: resumePath,
+			Complete:  complete,
+		})
+	}
+	return torrents, nil
+}
+
+func readDelugeSession(dir string) ([]migratedTorrent, error) {
+	fastresume, err := readBencodedDict(filepath.Join(dir, "torrents.fastresume"))
+	if err != nil {
+		return nil, err
+	}
+	labels := readDelugeLabels(filepath.Join(filepath.Dir(dir), "label.conf"))
+
+	var torrents []migratedTorrent
+	for hash, raw := range fastresume {
+		blob, _ := raw.(string)
+		inner, err := bdecode([]byte(blob))
+		if err != nil {
+			log.Warn("Skipping unreadable fastresume entry", "hash", hash, "error", err)
+			continue
+		}
+		resume, _ := inner.(map[string]any)
+
+		torrentPath := filepath.Join(dir, hash+".torrent")
+		data, err := os.ReadFile(torrentPath)
+		if err != nil {
+			log.Warn("Skipping fastresume entry without .torrent", "path", torrentPath, "error", err)
+			continue
+		}
+		name, _, _ := torrentIsMultiFile(data)
+
+		torrents = append(torrents, migratedTorrent{
+			Hash:      hash,
+			Name:      name,
+			FileName:  hash + ".torrent",
+			Torrent:   data,
+			SavePath:  bdictString(resume, "save_path"),
+			Category:  labels[hash],
+			SourceRef: torrentPath,
+			Complete:  libtorrentResumeComplete(resume),
+		})
+	}
+	sort.Slice(torrents, func(i, j int) bool { return torrents[i].Hash < torrents[j].Hash })
+	return torrents, nil
+}
+
+// libtorrentResumeComplete reports whether libtorrent resume data has every
+// piece: either the torrent was added in seed mode or every entry of the
+// piece map has its have bit set.
+func libtorrentResumeComplete(resume map[string]any) bool {
+	if bdictInt(resume, "seed_mode") == 1 {
+		return true
+	}
+	pieces := bdictString(resume, "pieces")
+	if pieces == "" {
+		return false
+	}
+	for i := 0; i < len(pieces); i++ {
+		if pieces[i]&1 == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func readDelugeLabels(path string) map[string]string {
+	labels := make(map[string]string)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return labels
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		var doc struct {
+			TorrentLabels map[string]string `json:"torrent_labels"`
+		}
+		if err := dec.Decode(&doc); err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Warn("Failed to parse Deluge label config", "path", path, "error", err)
+			}
+			return labels
+		}
+		for hash, label := range doc.TorrentLabels {
+			labels[hash] = label
+		}
+	}
+}
+
+func readRTorrentSession(dir string) ([]migratedTorrent, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var torrents []migratedTorrent
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) != ".torrent" {
+			continue
+		}
+		hash := strings.TrimSuffix(e.Name(), ".torrent")
+		torrentPath := filepath.Join(dir, e.Name())
+
+		state, err := readBencodedDict(torrentPath + ".rtorrent")
+		if err != nil {
+			log.Warn("Skipping torrent without rtorrent state", "path", torrentPath, "error", err)
+			continue
+		}
+		data, err := os.ReadFile(torrentPath)
+		if err != nil {
+			return nil, err
+		}
+
+		name, multi, err := torrentIsMultiFile(data)
+		if err != nil {
+			log.Warn("Skipping unreadable torrent", "path", torrentPath, "error", err)
+			continue
+		}
+
+		savePath := bdictString(state, "directory")
+		if multi {
+			savePath = filepath.Dir(savePath)
+		}
+
+		category := ""
+		if custom := bdictDict(state, "custom"); custom != nil {
+			category = bdictString(custom, "1")
+			if unescaped, err := url.QueryUnescape(category); err == nil {
+				category = unescaped
+			}
+		}
+
+		torrents = append(torrents, migratedTorrent{
+			Hash:      strings.ToLower(hash),
+			Name:      name,
+			FileName:  e.Name(),
+			Torrent:   data,
+			SavePath:  savePath,
+			Category:  category,
+			SourceRef: torrentPath,
+			Complete:  bdictInt(state, "complete") == 1,
+		})
+	}
+	return torrents, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestReadTransmissionSessionComplete(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"torrents", "resume"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, progress := range map[string]string{
+		"done":    "d4:have3:alle",
+		"partial": "d8:bitfield2:xxe",
+	} {
+		resume := "d11:destination5:/data4:name" + strconv.Itoa(len(name)) + ":" + name + "8:progress" + progress + "e"
+		if err := os.WriteFile(filepath.Join(dir, "resume", name+".resume"), []byte(resume), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "torrents", name+".torrent"), []byte("de"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	torrents, err := readTransmissionSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(torrents) != 2 {
+		t.Fatalf("read %d torrents, want 2", len(torrents))
+	}
+	for _, tr := range torrents {
+		if want := tr.Name == "done"; tr.Complete != want {
+			t.Errorf("%s: Complete = %v, want %v", tr.Name, tr.Complete, want)
+		}
+	}
+}
+
+func TestLibtorrentResumeComplete(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		resume map[string]any
+		want   bool
+	}{
+		{"all pieces", map[string]any{"pieces": "\x01\x01\x03"}, true},
+		{"missing piece", map[string]any{"pieces": "\x01\x00\x01"}, false},
+		{"seed mode", map[string]any{"seed_mode": int64(1)}, true},
+		{"no piece map", map[string]any{}, false},
+	} {
+		if got := libtorrentResumeComplete(tc.resume); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
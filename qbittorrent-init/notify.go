@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// notifierBinary is the notifier bundled in the image. Alerts go through its
+// notify command, so there is one Pushover client, configured by the same
+// PUSHOVER_* variables as the rest of the notifications.
+var notifierBinary = getEnv("QBT_NOTIFIER_BINARY", "/usr/bin/cross-seed-search")
+
+func sendPushover(ctx context.Context, title, message string, priority int) error {
+	if err := verifyBinary(notifierBinary); err != nil {
+		return fmt.Errorf("notifier integrity check failed: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, notifierBinary, "notify", "--priority", strconv.Itoa(priority), title, message)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("notifier failed: %w: %s", err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// extraOption declares a qbittorrent-nox command line option that is not in
+// the built-in allowlist, typically one added by a newer qBittorrent.
+type extraOption struct {
+	Name         string   `json:"name"`
+	ExpectsValue bool     `json:"expects_value"`
+	Pattern      string   `json:"pattern"`
+	Values       []string `json:"values"`
+}
+
+// loadExtraOptions adds the options from QBT_EXTRA_OPTIONS, or the file named
+// by QBT_EXTRA_OPTIONS_FILE, to allowedOptions. Built-in options cannot be
+// redefined, so their validation cannot be loosened.
+func loadExtraOptions() error {
+	raw := os.Getenv("QBT_EXTRA_OPTIONS")
+	if file := os.Getenv("QBT_EXTRA_OPTIONS_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read extra options file: %w", err)
+		}
+		raw = string(data)
+	}
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+
+	var extras []extraOption
+	if err := json.Unmarshal([]byte(raw), &extras); err != nil {
+		return fmt.Errorf("failed to parse extra options: %w", err)
+	}
+
+	options := make(map[string]allowedOption, len(extras))
+	for _, extra := range extras {
+		if !strings.HasPrefix(extra.Name, "-") || strings.ContainsAny(extra.Name, "= ") {
+			return fmt.Errorf("invalid option name %q", extra.Name)
+		}
+		if _, ok := allowedOptions[extra.Name]; ok {
+			return fmt.Errorf("option %s is built in and cannot be redefined", extra.Name)
+		}
+		if _, ok := options[extra.Name]; ok {
+			return fmt.Errorf("option %s is defined twice", extra.Name)
+		}
+
+		opt := allowedOption{expectsValue: extra.ExpectsValue}
+		if !extra.ExpectsValue && (extra.Pattern != "" || len(extra.Values) > 0) {
+			return fmt.Errorf("option %s: pattern and values require expects_value", extra.Name)
+		}
+		if extra.Pattern != "" {
+			re, err := regexp.Compile("^(?:" + extra.Pattern + ")$")
+			if err != nil {
+				return fmt.Errorf("option %s: invalid pattern: %w", extra.Name, err)
+			}
+			opt.validator = re.MatchString
+		}
+		if len(extra.Values) > 0 {
+			opt.allowedValues = make(map[string]bool, len(extra.Values))
+			for _, v := range extra.Values {
+				opt.allowedValues[strings.ToLower(v)] = true
+			}
+		}
+		options[extra.Name] = opt
+	}
+
+	for name, opt := range options {
+		allowedOptions[name] = opt
+	}
+	log.Info("Loaded extra qBittorrent options", "count", len(options))
+	return nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// orphanLoadingStates are states in which qBittorrent does not know a
+// torrent's files yet, so its payload would look orphaned.
+var orphanLoadingStates = []string{"checkingResumeData", "metaDL", "forcedMetaDL"}
+
+type orphanScanner struct {
+	dirs     []string
+	excludes []string
+	minAge   time.Duration
+	dryRun   bool
+	// settleInterval is how long the torrent list must stay unchanged, and
+	// settleTimeout how long to wait for that, before a scan.
+	settleInterval time.Duration
+	settleTimeout  time.Duration
+	// maxShrinkPercent is how much of the registered files may disappear
+	// between two runs before a run is aborted; lastRegistered is the count
+	// seen by the previous run.
+	maxShrinkPercent int
+	lastRegistered   int
+}
+
+func newOrphanScanner() *orphanScanner {
+	return &orphanScanner{
+		dirs:             splitList(os.Getenv("QBT_ORPHAN_DIRS")),
+		excludes:         splitList(getEnv("QBT_ORPHAN_EXCLUDE", "*.parts,.DS_Store")),
+		minAge:           getEnvDuration("QBT_ORPHAN_MIN_AGE", time.Hour),
+		dryRun:           getEnvBool("QBT_ORPHAN_DRY_RUN", true),
+		settleInterval:   getEnvDuration("QBT_ORPHAN_SETTLE_INTERVAL", 30*time.Second),
+		settleTimeout:    getEnvDuration("QBT_ORPHAN_SETTLE_TIMEOUT", 30*time.Minute),
+		maxShrinkPercent: getEnvInt("QBT_ORPHAN_MAX_SHRINK_PERCENT", 25),
+	}
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// waitForSettledTorrents returns the torrent list once qBittorrent has
+// finished loading: the count is unchanged over settleInterval and no
+// torrent is still in one of orphanLoadingStates.
+func (s *orphanScanner) waitForSettledTorrents(ctx context.Context, client *webUIClient) ([]torrentInfo, error) {
+	deadline := time.Now().Add(s.settleTimeout)
+	previous := -1
+	for {
+		torrents, err := client.torrents(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list torrents: %w", err)
+		}
+		loading := 0
+		for _, t := range torrents {
+			if slices.Contains(orphanLoadingStates, t.State) {
+				loading++
+			}
+		}
+		if loading == 0 && len(torrents) == previous {
+			return torrents, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("torrent list did not settle within %s (%d torrents, %d loading), skipping scan", s.settleTimeout, len(torrents), loading)
+		}
+		log.Debug("Waiting for torrents to finish loading before orphan scan", "torrents", len(torrents), "loading", loading)
+		previous = len(torrents)
+
+		select {
+		case <-time.After(s.settleInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *orphanScanner) run(ctx context.Context, client *webUIClient) error {
+	torrents, err := s.waitForSettledTorrents(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	registered := make(map[string]bool)
+	for _, t := range torrents {
+		files, err := client.torrentFiles(ctx, t.Hash)
+		if err != nil {
+			return fmt.Errorf("failed to list files for %s: %w", t.Hash, err)
+		}
+		for _, root := range []string{t.SavePath, t.DownloadPath} {
+			if root == "" {
+				continue
+			}
+			for _, f := range files {
+				p := filepath.Clean(filepath.Join(root, f.Name))
+				registered[p] = true
+				registered[p+".!qB"] = true
+			}
+		}
+	}
+
+	last := s.lastRegistered
+	s.lastRegistered = len(registered)
+	if last > 0 && (last-len(registered))*100 > last*s.maxShrinkPercent {
+		return fmt.Errorf("registered files dropped from %d to %d since the last run, skipping scan; it runs again once the count holds", last, len(registered))
+	}
+
+	dirs := s.dirs
+	if len(dirs) == 0 {
+		prefs, err := client.preferences(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read preferences: %w", err)
+		}
+		for _, key := range []string{"save_path", "temp_path"} {
+			if p, _ := prefs[key].(string); p != "" {
+				dirs = append(dirs, p)
+			}
+		}
+	}
+
+	orphans, totalSize, err := s.findOrphans(ctx, dirs, registered)
+	if err != nil {
+		return err
+	}
+
+	if len(orphans) == 0 {
+		log.Info("Orphan scan found no orphaned files", "dirs", dirs)
+		return nil
+	}
+
+	for _, p := range orphans {
+		log.Info("Orphaned file detected", "path", p, "dry_run", s.dryRun)
+	}
+	log.Info("Orphan scan completed",
+		"orphans", len(orphans),
+		"total_bytes", totalSize,
+		"dry_run", s.dryRun)
+
+	if s.dryRun {
+		return nil
+	}
+
+	// Parent directories are judged by their age before the files in them
+	// were removed, since removing a file updates it.
+	parents := s.orphanParents(orphans, dirs)
+	var removed int
+	for _, p := range orphans {
+		if err := os.Remove(p); err != nil {
+			log.Warn("Failed to remove orphaned file", "path", p, "error", err)
+			continue
+		}
+		removed++
+	}
+	removeEmptyParents(parents)
+
+	log.Info("Orphaned files removed", "removed", removed, "failed", len(orphans)-removed)
+	return nil
+}
+
+func (s *orphanScanner) findOrphans(ctx context.Context, dirs []string, registered map[string]bool) ([]string, int64, error) {
+	var (
+		orphans   []string
+		totalSize int64
+		seen      = make(map[string]bool)
+		cutoff    = time.Now().Add(-s.minAge)
+	)
+
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				log.Debug("Skipping unreadable path", "path", p, "error", err)
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if d.IsDir() || seen[p] || registered[p] || s.excluded(d.Name()) {
+				return nil
+			}
+			seen[p] = true
+
+			info, err := d.Info()
+			if err != nil || !info.Mode().IsRegular() || info.ModTime().After(cutoff) {
+				return nil
+			}
+
+			orphans = append(orphans, p)
+			totalSize += info.Size()
+			return nil
+		})
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to walk %s: %w", dir, err)
+		}
+	}
+
+	sort.Strings(orphans)
+	return orphans, totalSize, nil
+}
+
+func (s *orphanScanner) excluded(name string) bool {
+	for _, pattern := range s.excludes {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// orphanParents maps each directory between an orphan and its scan root,
+// not including the root, to whether it may be removed once empty: it must
+// be older than minAge and not match an exclude pattern.
+func (s *orphanScanner) orphanParents(orphans, roots []string) map[string]bool {
+	cutoff := time.Now().Add(-s.minAge)
+	parents := make(map[string]bool)
+	for _, p := range orphans {
+		root := orphanRoot(p, roots)
+		if root == "" {
+			continue
+		}
+		for dir := filepath.Dir(p); dir != root; dir = filepath.Dir(dir) {
+			if _, seen := parents[dir]; seen {
+				break
+			}
+			info, err := os.Stat(dir)
+			parents[dir] = err == nil && !info.ModTime().After(cutoff) && !s.excluded(filepath.Base(dir))
+		}
+	}
+	return parents
+}
+
+// orphanRoot returns the innermost scan root containing p.
+func orphanRoot(p string, roots []string) string {
+	var best string
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		if strings.HasPrefix(p, root+string(filepath.Separator)) && len(root) > len(best) {
+			best = root
+		}
+	}
+	return best
+}
+
+// removeEmptyParents removes the directories marked removable that are
+// empty, deepest first, so a directory emptied by removing its
+// subdirectory goes too. A directory that is not removable keeps all its
+// ancestors.
+func removeEmptyParents(parents map[string]bool) {
+	dirs := slices.Collect(maps.Keys(parents))
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, dir := range dirs {
+		if !parents[dir] {
+			continue
+		}
+		if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+			continue
+		}
+		if err := os.Remove(dir); err == nil {
+			log.Debug("Removed empty directory", "path", dir)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemoveOrphanParents(t *testing.T) {
+	root := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	mkdir := func(rel string, mtime time.Time) string {
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(p, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	file := func(dir, name string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	emptyCategory := mkdir("empty-category", old)
+	newDir := mkdir("movies/new", time.Now())
+	excludedDir := mkdir("movies/keep.parts", old)
+	oldDir := mkdir("movies/old/sub", old)
+	orphans := []string{file(oldDir, "a.mkv"), file(newDir, "b.mkv"), file(excludedDir, "c.mkv")}
+	for _, dir := range []string{oldDir, filepath.Dir(oldDir), filepath.Join(root, "movies"), excludedDir} {
+		if err := os.Chtimes(dir, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &orphanScanner{minAge: time.Hour, excludes: []string{"*.parts"}}
+	parents := s.orphanParents(orphans, []string{root})
+	for _, p := range orphans {
+		if err := os.Remove(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removeEmptyParents(parents)
+
+	for _, p := range []string{emptyCategory, newDir, excludedDir, filepath.Join(root, "movies")} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s was removed", p)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "movies", "old")); !os.IsNotExist(err) {
+		t.Errorf("emptied directory was kept: %v", err)
+	}
+	if _, err := os.Stat(root); err != nil {
+		t.Error("scan root was removed")
+	}
+}
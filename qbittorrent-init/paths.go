@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// allowedPathRoots returns the directories --profile and --save-path must lie
+// under, from QBT_ALLOWED_PATH_ROOTS.
+func allowedPathRoots() []string {
+	return splitList(getEnv("QBT_ALLOWED_PATH_ROOTS", "/config,/downloads"))
+}
+
+func isValidPath(path string) bool {
+	resolved, err := resolveAllowedPath(path, allowedPathRoots())
+	if err != nil {
+		log.Warn("Rejected path", "path", path, "reason", err)
+		return false
+	}
+	log.Debug("Validated path", "path", path, "resolved", resolved)
+	return true
+}
+
+// resolveAllowedPath resolves symlinks in path and checks that the result is
+// under one of the roots, so a symlink inside a volume cannot point outside
+// of it. A missing directory is created.
+func resolveAllowedPath(path string, roots []string) (string, error) {
+	if path == "" {
+		return "", errors.New("empty path")
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	// Resolve the deepest existing ancestor; the remainder does not exist
+	// yet and so cannot contain symlinks.
+	existing, missing := abs, ""
+	var resolved string
+	for {
+		resolved, err = filepath.EvalSymlinks(existing)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) || existing == filepath.Dir(existing) {
+			return "", fmt.Errorf("failed to resolve path: %w", err)
+		}
+		missing = filepath.Join(filepath.Base(existing), missing)
+		existing = filepath.Dir(existing)
+	}
+	resolved = filepath.Join(resolved, missing)
+
+	if !underAnyRoot(resolved, roots) {
+		return "", fmt.Errorf("%s is outside the allowed roots %s", resolved, strings.Join(roots, ","))
+	}
+
+	if missing != "" && dryRun {
+		log.Info("Directory would be created", "path", resolved)
+		return resolved, nil
+	}
+	if missing != "" {
+		if err := os.MkdirAll(resolved, 0o755); err != nil {
+			return "", fmt.Errorf("failed to create directory: %w", err)
+		}
+		return resolved, nil
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", resolved)
+	}
+	return resolved, nil
+}
+
+func underAnyRoot(path string, roots []string) bool {
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		if r, err := filepath.EvalSymlinks(root); err == nil {
+			root = r
+		}
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) || root == "/" {
+			return true
+		}
+	}
+	return false
+}
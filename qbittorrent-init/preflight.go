@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// preflightBinary checks that qbittorrent-nox (QBT_BINARY) can be run and
+// that it is at least QBT_MIN_VERSION and QBT_MIN_LIBTORRENT_VERSION. Without
+// a minimum, an unreadable version is only a warning. The bundled binaries
+// are verified before anything is executed.
+func preflightBinary() error {
+	if err := verifyBinaries(); err != nil {
+		return fmt.Errorf("binary integrity check failed: %w", err)
+	}
+
+	info, err := os.Stat(qbittorrentBinary)
+	if err != nil {
+		return fmt.Errorf("qBittorrent binary not found: %w", err)
+	}
+	if info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("qBittorrent binary %s is not executable", qbittorrentBinary)
+	}
+
+	minVersion := os.Getenv("QBT_MIN_VERSION")
+	minLibtorrent := os.Getenv("QBT_MIN_LIBTORRENT_VERSION")
+
+	versions, err := qbittorrentVersions()
+	if err != nil {
+		if minVersion != "" || minLibtorrent != "" {
+			return fmt.Errorf("cannot check minimum version: %w", err)
+		}
+		log.Warn("Unable to determine qBittorrent version", "binary", qbittorrentBinary, "error", err)
+		return nil
+	}
+
+	attrs := []any{"binary", qbittorrentBinary, "qbittorrent_version", versions.qbittorrent}
+	if versions.libtorrent != "" {
+		attrs = append(attrs, "libtorrent_version", versions.libtorrent)
+	}
+	log.Info("Found qBittorrent", attrs...)
+
+	if minVersion != "" && compareVersions(versions.qbittorrent, minVersion) < 0 {
+		return fmt.Errorf("qBittorrent %s is older than the required %s (QBT_MIN_VERSION)", versions.qbittorrent, minVersion)
+	}
+	if minLibtorrent != "" {
+		if versions.libtorrent == "" {
+			log.Warn("qBittorrent does not report its libtorrent version, cannot check QBT_MIN_LIBTORRENT_VERSION")
+		} else if compareVersions(versions.libtorrent, minLibtorrent) < 0 {
+			return fmt.Errorf("libtorrent %s is older than the required %s (QBT_MIN_LIBTORRENT_VERSION)", versions.libtorrent, minLibtorrent)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// prestopStatePath is the file listing the torrents stopped by the prestop
+// command, so the next start can resume them instead of leaving everything
+// stopped. It sits in the profile directory next to their resume data.
+func prestopStatePath() string {
+	return filepath.Join(qbtDataDir(), ".prestop-stopped")
+}
+
+// runPrestopCommand stops all active torrents and waits for qBittorrent to
+// report them stopped, which makes it write their resume data. It is meant
+// for a Kubernetes preStop exec hook, so an eviction only has to stop an idle
+// session; the pod's terminationGracePeriodSeconds must cover --timeout.
+func runPrestopCommand(args []string) error {
+	flags := flag.NewFlagSet("prestop", flag.ContinueOnError)
+	timeout := flags.Duration("timeout", getEnvDuration("QBT_PRESTOP_TIMEOUT", time.Minute), "how long to wait for torrents to stop")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	client := newWebUIClient()
+
+	torrents, err := client.torrents(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to list torrents: %w", err)
+	}
+	var active []string
+	for _, t := range torrents {
+		if !isStoppedState(t.State) {
+			active = append(active, t.Hash)
+		}
+	}
+	if len(active) == 0 {
+		log.Info("No active torrents to stop")
+		return nil
+	}
+
+	// Recorded before stopping, so a hook killed halfway still lets the
+	// next start resume everything.
+	if err := recordPrestopTorrents(active); err != nil {
+		return err
+	}
+	if err := client.stopTorrents(ctx, active); err != nil {
+		return fmt.Errorf("failed to stop torrents: %w", err)
+	}
+	log.Info("Stopping torrents", "count", len(active))
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		remaining, err := countActive(ctx, client, active)
+		switch {
+		case err != nil:
+			log.Warn("Failed to check torrent states", "error", err)
+		case remaining == 0:
+			log.Info("All torrents stopped", "count", len(active))
+			return nil
+		default:
+			log.Debug("Waiting for torrents to stop", "remaining", remaining)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("torrents did not stop within %s", *timeout)
+		}
+	}
+}
+
+func countActive(ctx context.Context, client *webUIClient, hashes []string) (int, error) {
+	torrents, err := client.torrents(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	remaining := 0
+	for _, t := range torrents {
+		if !isStoppedState(t.State) && slices.Contains(hashes, t.Hash) {
+			remaining++
+		}
+	}
+	return remaining, nil
+}
+
+// recordPrestopTorrents adds hashes to the state file, keeping those from an
+// earlier run that were not resumed yet.
+func recordPrestopTorrents(hashes []string) error {
+	existing, err := readPrestopTorrents()
+	if err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if !slices.Contains(existing, h) {
+			existing = append(existing, h)
+		}
+	}
+	if err := os.WriteFile(prestopStatePath(), []byte(strings.Join(existing, "\n")+"\n"), 0o640); err != nil {
+		return fmt.Errorf("failed to record stopped torrents: %w", err)
+	}
+	return nil
+}
+
+func readPrestopTorrents() ([]string, error) {
+	data, err := os.ReadFile(prestopStatePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stopped torrents: %w", err)
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// resumePrestopTorrents starts the torrents a prestop hook stopped before the
+// last shutdown.
+func resumePrestopTorrents(ctx context.Context, client *webUIClient) error {
+	hashes, err := readPrestopTorrents()
+	if err != nil || len(hashes) == 0 {
+		return err
+	}
+	if err := client.startTorrents(ctx, hashes); err != nil {
+		return fmt.Errorf("failed to resume torrents: %w", err)
+	}
+	log.Info("Resumed torrents stopped before the last shutdown", "count", len(hashes))
+	return os.Remove(prestopStatePath())
+}
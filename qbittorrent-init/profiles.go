@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// balancedProfile suits a mixed library: a handful of active downloads, a
+// larger set of seeds, and connection limits a small host can sustain.
+var balancedProfile = map[string]any{
+	"queueing_enabled":              true,
+	"max_active_downloads":          5,
+	"max_active_uploads":            20,
+	"max_active_torrents":           25,
+	"dont_count_slow_torrents":      true,
+	"announce_to_all_trackers":      false,
+	"announce_to_all_tiers":         true,
+	"max_concurrent_http_announces": 50,
+	"max_connec":                    800,
+	"max_connec_per_torrent":        100,
+	"max_uploads":                   40,
+	"max_uploads_per_torrent":       8,
+	"upload_choking_algorithm":      1, // fastest upload
+	"upload_slots_behavior":         0, // fixed slots
+	"connection_speed":              30,
+	"send_buffer_watermark":         1024,
+	"send_buffer_low_watermark":     128,
+	"send_buffer_watermark_factor":  100,
+	"disk_queue_size":               2 << 20,
+}
+
+// preferenceProfiles are the presets selected with QBT_PROFILE, keyed by
+// name and holding WebUI API preferences.
+var preferenceProfiles = map[string]map[string]any{
+	// racing favours fresh torrents on busy trackers: no queueing, every
+	// tracker announced, many connections and large send buffers.
+	"racing": {
+		"queueing_enabled":              false,
+		"announce_to_all_trackers":      true,
+		"announce_to_all_tiers":         true,
+		"max_concurrent_http_announces": 200,
+		"max_connec":                    2000,
+		"max_connec_per_torrent":        300,
+		"max_uploads":                   -1,
+		"max_uploads_per_torrent":       -1,
+		"upload_choking_algorithm":      1, // fastest upload
+		"upload_slots_behavior":         1, // upload rate based
+		"connection_speed":              100,
+		"send_buffer_watermark":         5120,
+		"send_buffer_low_watermark":     1024,
+		"send_buffer_watermark_factor":  200,
+		"disk_queue_size":               4 << 20,
+	},
+	// archival keeps a large library seeding with few connections per
+	// torrent, spreading uploads across peers rather than chasing speed.
+	"archival": {
+		"queueing_enabled":              true,
+		"max_active_downloads":          2,
+		"max_active_uploads":            -1,
+		"max_active_torrents":           -1,
+		"dont_count_slow_torrents":      true,
+		"announce_to_all_trackers":      false,
+		"announce_to_all_tiers":         true,
+		"max_concurrent_http_announces": 50,
+		"max_connec":                    400,
+		"max_connec_per_torrent":        20,
+		"max_uploads":                   100,
+		"max_uploads_per_torrent":       4,
+		"upload_choking_algorithm":      2, // anti-leech
+		"upload_slots_behavior":         0, // fixed slots
+		"connection_speed":              20,
+		"send_buffer_watermark":         500,
+		"send_buffer_low_watermark":     10,
+		"send_buffer_watermark_factor":  50,
+		"disk_queue_size":               1 << 20,
+	},
+	"balanced": balancedProfile,
+	"default":  balancedProfile,
+}
+
+// profilePreferenceEnv maps profile preferences to the variables that also
+// write them to qBittorrent.conf. A set variable wins over the profile.
+var profilePreferenceEnv = map[string]string{
+	"queueing_enabled":         "QBT_QUEUEING_ENABLED",
+	"max_active_downloads":     "QBT_MAX_ACTIVE_DOWNLOADS",
+	"max_active_uploads":       "QBT_MAX_ACTIVE_UPLOADS",
+	"max_active_torrents":      "QBT_MAX_ACTIVE_TORRENTS",
+	"dont_count_slow_torrents": "QBT_IGNORE_SLOW_TORRENTS",
+	"max_connec":               "QBT_MAX_CONNECTIONS",
+	"max_connec_per_torrent":   "QBT_MAX_CONNECTIONS_PER_TORRENT",
+	"max_uploads":              "QBT_MAX_UPLOADS",
+	"max_uploads_per_torrent":  "QBT_MAX_UPLOADS_PER_TORRENT",
+}
+
+// profilePrefs returns the preferences of the QBT_PROFILE preset, minus any
+// the environment sets through its own variable, with the JSON object in
+// QBT_PROFILE_OVERRIDES layered on top.
+func profilePrefs() (map[string]any, error) {
+	prefs := make(map[string]any)
+	if name := os.Getenv("QBT_PROFILE"); name != "" {
+		profile, ok := preferenceProfiles[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown QBT_PROFILE %q: expected one of %s", name,
+				strings.Join(slices.Sorted(maps.Keys(preferenceProfiles)), ", "))
+		}
+		for pref, value := range profile {
+			if env, ok := profilePreferenceEnv[pref]; ok && os.Getenv(env) != "" {
+				continue
+			}
+			prefs[pref] = value
+		}
+	}
+
+	if raw := os.Getenv("QBT_PROFILE_OVERRIDES"); raw != "" {
+		var overrides map[string]any
+		if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+			return nil, fmt.Errorf("invalid QBT_PROFILE_OVERRIDES: expected a JSON object of preferences: %w", err)
+		}
+		maps.Copy(prefs, overrides)
+	}
+	return prefs, nil
+}
+
+// applyProfile sets the profile through the API once the WebUI is up.
+// qBittorrent saves the values, so they outlive a later change of profile
+// until set again.
+func applyProfile(ctx context.Context, client *webUIClient, prefs map[string]any) error {
+	if len(prefs) == 0 {
+		return nil
+	}
+	if err := client.setPreferences(ctx, prefs); err != nil {
+		return fmt.Errorf("failed to apply profile: %w", err)
+	}
+	log.Info("Applied preference profile", "profile", os.Getenv("QBT_PROFILE"), "preferences", len(prefs))
+	return nil
+}
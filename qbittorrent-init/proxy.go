@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var proxyTypes = map[string]string{
+	"none":   "None",
+	"http":   "HTTP",
+	"socks4": "SOCKS4",
+	"socks5": "SOCKS5",
+}
+
+// applyProxySettings writes the proxy from QBT_PROXY_TYPE, QBT_PROXY_HOST,
+// QBT_PROXY_PORT, QBT_PROXY_USERNAME and QBT_PROXY_PASSWORD to conf.
+// QBT_PROXY_PEER_CONNECTIONS also routes peer connections through it and
+// QBT_PROXY_ONLY_TORRENTS keeps RSS and other traffic off it. It reports
+// whether conf changed.
+func applyProxySettings(conf *iniFile) (bool, error) {
+	raw := os.Getenv("QBT_PROXY_TYPE")
+	if raw == "" {
+		return false, nil
+	}
+	proxyType, ok := proxyTypes[strings.ToLower(raw)]
+	if !ok {
+		return false, fmt.Errorf("invalid QBT_PROXY_TYPE=%q: expected none, http, socks4 or socks5", raw)
+	}
+
+	settings := [][3]string{{"Network", `Proxy\Type`, proxyType}}
+	if proxyType != "None" {
+		host := os.Getenv("QBT_PROXY_HOST")
+		if host == "" {
+			return false, errors.New("QBT_PROXY_HOST is required with QBT_PROXY_TYPE")
+		}
+		port := os.Getenv("QBT_PROXY_PORT")
+		if !isValidPort(port) {
+			return false, fmt.Errorf("invalid QBT_PROXY_PORT=%q", port)
+		}
+		settings = append(settings,
+			[3]string{"Network", `Proxy\IP`, host},
+			[3]string{"Network", `Proxy\Port`, port},
+		)
+
+		user := os.Getenv("QBT_PROXY_USERNAME")
+		if user != "" && proxyType == "SOCKS4" {
+			return false, errors.New("SOCKS4 proxies do not support authentication")
+		}
+		settings = append(settings,
+			[3]string{"Network", `Proxy\AuthEnabled`, strconv.FormatBool(user != "")},
+			[3]string{"Network", `Proxy\Username`, user},
+			[3]string{"Network", `Proxy\Password`, os.Getenv("QBT_PROXY_PASSWORD")},
+		)
+	}
+
+	if raw := os.Getenv("QBT_PROXY_PEER_CONNECTIONS"); raw != "" {
+		peers, err := strconv.ParseBool(raw)
+		if err != nil {
+			return false, fmt.Errorf("invalid QBT_PROXY_PEER_CONNECTIONS=%q: expected true or false", raw)
+		}
+		settings = append(settings, [3]string{"BitTorrent", `Session\ProxyPeerConnections`, strconv.FormatBool(peers)})
+	}
+	if raw := os.Getenv("QBT_PROXY_ONLY_TORRENTS"); raw != "" {
+		only, err := strconv.ParseBool(raw)
+		if err != nil {
+			return false, fmt.Errorf("invalid QBT_PROXY_ONLY_TORRENTS=%q: expected true or false", raw)
+		}
+		others := strconv.FormatBool(!only)
+		settings = append(settings,
+			[3]string{"Network", `Proxy\Profiles\BitTorrent`, "true"},
+			[3]string{"Network", `Proxy\Profiles\Misc`, others},
+			[3]string{"Network", `Proxy\Profiles\RSS`, others},
+		)
+	}
+
+	changed := false
+	for _, s := range settings {
+		if current, ok := conf.get(s[0], s[1]); ok && current == s[2] {
+			continue
+		}
+		conf.set(s[0], s[1], s[2])
+		changed = true
+	}
+	if changed {
+		log.Info("Applied proxy settings from environment", "type", proxyType, "host", os.Getenv("QBT_PROXY_HOST"))
+	}
+	return changed, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	pruneActionStop        = "stop"
+	pruneActionDelete      = "delete"
+	pruneActionDeleteFiles = "delete-files"
+)
+
+type prunePolicy struct {
+	Name           string  `json:"name"`
+	Category       string  `json:"category"`
+	Tracker        string  `json:"tracker"`
+	Tag            string  `json:"tag"`
+	MinRatio       float64 `json:"min_ratio"`
+	MinSeedingTime string  `json:"min_seeding_time"`
+	GracePeriod    string  `json:"grace_period"`
+	Action         string  `json:"action"`
+
+	minSeedingTime time.Duration
+	gracePeriod    time.Duration
+}
+
+type pruneEngine struct {
+	policies []prunePolicy
+	dryRun   bool
+}
+
+func loadPrunePolicies() ([]prunePolicy, error) {
+	raw := os.Getenv("QBT_PRUNE_POLICIES")
+	if file := os.Getenv("QBT_PRUNE_POLICIES_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prune policies file: %w", err)
+		}
+		raw = string(data)
+	}
+	if strings.TrimSpace(raw) == "" {
+		return nil, errors.New("no prune policies configured")
+	}
+
+	var policies []prunePolicy
+	if err := json.Unmarshal([]byte(raw), &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse prune policies: %w", err)
+	}
+
+	for i := range policies {
+		p := &policies[i]
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("policy-%d", i+1)
+		}
+		if p.Action == "" {
+			p.Action = pruneActionStop
+		}
+		switch p.Action {
+		case pruneActionStop, pruneActionDelete, pruneActionDeleteFiles:
+		default:
+			return nil, fmt.Errorf("policy %s: invalid action %q", p.Name, p.Action)
+		}
+
+		var err error
+		if p.MinSeedingTime != "" {
+			if p.minSeedingTime, err = time.ParseDuration(p.MinSeedingTime); err != nil {
+				return nil, fmt.Errorf("policy %s: invalid min_seeding_time: %w", p.Name, err)
+			}
+		}
+		if p.GracePeriod != "" {
+			if p.gracePeriod, err = time.ParseDuration(p.GracePeriod); err != nil {
+				return nil, fmt.Errorf("policy %s: invalid grace_period: %w", p.Name, err)
+			}
+		}
+		if p.MinRatio <= 0 && p.minSeedingTime <= 0 {
+			return nil, fmt.Errorf("policy %s: needs min_ratio or min_seeding_time", p.Name)
+		}
+	}
+
+	return policies, nil
+}
+
+func newPruneEngine() (*pruneEngine, error) {
+	policies, err := loadPrunePolicies()
+	if err != nil {
+		return nil, err
+	}
+	return &pruneEngine{
+		policies: policies,
+		dryRun:   getEnvBool("QBT_PRUNE_DRY_RUN", true),
+	}, nil
+}
+
+func (p *prunePolicy) matches(t torrentInfo) bool {
+	if p.Category != "" && p.Category != t.Category {
+		return false
+	}
+	if p.Tag != "" && !hasTag(t.Tags, p.Tag) {
+		return false
+	}
+	if p.Tracker != "" {
+		host := trackerHost(t.Tracker)
+		if host != p.Tracker && !strings.HasSuffix(host, "."+p.Tracker) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *prunePolicy) thresholdReached(t torrentInfo) bool {
+	if p.MinRatio > 0 && t.Ratio >= p.MinRatio {
+		return true
+	}
+	return p.minSeedingTime > 0 && time.Duration(t.SeedingTime)*time.Second >= p.minSeedingTime
+}
+
+func (e *pruneEngine) policyFor(t torrentInfo) *prunePolicy {
+	for i := range e.policies {
+		if e.policies[i].matches(t) {
+			return &e.policies[i]
+		}
+	}
+	return nil
+}
+
+func (e *pruneEngine) run(ctx context.Context, client *webUIClient) error {
+	torrents, err := client.torrents(ctx, url.Values{"filter": {"completed"}})
+	if err != nil {
+		return fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	now := time.Now()
+	actions := make(map[string][]string)
+
+	for _, t := range torrents {
+		policy := e.policyFor(t)
+		if policy == nil || !policy.thresholdReached(t) {
+			continue
+		}
+		if t.CompletionOn > 0 && now.Sub(time.Unix(t.CompletionOn, 0)) < policy.gracePeriod {
+			continue
+		}
+		if policy.Action == pruneActionStop && isStoppedState(t.State) {
+			continue
+		}
+
+		action := policy.Action
+		if action == pruneActionDeleteFiles {
+			linked, err := torrentHasExtraHardlinks(ctx, client, t)
+			if err != nil {
+				log.Warn("Hardlink check failed, skipping torrent", "hash", t.Hash, "error", err)
+				continue
+			}
+			if linked {
+				action = pruneActionDelete
+			}
+		}
+
+		log.Info("Torrent reached prune threshold",
+			"name", t.Name,
+			"hash", t.Hash,
+			"policy", policy.Name,
+			"ratio", t.Ratio,
+			"seeding_time", time.Duration(t.SeedingTime)*time.Second,
+			"action", action,
+			"dry_run", e.dryRun)
+
+		actions[action] = append(actions[action], t.Hash)
+	}
+
+	if e.dryRun {
+		return nil
+	}
+
+	for action, hashes := range actions {
+		var err error
+		switch action {
+		case pruneActionStop:
+			err = client.stopTorrents(ctx, hashes)
+		case pruneActionDelete, pruneActionDeleteFiles:
+			err = client.deleteTorrents(ctx, hashes, action == pruneActionDeleteFiles)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to apply %s to %d torrents: %w", action, len(hashes), err)
+		}
+	}
+	return nil
+}
+
+func isStoppedState(state string) bool {
+	switch state {
+	case "pausedUP", "pausedDL", "stoppedUP", "stoppedDL":
+		return true
+	default:
+		return false
+	}
+}
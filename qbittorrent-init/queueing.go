@@ -0,0 +1,19 @@
+package main
+
+var queueSettings = []sessionSetting{
+	{env: "QBT_QUEUEING_ENABLED", key: `Session\QueueingSystemEnabled`, boolean: true},
+	{env: "QBT_MAX_ACTIVE_DOWNLOADS", key: `Session\MaxActiveDownloads`, min: -1},
+	{env: "QBT_MAX_ACTIVE_UPLOADS", key: `Session\MaxActiveUploads`, min: -1},
+	{env: "QBT_MAX_ACTIVE_TORRENTS", key: `Session\MaxActiveTorrents`, min: -1},
+	{env: "QBT_IGNORE_SLOW_TORRENTS", key: `Session\IgnoreSlowTorrentsForQueueing`, boolean: true},
+	{env: "QBT_SLOW_TORRENT_DL_RATE", key: `Session\SlowTorrentsDownloadRate`},
+	{env: "QBT_SLOW_TORRENT_UL_RATE", key: `Session\SlowTorrentsUploadRate`},
+	{env: "QBT_SLOW_TORRENT_INACTIVE_TIME", key: `Session\SlowTorrentsInactivityTimer`},
+}
+
+// applyQueueSettings writes the queueing limits set in the environment to
+// conf. Slow torrent rates are in KiB/s and the inactivity time in seconds.
+// It reports whether conf changed.
+func applyQueueSettings(conf *iniFile) (bool, error) {
+	return writeSessionSettings(conf, queueSettings, nil)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type reannounceState struct {
+	attempts int
+	next     time.Time
+	gaveUp   bool
+}
+
+type stalledReannouncer struct {
+	window      time.Duration
+	baseDelay   time.Duration
+	maxAttempts int
+	pending     map[string]*reannounceState
+}
+
+func newStalledReannouncer() *stalledReannouncer {
+	return &stalledReannouncer{
+		window:      getEnvDuration("QBT_REANNOUNCE_WINDOW", 15*time.Minute),
+		baseDelay:   getEnvDuration("QBT_REANNOUNCE_DELAY", 10*time.Second),
+		maxAttempts: getEnvInt("QBT_REANNOUNCE_MAX_ATTEMPTS", 6),
+		pending:     make(map[string]*reannounceState),
+	}
+}
+
+func (r *stalledReannouncer) run(ctx context.Context, client *webUIClient) error {
+	torrents, err := client.torrents(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	now := time.Now()
+	active := make(map[string]bool, len(torrents))
+	var due []string
+
+	for _, t := range torrents {
+		expired := now.Sub(time.Unix(t.AddedOn, 0)) > r.window
+		if expired || !isStalledWithoutPeers(t) {
+			if state, ok := r.pending[t.Hash]; ok {
+				switch {
+				case t.NumSeeds > 0 || t.NumLeechs > 0:
+					log.Info("Torrent found peers after reannounce",
+						"name", t.Name, "hash", t.Hash, "attempts", state.attempts)
+				case expired && isStalledWithoutPeers(t):
+					r.giveUp(t, state)
+				default:
+					log.Debug("Torrent is no longer stalled", "name", t.Name, "hash", t.Hash, "state", t.State)
+				}
+				delete(r.pending, t.Hash)
+			}
+			continue
+		}
+		active[t.Hash] = true
+
+		state, ok := r.pending[t.Hash]
+		if !ok {
+			state = &reannounceState{}
+			r.pending[t.Hash] = state
+		}
+		if now.Before(state.next) {
+			continue
+		}
+		if state.attempts >= r.maxAttempts {
+			r.giveUp(t, state)
+			continue
+		}
+
+		state.attempts++
+		state.next = now.Add(r.baseDelay << (state.attempts - 1))
+		due = append(due, t.Hash)
+
+		log.Info("Reannouncing stalled torrent",
+			"name", t.Name,
+			"hash", t.Hash,
+			"attempt", state.attempts,
+			"max_attempts", r.maxAttempts)
+	}
+
+	for hash := range r.pending {
+		if !active[hash] {
+			delete(r.pending, hash)
+		}
+	}
+
+	if len(due) == 0 {
+		return nil
+	}
+	if err := client.reannounce(ctx, due); err != nil {
+		return fmt.Errorf("failed to reannounce torrents: %w", err)
+	}
+	return nil
+}
+
+// giveUp logs once that reannouncing did not find peers for t.
+func (r *stalledReannouncer) giveUp(t torrentInfo, state *reannounceState) {
+	if state.gaveUp {
+		return
+	}
+	state.gaveUp = true
+	log.Warn("Torrent still has no peers after reannouncing, giving up",
+		"name", t.Name, "hash", t.Hash, "attempts", state.attempts)
+}
+
+func isStalledWithoutPeers(t torrentInfo) bool {
+	switch t.State {
+	case "stalledDL", "metaDL", "downloading", "forcedDL":
+		return t.NumSeeds == 0 && t.NumLeechs == 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var remapResumeKeys = map[string]bool{
+	"save_path":        true,
+	"qBt-savePath":     true,
+	"qBt-downloadPath": true,
+}
+
+var remapConfigKeys = []string{
+	`Session\DefaultSavePath`,
+	`Session\TempPath`,
+	`Session\TorrentExportDirectory`,
+	`Session\FinishedTorrentExportDirectory`,
+}
+
+func runRemapPathsCommand(args []string) error {
+	flags := flag.NewFlagSet("remap-paths", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would change without writing anything")
+	var mappingFlags stringList
+	flags.Var(&mappingFlags, "map", "rewrite save paths, old=new (repeatable)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(mappingFlags) == 0 {
+		return errors.New("usage: qbittorrent-init remap-paths --map /old=/new [--map ...] [--dry-run]")
+	}
+
+	mappings, err := parsePathMappings(mappingFlags)
+	if err != nil {
+		return err
+	}
+
+	running, err := qbittorrentRunning()
+	if err != nil {
+		return err
+	}
+	if running {
+		return errors.New("qBittorrent is running, stop it before remapping save paths")
+	}
+
+	if !*dryRun {
+		archive, err := createBackupArchive(getEnv("QBT_BACKUP_DIR", defaultBackupDir), backupSources())
+		if err != nil {
+			return fmt.Errorf("failed to back up state before remapping: %w", err)
+		}
+		log.Info("Backed up state before remapping", "archive", archive)
+	}
+
+	remap := func(p string) (string, bool) {
+		if p == "" {
+			return p, false
+		}
+		mapped := applyPathMappings(p, mappings)
+		if mapped == p {
+			return p, false
+		}
+		if strings.HasSuffix(p, "/") && !strings.HasSuffix(mapped, "/") {
+			mapped += "/"
+		}
+		return mapped, true
+	}
+
+	var changed int
+	if resumeStorageIsSQLite() {
+		changed, err = remapSQLiteResume(filepath.Join(qbtDataDir(), "torrents.db"), remap, *dryRun)
+	} else {
+		changed, err = remapFastresume(filepath.Join(qbtDataDir(), "BT_backup"), remap, *dryRun)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := remapConfig(defaultConfigPath, remap, *dryRun); err != nil {
+		return err
+	}
+	if err := remapCategories(filepath.Join(qbtConfigDir(), "categories.json"), remap, *dryRun); err != nil {
+		return err
+	}
+
+	log.Info("Save path remapping completed", "torrents", changed, "dry_run", *dryRun)
+	return nil
+}
+
+// qbittorrentRunning looks for a qbittorrent-nox process in /proc. Run
+// remap-paths in the qBittorrent container, or in a pod sharing its process
+// namespace, for the check to see it.
+func qbittorrentRunning() (bool, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return false, fmt.Errorf("failed to list processes: %w", err)
+	}
+	name := filepath.Base(qbittorrentBinary)
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		// comm is cut to 15 bytes, which qbittorrent-nox just fits.
+		comm, err := os.ReadFile(filepath.Join("/proc", e.Name(), "comm"))
+		if err == nil && strings.TrimSpace(string(comm)) == name[:min(len(name), 15)] {
+			return true, nil
+		}
+		cmdline, err := os.ReadFile(filepath.Join("/proc", e.Name(), "cmdline"))
+		if argv0, _, _ := bytes.Cut(cmdline, []byte{0}); err == nil && filepath.Base(string(argv0)) == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func remapResumeData(data []byte, remap func(string) (string, bool)) ([]byte, bool, error) {
+	return brewriteStrings(data, func(key, value string) (string, bool) {
+		if !remapResumeKeys[key] {
+			return value, false
+		}
+		return remap(value)
+	})
+}
+
+func remapFastresume(dir string, remap func(string) (string, bool), dryRun bool) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	changed := 0
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) != ".fastresume" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return changed, err
+		}
+		updated, ok, err := remapResumeData(data, remap)
+		if err != nil {
+			log.Warn("Skipping unreadable resume file", "path", path, "error", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		log.Info("Remapping resume data", "hash", strings.TrimSuffix(e.Name(), ".fastresume"), "dry_run", dryRun)
+		changed++
+		if dryRun {
+			continue
+		}
+
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, updated, 0644); err != nil {
+			return changed, fmt.Errorf("failed to write %s: %w", tmp, err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return changed, fmt.Errorf("failed to replace %s: %w", path, err)
+		}
+	}
+	return changed, nil
+}
+
+func remapSQLiteResume(dbPath string, remap func(string) (string, bool), dryRun bool) (int, error) {
+	update := func(row map[string]any) bool {
+		changed := false
+		for _, column := range []string{"target_save_path", "download_path"} {
+			if s, ok := row[column].(string); ok {
+				if mapped, ok := remap(s); ok {
+					row[column] = mapped
+					changed = true
+				}
+			}
+		}
+		if blob, ok := row["libtorrent_resume_data"].([]byte); ok {
+			updated, ok, err := remapResumeData(blob, remap)
+			if err != nil {
+				log.Warn("Skipping unreadable resume data", "hash", sqliteText(row["torrent_id"]), "error", err)
+			} else if ok {
+				row["libtorrent_resume_data"] = updated
+				changed = true
+			}
+		}
+		if changed {
+			log.Info("Remapping resume data", "hash", sqliteText(row["torrent_id"]), "name", sqliteText(row["name"]), "dry_run", dryRun)
+		}
+		return changed
+	}
+
+	if dryRun {
+		rows, err := readSQLiteTable(dbPath, "torrents")
+		if err != nil {
+			return 0, fmt.Errorf("failed to read resume database: %w", err)
+		}
+		changed := 0
+		for _, row := range rows {
+			if update(row) {
+				changed++
+			}
+		}
+		return changed, nil
+	}
+
+	changed, err := rewriteSQLiteTable(dbPath, "torrents", update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rewrite resume database: %w", err)
+	}
+	return changed, nil
+}
+
+func remapConfig(path string, remap func(string) (string, bool), dryRun bool) error {
+	conf, err := readINIFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	changed := false
+	for _, key := range remapConfigKeys {
+		value, ok := conf.get("BitTorrent", key)
+		if !ok {
+			continue
+		}
+		if mapped, ok := remap(value); ok {
+			log.Info("Remapping config path", "key", key, "old", value, "new", mapped, "dry_run", dryRun)
+			conf.set("BitTorrent", key, mapped)
+			changed = true
+		}
+	}
+	if !changed || dryRun {
+		return nil
+	}
+	return conf.writeFile(path)
+}
+
+func remapCategories(path string, remap func(string) (string, bool), dryRun bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var categories map[string]map[string]any
+	if err := json.Unmarshal(data, &categories); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	changed := false
+	for name, options := range categories {
+		for _, key := range []string{"save_path", "download_path"} {
+			value, ok := options[key].(string)
+			if !ok {
+				continue
+			}
+			if mapped, ok := remap(value); ok {
+				log.Info("Remapping category path", "category", name, "key", key, "old", value, "new", mapped, "dry_run", dryRun)
+				options[key] = mapped
+				changed = true
+			}
+		}
+	}
+	if !changed || dryRun {
+		return nil
+	}
+
+	out, err := json.MarshalIndent(categories, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
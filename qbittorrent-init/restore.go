@@ -0,0 +1,281 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func runRestoreCommand(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	force := flags.Bool("force", false, "overwrite existing state even if it is newer than the backup")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: qbittorrent-init restore [--force] <archive>")
+	}
+	archivePath := flags.Arg(0)
+
+	running, err := qbittorrentRunning()
+	if err != nil {
+		return err
+	}
+	if running {
+		return errors.New("qBittorrent is running, stop it before restoring a backup")
+	}
+
+	manifest, err := validateBackupArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("invalid backup archive: %w", err)
+	}
+	log.Info("Backup archive validated",
+		"path", archivePath,
+		"created_at", manifest.CreatedAt,
+		"files", len(manifest.Files))
+
+	if current, ok := latestStateModTime(); ok && current.After(manifest.CreatedAt) && !*force {
+		return fmt.Errorf("existing state was modified at %s, after the backup was created (%s); use --force to overwrite",
+			current.UTC().Format(time.RFC3339), manifest.CreatedAt.Format(time.RFC3339))
+	}
+
+	if _, err := restoreBackup(archivePath, backupSources()); err != nil {
+		return err
+	}
+
+	log.Info("Restore completed successfully", "archive", archivePath)
+	return nil
+}
+
+func restoreBackup(archivePath string, units []string) ([]string, error) {
+	staging, err := os.MkdirTemp(backupRoot, ".restore-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := extractBackupArchive(archivePath, staging); err != nil {
+		return nil, fmt.Errorf("failed to extract archive: %w", err)
+	}
+
+	var restored []string
+	uid, gid := restoreOwnership()
+	for _, dest := range units {
+		rel, err := filepath.Rel(backupRoot, dest)
+		if err != nil {
+			return restored, err
+		}
+		src := filepath.Join(staging, rel)
+		if _, err := os.Lstat(src); os.IsNotExist(err) {
+			continue
+		}
+
+		if err := chownTree(src, uid, gid); err != nil {
+			return restored, fmt.Errorf("failed to set ownership on %s: %w", rel, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return restored, fmt.Errorf("failed to create %s: %w", filepath.Dir(dest), err)
+		}
+		if err := os.RemoveAll(dest); err != nil {
+			return restored, fmt.Errorf("failed to remove existing %s: %w", dest, err)
+		}
+		if err := os.Rename(src, dest); err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", dest, err)
+		}
+		restored = append(restored, dest)
+		log.Info("Restored path", "path", dest)
+
+		if filepath.Base(dest) == "torrents.db" {
+			for _, suffix := range []string{"-wal", "-shm"} {
+				if _, err := os.Lstat(src + suffix); os.IsNotExist(err) {
+					os.Remove(dest + suffix)
+				}
+			}
+		}
+	}
+	return restored, nil
+}
+
+func openBackupArchive(archivePath string) (*tar.Reader, func(), error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("not a gzip archive: %w", err)
+	}
+	return tar.NewReader(gz), func() { gz.Close(); f.Close() }, nil
+}
+
+func validateArchiveEntry(hdr *tar.Header) error {
+	name := hdr.Name
+	if name == backupManifestKey {
+		return nil
+	}
+	clean := path.Clean(name)
+	if path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("entry %q escapes the restore root", name)
+	}
+	if !backupEntryAllowed(clean) {
+		return fmt.Errorf("unexpected entry %q", name)
+	}
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeDir:
+		return nil
+	default:
+		return fmt.Errorf("entry %q has unsupported type %c", name, hdr.Typeflag)
+	}
+}
+
+// backupEntryAllowed reports whether name, a cleaned archive path, is one of
+// backupSources or lies below one, wherever QBT_DATA_DIR puts them.
+func backupEntryAllowed(name string) bool {
+	for _, src := range backupSources() {
+		rel, err := filepath.Rel(backupRoot, src)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if name == rel || strings.HasPrefix(name, rel+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func validateBackupArchive(archivePath string) (*backupManifest, error) {
+	tr, closeFn, err := openBackupArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	var manifest *backupManifest
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("corrupt tar stream: %w", err)
+		}
+		if err := validateArchiveEntry(hdr); err != nil {
+			return nil, err
+		}
+
+		if hdr.Name == backupManifestKey {
+			manifest = &backupManifest{}
+			if err := json.NewDecoder(io.LimitReader(tr, 1<<20)).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("invalid manifest: %w", err)
+			}
+			continue
+		}
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return nil, fmt.Errorf("corrupt entry %q: %w", hdr.Name, err)
+		}
+	}
+
+	if manifest == nil {
+		return nil, errors.New("manifest.json not found")
+	}
+	return manifest, nil
+}
+
+func extractBackupArchive(archivePath, dest string) error {
+	tr, closeFn, err := openBackupArchive(archivePath)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Name == backupManifestKey {
+			continue
+		}
+		if err := validateArchiveEntry(hdr); err != nil {
+			return err
+		}
+
+		target := filepath.Join(dest, filepath.FromSlash(path.Clean(hdr.Name)))
+		if hdr.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(hdr.Mode).Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+	}
+}
+
+func latestStateModTime() (time.Time, bool) {
+	var latest time.Time
+	for _, src := range backupSources() {
+		filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info, err := d.Info(); err == nil && info.ModTime().After(latest) {
+				latest = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return latest, !latest.IsZero()
+}
+
+func restoreOwnership() (int, int) {
+	uid, gid := -1, -1
+	if info, err := os.Stat(backupRoot); err == nil {
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(st.Uid), int(st.Gid)
+		}
+	}
+	return getEnvInt("PUID", uid), getEnvInt("PGID", gid)
+}
+
+func chownTree(root string, uid, gid int) error {
+	if uid < 0 && gid < 0 {
+		return nil
+	}
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(p, uid, gid)
+	})
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type resumeEntry struct {
+	Hash         string
+	Name         string
+	SavePath     string
+	DownloadPath string
+	Resume       map[string]any
+	Info         map[string]any
+	ResumePath   string
+	TorrentPath  string
+}
+
+type contentFile struct {
+	Path   string
+	Length int64
+}
+
+func resumeStorageIsSQLite() bool {
+	_, err := os.Stat(filepath.Join(qbtDataDir(), "torrents.db"))
+	return err == nil
+}
+
+func loadResumeEntries() ([]resumeEntry, []resumeIssue, error) {
+	if resumeStorageIsSQLite() {
+		return loadSQLiteResumeEntries(filepath.Join(qbtDataDir(), "torrents.db"))
+	}
+	return loadFastresumeEntries(filepath.Join(qbtDataDir(), "BT_backup"))
+}
+
+func loadFastresumeEntries(dir string) ([]resumeEntry, []resumeIssue, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	resumeFiles := make(map[string]bool)
+	torrentFiles := make(map[string]bool)
+	for _, e := range dirEntries {
+		name := e.Name()
+		switch filepath.Ext(name) {
+		case ".fastresume":
+			resumeFiles[strings.TrimSuffix(name, ".fastresume")] = true
+		case ".torrent":
+			torrentFiles[strings.TrimSuffix(name, ".torrent")] = true
+		}
+	}
+
+	var (
+		entries []resumeEntry
+		issues  []resumeIssue
+	)
+
+	for hash := range torrentFiles {
+		if !resumeFiles[hash] {
+			issues = append(issues, resumeIssue{
+				Hash:  hash,
+				Issue: "orphaned .torrent without resume data",
+				Path:  filepath.Join(dir, hash+".torrent"),
+			})
+		}
+	}
+
+	for hash := range resumeFiles {
+		entry := resumeEntry{
+			Hash:       hash,
+			ResumePath: filepath.Join(dir, hash+".fastresume"),
+		}
+
+		data, err := os.ReadFile(entry.ResumePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		resume, err := bdecode(data)
+		if err != nil {
+			issues = append(issues, resumeIssue{Hash: hash, Issue: "corrupt resume data: " + err.Error(), Path: entry.ResumePath})
+			continue
+		}
+		entry.Resume, _ = resume.(map[string]any)
+		entry.Info = bdictDict(entry.Resume, "info")
+
+		if torrentFiles[hash] {
+			entry.TorrentPath = filepath.Join(dir, hash+".torrent")
+			data, err := os.ReadFile(entry.TorrentPath)
+			if err != nil {
+				return nil, nil, err
+			}
+			torrent, err := bdecode(data)
+			if err != nil {
+				issues = append(issues, resumeIssue{Hash: hash, Issue: "corrupt .torrent file: " + err.Error(), Path: entry.TorrentPath})
+				continue
+			}
+			if t, ok := torrent.(map[string]any); ok {
+				entry.Info = bdictDict(t, "info")
+			}
+		}
+
+		entry.fillFromResume()
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Hash < entries[j].Hash })
+	return entries, issues, nil
+}
+
+func loadSQLiteResumeEntries(dbPath string) ([]resumeEntry, []resumeIssue, error) {
+	rows, err := readSQLiteTable(dbPath, "torrents")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read resume database: %w", err)
+	}
+
+	var (
+		entries []resumeEntry
+		issues  []resumeIssue
+	)
+	for _, row := range rows {
+		entry := resumeEntry{
+			Hash:         sqliteText(row["torrent_id"]),
+			Name:         sqliteText(row["name"]),
+			SavePath:     sqliteText(row["target_save_path"]),
+			DownloadPath: sqliteText(row["download_path"]),
+			ResumePath:   dbPath,
+		}
+
+		if blob, ok := row["libtorrent_resume_data"].([]byte); ok {
+			resume, err := bdecode(blob)
+			if err != nil {
+				issues = append(issues, resumeIssue{Hash: entry.Hash, Name: entry.Name, Issue: "corrupt resume data: " + err.Error(), Path: dbPath})
+				continue
+			}
+			entry.Resume, _ = resume.(map[string]any)
+		}
+		if blob, ok := row["metadata"].([]byte); ok && len(blob) > 0 {
+			torrent, err := bdecode(blob)
+			if err != nil {
+				issues = append(issues, resumeIssue{Hash: entry.Hash, Name: entry.Name, Issue: "corrupt metadata: " + err.Error(), Path: dbPath})
+				continue
+			}
+			if t, ok := torrent.(map[string]any); ok {
+				entry.Info = bdictDict(t, "info")
+			}
+		}
+
+		entry.fillFromResume()
+		entries = append(entries, entry)
+	}
+	return entries, issues, nil
+}
+
+func sqliteText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return ""
+	}
+}
+
+func (e *resumeEntry) fillFromResume() {
+	if e.Resume == nil {
+		return
+	}
+	if e.SavePath == "" {
+		e.SavePath = bdictString(e.Resume, "qBt-savePath")
+	}
+	if e.SavePath == "" {
+		e.SavePath = bdictString(e.Resume, "save_path")
+	}
+	if e.DownloadPath == "" {
+		e.DownloadPath = bdictString(e.Resume, "qBt-downloadPath")
+	}
+	if e.Name == "" {
+		e.Name = bdictString(e.Resume, "qBt-name")
+	}
+	if e.Name == "" {
+		e.Name = bdictString(e.Resume, "name")
+	}
+	if e.Name == "" && e.Info != nil {
+		e.Name = bdictString(e.Info, "name")
+	}
+	if e.Hash == "" {
+		e.Hash = hex.EncodeToString([]byte(bdictString(e.Resume, "info-hash")))
+	}
+}
+
+func (e *resumeEntry) hasDownloadedPieces() bool {
+	pieces := bdictString(e.Resume, "pieces")
+	for i := 0; i < len(pieces); i++ {
+		if pieces[i]&1 != 0 {
+			return true
+		}
+	}
+	return bdictInt(e.Resume, "seed_mode") == 1
+}
+
+func (e *resumeEntry) contentFiles() []contentFile {
+	if e.Info == nil {
+		return nil
+	}
+
+	name := bdictString(e.Info, "name")
+	var files []contentFile
+	// indices holds each file's index in the torrent, which mapped_files
+	// follows; pad files are skipped but keep their index.
+	var indices []int
+
+	if list := bdictList(e.Info, "files"); list != nil {
+		for index, item := range list {
+			f, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			parts := []string{name}
+			for _, p := range bdictList(f, "path") {
+				if s, ok := p.(string); ok {
+					parts = append(parts, s)
+				}
+			}
+			attr := bdictString(f, "attr")
+			if strings.Contains(attr, "p") {
+				continue
+			}
+			files = append(files, contentFile{Path: filepath.Join(parts...), Length: bdictInt(f, "length")})
+			indices = append(indices, index)
+		}
+	} else if tree := bdictDict(e.Info, "file tree"); tree != nil {
+		walkFileTree(tree, []string{}, &files)
+		if len(files) > 1 || (len(files) == 1 && files[0].Path != name) {
+			for i := range files {
+				files[i].Path = filepath.Join(name, files[i].Path)
+			}
+		}
+	} else {
+		files = append(files, contentFile{Path: name, Length: bdictInt(e.Info, "length")})
+	}
+
+	mapped := bdictList(e.Resume, "mapped_files")
+	for i := range files {
+		index := i
+		if indices != nil {
+			index = indices[i]
+		}
+		if index < len(mapped) {
+			if p, ok := mapped[index].(string); ok && p != "" {
+				files[i].Path = filepath.FromSlash(p)
+			}
+		}
+	}
+	return files
+}
+
+func walkFileTree(tree map[string]any, prefix []string, files *[]contentFile) {
+	keys := make([]string, 0, len(tree))
+	for k := range tree {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		node, ok := tree[k].(map[string]any)
+		if !ok {
+			continue
+		}
+		if leaf := bdictDict(node, ""); leaf != nil && k != "" {
+			*files = append(*files, contentFile{
+				Path:   filepath.Join(append(append([]string{}, prefix...), k)...),
+				Length: bdictInt(leaf, "length"),
+			})
+			continue
+		}
+		walkFileTree(node, append(prefix, k), files)
+	}
+}
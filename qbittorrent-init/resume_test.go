@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestContentFilesMappedAfterPadFile(t *testing.T) {
+	file := func(attr string, length int64, path ...any) map[string]any {
+		return map[string]any{"attr": attr, "length": length, "path": path}
+	}
+	e := &resumeEntry{
+		Info: map[string]any{
+			"name": "album",
+			"files": []any{
+				file("", 100, "a.flac"),
+				file("p", 12, ".pad", "12"),
+				file("", 200, "b.flac"),
+			},
+		},
+		Resume: map[string]any{
+			"mapped_files": []any{"", "", "album/renamed.flac"},
+		},
+	}
+
+	var got []string
+	for _, f := range e.contentFiles() {
+		got = append(got, f.Path)
+	}
+	want := []string{filepath.Join("album", "a.flac"), filepath.Join("album", "renamed.flac")}
+	if !slices.Equal(got, want) {
+		t.Errorf("contentFiles() = %q, want %q", got, want)
+	}
+}
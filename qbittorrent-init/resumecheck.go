@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type resumeIssue struct {
+	Hash  string
+	Name  string
+	Issue string
+	Path  string
+}
+
+func runCheckResumeCommand(args []string) error {
+	flags := flag.NewFlagSet("check-resume", flag.ContinueOnError)
+	skipContent := flags.Bool("skip-content", false, "only check resume data and .torrent files, not content on disk")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	entries, issues, err := loadResumeEntries()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.Info == nil {
+			issues = append(issues, resumeIssue{
+				Hash:  e.Hash,
+				Name:  e.Name,
+				Issue: "no torrent metadata (.torrent file missing)",
+				Path:  e.ResumePath,
+			})
+			continue
+		}
+		if *skipContent || !e.hasDownloadedPieces() {
+			continue
+		}
+		issues = append(issues, checkEntryContent(e)...)
+	}
+
+	for _, issue := range issues {
+		log.Warn("Resume data inconsistency",
+			"hash", issue.Hash,
+			"name", issue.Name,
+			"issue", issue.Issue,
+			"path", issue.Path)
+	}
+
+	log.Info("Resume data check completed",
+		"torrents", len(entries),
+		"issues", len(issues))
+
+	if len(issues) > 0 {
+		return fmt.Errorf("found %d inconsistencies", len(issues))
+	}
+	return nil
+}
+
+func checkEntryContent(e resumeEntry) []resumeIssue {
+	if e.SavePath == "" {
+		return []resumeIssue{{Hash: e.Hash, Name: e.Name, Issue: "resume data has no save path", Path: e.ResumePath}}
+	}
+
+	var issues []resumeIssue
+	for _, f := range e.contentFiles() {
+		path, info, err := locateContentFile(e, f)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			issues = append(issues, resumeIssue{Hash: e.Hash, Name: e.Name, Issue: "content file missing", Path: path})
+		case err != nil:
+			issues = append(issues, resumeIssue{Hash: e.Hash, Name: e.Name, Issue: "content file unreadable: " + err.Error(), Path: path})
+		case info.Size() > f.Length:
+			issues = append(issues, resumeIssue{
+				Hash:  e.Hash,
+				Name:  e.Name,
+				Issue: fmt.Sprintf("content file larger than expected (%d > %d bytes)", info.Size(), f.Length),
+				Path:  path,
+			})
+		}
+	}
+	return issues
+}
+
+func locateContentFile(e resumeEntry, f contentFile) (string, os.FileInfo, error) {
+	primary := filepath.Join(e.SavePath, f.Path)
+
+	candidates := []string{primary, primary + ".!qB"}
+	if e.DownloadPath != "" {
+		incomplete := filepath.Join(e.DownloadPath, f.Path)
+		candidates = append(candidates, incomplete, incomplete+".!qB")
+	}
+
+	var lastErr error
+	for _, p := range candidates {
+		info, err := os.Stat(p)
+		if err == nil {
+			return p, info, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return p, nil, err
+		}
+		lastErr = err
+	}
+	return primary, nil, lastErr
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// passwordRotator replaces the WebUI password once the secret file is older
+// than maxAge. The new password is staged next to the file, applied through
+// the API and only then moved into place, so the file never holds a password
+// qBittorrent doesn't accept.
+type passwordRotator struct {
+	path   string
+	maxAge time.Duration
+}
+
+func newPasswordRotator() *passwordRotator {
+	return &passwordRotator{
+		path:   getEnv("QBT_PASSWORD_ROTATION_FILE", "/config/secrets/webui-password"),
+		maxAge: getEnvDuration("QBT_PASSWORD_ROTATION_INTERVAL", 30*24*time.Hour),
+	}
+}
+
+func (p *passwordRotator) run(ctx context.Context, client *webUIClient) error {
+	if info, err := os.Stat(p.path); err == nil && time.Since(info.ModTime()) < p.maxAge {
+		return nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check password file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create secret directory: %w", err)
+	}
+	staged, err := os.CreateTemp(filepath.Dir(p.path), ".webui-password-*")
+	if err != nil {
+		return fmt.Errorf("failed to stage password: %w", err)
+	}
+	applied := false
+	defer func() {
+		// Once applied, the staged file may be the only copy of the password.
+		if !applied {
+			os.Remove(staged.Name())
+		}
+	}()
+
+	password := rand.Text()
+	if _, err := staged.WriteString(password + "\n"); err != nil {
+		staged.Close()
+		return fmt.Errorf("failed to stage password: %w", err)
+	}
+	if err := staged.Close(); err != nil {
+		return fmt.Errorf("failed to stage password: %w", err)
+	}
+
+	if err := client.setPreferences(ctx, map[string]any{"web_ui_password": password}); err != nil {
+		return fmt.Errorf("failed to apply new password: %w", err)
+	}
+	applied = true
+	if err := os.Rename(staged.Name(), p.path); err != nil {
+		return fmt.Errorf("password was changed but could not be moved from %s to %s: %w", staged.Name(), p.path, err)
+	}
+	log.Info("Rotated WebUI password", "path", p.path, "next_rotation", time.Now().Add(p.maxAge))
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedulerDays lists the QBT_SCHEDULE_DAYS values in the order of the API's
+// scheduler_days enum.
+var schedulerDays = []string{
+	"every_day", "weekdays", "weekends",
+	"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday",
+}
+
+// speedSchedulePrefs returns the alternative speed limit and scheduler
+// preferences set in the environment. Limits are in KiB/s, with 0 meaning
+// unlimited; times are HH:MM.
+func speedSchedulePrefs() (map[string]any, error) {
+	prefs := make(map[string]any)
+
+	for _, l := range []struct{ env, pref string }{
+		{"QBT_ALT_DL_LIMIT", "alt_dl_limit"},
+		{"QBT_ALT_UP_LIMIT", "alt_up_limit"},
+	} {
+		raw := os.Getenv(l.env)
+		if raw == "" {
+			continue
+		}
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid %s=%q: expected a limit in KiB/s", l.env, raw)
+		}
+		prefs[l.pref] = limit
+	}
+
+	if raw := os.Getenv("QBT_SCHEDULER_ENABLED"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid QBT_SCHEDULER_ENABLED=%q: expected true or false", raw)
+		}
+		prefs["scheduler_enabled"] = enabled
+	}
+
+	for _, t := range []struct{ env, prefix string }{
+		{"QBT_SCHEDULE_FROM", "schedule_from"},
+		{"QBT_SCHEDULE_TO", "schedule_to"},
+	} {
+		raw := os.Getenv(t.env)
+		if raw == "" {
+			continue
+		}
+		at, err := time.Parse("15:04", raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s=%q: expected HH:MM", t.env, raw)
+		}
+		prefs[t.prefix+"_hour"] = at.Hour()
+		prefs[t.prefix+"_min"] = at.Minute()
+	}
+
+	if raw := os.Getenv("QBT_SCHEDULE_DAYS"); raw != "" {
+		days := slices.Index(schedulerDays, strings.ToLower(strings.ReplaceAll(raw, "-", "_")))
+		if days < 0 {
+			return nil, fmt.Errorf("invalid QBT_SCHEDULE_DAYS=%q: expected one of %s", raw, strings.Join(schedulerDays, ", "))
+		}
+		prefs["scheduler_days"] = days
+	}
+
+	return prefs, nil
+}
+
+// applySpeedSchedule sets the configured preferences through the API once the
+// WebUI is up, since qBittorrent stores the schedule times as binary Qt
+// variants that can't sensibly be written to qBittorrent.conf.
+func applySpeedSchedule(ctx context.Context, client *webUIClient, prefs map[string]any) error {
+	if len(prefs) == 0 {
+		return nil
+	}
+	if err := client.setPreferences(ctx, prefs); err != nil {
+		return fmt.Errorf("failed to apply speed schedule: %w", err)
+	}
+	log.Info("Applied speed limit schedule", "preferences", prefs)
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+const seccompAuditArch = 0xc000003e // AUDIT_ARCH_X86_64
+
+var seccompDeniedSyscalls = map[string]uint32{
+	"ptrace":            101,
+	"syslog":            103,
+	"vhangup":           153,
+	"pivot_root":        155,
+	"adjtimex":          159,
+	"chroot":            161,
+	"acct":              163,
+	"settimeofday":      164,
+	"mount":             165,
+	"umount2":           166,
+	"swapon":            167,
+	"swapoff":           168,
+	"reboot":            169,
+	"sethostname":       170,
+	"setdomainname":     171,
+	"iopl":              172,
+	"ioperm":            173,
+	"init_module":       175,
+	"delete_module":     176,
+	"quotactl":          179,
+	"lookup_dcookie":    212,
+	"clock_settime":     227,
+	"kexec_load":        246,
+	"add_key":           248,
+	"request_key":       249,
+	"keyctl":            250,
+	"unshare":           272,
+	"perf_event_open":   298,
+	"name_to_handle_at": 303,
+	"open_by_handle_at": 304,
+	"clock_adjtime":     305,
+	"setns":             308,
+	"process_vm_readv":  310,
+	"process_vm_writev": 311,
+	"kcmp":              312,
+	"finit_module":      313,
+	"kexec_file_load":   320,
+	"bpf":               321,
+	"userfaultfd":       323,
+	"open_tree":         428,
+	"move_mount":        429,
+	"fsopen":            430,
+	"fsconfig":          431,
+	"fsmount":           432,
+	"fspick":            433,
+	"mount_setattr":     442,
+}
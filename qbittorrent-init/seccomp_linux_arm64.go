@@ -0,0 +1,50 @@
+package main
+
+const seccompAuditArch = 0xc00000b7 // AUDIT_ARCH_AARCH64
+
+var seccompDeniedSyscalls = map[string]uint32{
+	"lookup_dcookie":    18,
+	"umount2":           39,
+	"mount":             40,
+	"pivot_root":        41,
+	"chroot":            51,
+	"vhangup":           58,
+	"quotactl":          60,
+	"acct":              89,
+	"unshare":           97,
+	"kexec_load":        104,
+	"init_module":       105,
+	"delete_module":     106,
+	"clock_settime":     112,
+	"syslog":            116,
+	"ptrace":            117,
+	"reboot":            142,
+	"sethostname":       161,
+	"setdomainname":     162,
+	"settimeofday":      170,
+	"adjtimex":          171,
+	"add_key":           217,
+	"request_key":       218,
+	"keyctl":            219,
+	"swapon":            224,
+	"swapoff":           225,
+	"perf_event_open":   241,
+	"name_to_handle_at": 264,
+	"open_by_handle_at": 265,
+	"clock_adjtime":     266,
+	"setns":             268,
+	"process_vm_readv":  270,
+	"process_vm_writev": 271,
+	"kcmp":              272,
+	"finit_module":      273,
+	"bpf":               280,
+	"userfaultfd":       282,
+	"kexec_file_load":   294,
+	"open_tree":         428,
+	"move_mount":        429,
+	"fsopen":            430,
+	"fsconfig":          431,
+	"fsmount":           432,
+	"fspick":            433,
+	"mount_setattr":     442,
+}
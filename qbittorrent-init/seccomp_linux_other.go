@@ -0,0 +1,7 @@
+//go:build linux && !amd64 && !arm64
+
+package main
+
+const seccompAuditArch = 0
+
+var seccompDeniedSyscalls map[string]uint32
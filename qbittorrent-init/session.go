@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sessionSetting maps an environment variable to a [BitTorrent] key holding a
+// boolean, an integer of at least min, or, when choices is set, the named
+// choice. qBittorrent stores a choice as the matching entry of values, or as
+// its index when the key holds an integer.
+type sessionSetting struct {
+	env     string
+	key     string
+	boolean bool
+	min     int
+	choices []string
+	values  []string
+}
+
+var sessionSettings = []sessionSetting{
+	{env: "QBT_DHT_ENABLED", key: `Session\DHTEnabled`, boolean: true},
+	{env: "QBT_PEX_ENABLED", key: `Session\PeXEnabled`, boolean: true},
+	{env: "QBT_LSD_ENABLED", key: `Session\LSDEnabled`, boolean: true},
+	{env: "QBT_ENCRYPTION", key: `Session\Encryption`, choices: []string{"prefer", "require", "disable"}},
+	{env: "QBT_ANONYMOUS_MODE", key: `Session\AnonymousModeEnabled`, boolean: true},
+	{env: "QBT_MAX_CONNECTIONS", key: `Session\MaxConnections`, min: -1},
+	{env: "QBT_MAX_CONNECTIONS_PER_TORRENT", key: `Session\MaxConnectionsPerTorrent`, min: -1},
+	{env: "QBT_MAX_UPLOADS", key: `Session\MaxUploads`, min: -1},
+	{env: "QBT_MAX_UPLOADS_PER_TORRENT", key: `Session\MaxUploadsPerTorrent`, min: -1},
+	{env: "QBT_UTP_MIXED_MODE", key: `Session\uTPMixedMode`, choices: []string{"prefer_tcp", "proportional"}, values: []string{"TCP", "Proportional"}},
+}
+
+// sessionProfiles are presets selected with QBT_SESSION_PROFILE. Their values
+// apply where the setting's own variable is unset.
+var sessionProfiles = map[string]map[string]string{
+	"private": {
+		"QBT_DHT_ENABLED": "false",
+		"QBT_PEX_ENABLED": "false",
+		"QBT_LSD_ENABLED": "false",
+	},
+}
+
+func (s sessionSetting) parse(raw string) (string, error) {
+	switch {
+	case s.boolean:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s=%q: expected true or false", s.env, raw)
+		}
+		return strconv.FormatBool(b), nil
+	case s.choices != nil:
+		i := slices.Index(s.choices, strings.ToLower(raw))
+		if i < 0 {
+			return "", fmt.Errorf("invalid %s=%q: expected one of %s", s.env, raw, strings.Join(s.choices, ", "))
+		}
+		if s.values != nil {
+			return s.values[i], nil
+		}
+		return strconv.Itoa(i), nil
+	default:
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < s.min {
+			return "", fmt.Errorf("invalid %s=%q: expected an integer of at least %d", s.env, raw, s.min)
+		}
+		return strconv.Itoa(n), nil
+	}
+}
+
+// applySessionSettings writes the DHT, PEX, LSD, encryption, anonymous mode,
+// connection limit and uTP settings from the environment to conf. It reports
+// whether conf changed.
+func applySessionSettings(conf *iniFile) (bool, error) {
+	name := os.Getenv("QBT_SESSION_PROFILE")
+	profile, ok := sessionProfiles[strings.ToLower(name)]
+	if name != "" && !ok {
+		return false, fmt.Errorf("unknown QBT_SESSION_PROFILE %q", name)
+	}
+	return writeSessionSettings(conf, sessionSettings, profile)
+}
+
+// writeSessionSettings applies each setting whose variable, or failing that
+// the entry in defaults, is set.
+func writeSessionSettings(conf *iniFile, settings []sessionSetting, defaults map[string]string) (bool, error) {
+	changed := false
+	for _, s := range settings {
+		raw := getEnv(s.env, defaults[s.env])
+		if raw == "" {
+			continue
+		}
+		value, err := s.parse(raw)
+		if err != nil {
+			return false, err
+		}
+		if current, ok := conf.get("BitTorrent", s.key); ok && current == value {
+			continue
+		}
+		conf.set("BitTorrent", s.key, value)
+		changed = true
+		log.Info("Applied session setting from environment", "key", s.key, "value", value)
+	}
+	return changed, nil
+}
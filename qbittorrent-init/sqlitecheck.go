@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var errSQLiteCorrupt = errors.New("database is corrupt")
+
+// checkSQLiteIntegrity runs PRAGMA integrity_check. The database is opened
+// writable so SQLite recovers a write-ahead log left by a crash first;
+// pages the log supersedes are never judged from the main file alone. Only
+// SQLite reporting corruption yields errSQLiteCorrupt, so I/O or permission
+// problems are never mistaken for it.
+func checkSQLiteIntegrity(dbPath string) error {
+	db, err := openSQLite(dbPath, false)
+	if err != nil {
+		return classifySQLiteError(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return classifySQLiteError(err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return classifySQLiteError(err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return classifySQLiteError(err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", errSQLiteCorrupt, strings.Join(problems[:min(len(problems), 5)], "; "))
+	}
+	return nil
+}
+
+func classifySQLiteError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_CORRUPT, sqlite3.SQLITE_NOTADB:
+			return fmt.Errorf("%w: %v", errSQLiteCorrupt, err)
+		}
+	}
+	return err
+}
+
+// readSQLiteTable returns every row of table, including changes still in
+// the write-ahead log. The files are opened read-only.
+func readSQLiteTable(dbPath, table string) ([]map[string]any, error) {
+	db, err := openSQLite(dbPath, true)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return querySQLiteRows(context.Background(), db, "SELECT * FROM "+quoteSQLiteIdent(table))
+}
+
+// verifyResumeDatabase checks torrents.db before qBittorrent opens it. When
+// SQLite confirms corruption and QBT_DB_AUTO_RESTORE is enabled, the
+// database and its log are moved aside and the newest backup is restored;
+// otherwise startup stops so nothing is replaced without consent.
+func verifyResumeDatabase() error {
+	dbPath := filepath.Join(qbtDataDir(), "torrents.db")
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		log.Debug("Resume database does not exist, skipping integrity check", "path", dbPath)
+		return nil
+	}
+
+	err := checkSQLiteIntegrity(dbPath)
+	if err == nil {
+		log.Info("Resume database integrity check passed", "path", dbPath)
+		return nil
+	}
+	if !errors.Is(err, errSQLiteCorrupt) {
+		return fmt.Errorf("integrity check failed: %w", err)
+	}
+
+	log.Error("Resume database is corrupt", "path", dbPath, "error", err)
+	if !getEnvBool("QBT_DB_AUTO_RESTORE", false) {
+		return fmt.Errorf("%w (set QBT_DB_AUTO_RESTORE=true to restore the newest backup)", err)
+	}
+
+	backups, listErr := listBackups(getEnv("QBT_BACKUP_DIR", defaultBackupDir))
+	if listErr != nil || len(backups) == 0 {
+		return fmt.Errorf("%w (no backup available to restore from)", err)
+	}
+	latest := backups[len(backups)-1]
+
+	walPath := dbPath + "-wal"
+	suffix := fmt.Sprintf(".corrupt-%d", time.Now().Unix())
+	for _, p := range []string{dbPath, walPath, dbPath + "-shm"} {
+		if _, statErr := os.Stat(p); statErr == nil {
+			if err := os.Rename(p, p+suffix); err != nil {
+				return fmt.Errorf("failed to move corrupt database aside: %w", err)
+			}
+		}
+	}
+
+	restored, err := restoreBackup(latest, []string{dbPath, walPath})
+	if err != nil {
+		return fmt.Errorf("failed to restore resume database from %s: %w", latest, err)
+	}
+	if !slices.Contains(restored, dbPath) {
+		return fmt.Errorf("backup %s does not contain %s", latest, filepath.Base(dbPath))
+	}
+	if err := checkSQLiteIntegrity(dbPath); err != nil {
+		return fmt.Errorf("restored database failed integrity check: %w", err)
+	}
+
+	log.Warn("Resume database restored from backup", "archive", latest, "corrupt_copy", dbPath+suffix)
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// crashSnapshot copies a database and its write-ahead log while a
+// connection still has uncheckpointed changes, as a crash would leave them.
+// The table's only page is then overwritten in the main file, so it only
+// reads back correctly when the newer copy of that page in the log is used.
+func crashSnapshot(t *testing.T) string {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), "torrents.db")
+	db, err := sql.Open("sqlite", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`PRAGMA journal_mode = WAL`,
+		`CREATE TABLE torrents (id INTEGER PRIMARY KEY, torrent_id TEXT NOT NULL UNIQUE, name TEXT)`,
+		`INSERT INTO torrents (torrent_id, name) VALUES ('a', 'old'), ('b', 'old'), ('c', 'old')`,
+		`PRAGMA wal_checkpoint(TRUNCATE)`,
+		`PRAGMA wal_autocheckpoint = 0`,
+		`UPDATE torrents SET name = 'new'`,
+		`INSERT INTO torrents (torrent_id, name) VALUES ('d', 'new')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	var pageSize, root int
+	if err := db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(`SELECT rootpage FROM sqlite_schema WHERE name = 'torrents'`).Scan(&root); err != nil {
+		t.Fatal(err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "torrents.db")
+	for _, suffix := range []string{"", "-wal"} {
+		data, err := os.ReadFile(src + suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if suffix == "" {
+			copy(data[(root-1)*pageSize:root*pageSize], bytes.Repeat([]byte{0xa5}, pageSize))
+		}
+		if err := os.WriteFile(dbPath+suffix, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dbPath
+}
+
+// corruptDatabase overwrites the root page of the torrents table in a
+// cleanly closed database.
+func corruptDatabase(t *testing.T) string {
+	t.Helper()
+	dbPath := createResumeDB(t, 200)
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pageSize, root int
+	if err := db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(`SELECT rootpage FROM sqlite_schema WHERE name = 'torrents'`).Scan(&root); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	f, err := os.OpenFile(dbPath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(bytes.Repeat([]byte{0xa5}, pageSize), int64(root-1)*int64(pageSize)); err != nil {
+		t.Fatal(err)
+	}
+	return dbPath
+}
+
+func TestCheckSQLiteIntegrityRecoversWAL(t *testing.T) {
+	dbPath := crashSnapshot(t)
+
+	// Judged from the main file alone, the snapshot is corrupt.
+	data, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainOnly := filepath.Join(t.TempDir(), "torrents.db")
+	if err := os.WriteFile(mainOnly, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSQLiteIntegrity(mainOnly); !errors.Is(err, errSQLiteCorrupt) {
+		t.Fatalf("main file without its log: got %v, want errSQLiteCorrupt", err)
+	}
+
+	if err := checkSQLiteIntegrity(dbPath); err != nil {
+		t.Fatalf("database with a pending log reported as %v", err)
+	}
+	rows, err := readSQLiteTable(dbPath, "torrents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("read %d rows, want 4", len(rows))
+	}
+	for _, row := range rows {
+		if row["name"] != "new" {
+			t.Errorf("row %v has name %v, want the logged change", row["torrent_id"], row["name"])
+		}
+	}
+}
+
+func TestCheckSQLiteIntegrityCorrupt(t *testing.T) {
+	for name, dbPath := range map[string]string{
+		"damaged page": corruptDatabase(t),
+		"not a database": func() string {
+			p := filepath.Join(t.TempDir(), "torrents.db")
+			if err := os.WriteFile(p, bytes.Repeat([]byte("not sqlite"), 1000), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			return p
+		}(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := checkSQLiteIntegrity(dbPath); !errors.Is(err, errSQLiteCorrupt) {
+				t.Fatalf("got %v, want errSQLiteCorrupt", err)
+			}
+		})
+	}
+}
+
+func TestCheckSQLiteIntegrityUnreadable(t *testing.T) {
+	err := checkSQLiteIntegrity(filepath.Join(t.TempDir(), "missing", "torrents.db"))
+	if err == nil || errors.Is(err, errSQLiteCorrupt) {
+		t.Fatalf("got %v, want an error that is not errSQLiteCorrupt", err)
+	}
+}
+
+func TestVerifyResumeDatabase(t *testing.T) {
+	t.Run("pending log", func(t *testing.T) {
+		dbPath := crashSnapshot(t)
+		t.Setenv("QBT_DATA_DIR", filepath.Dir(dbPath))
+		if err := verifyResumeDatabase(); err != nil {
+			t.Fatal(err)
+		}
+		assertOnlyFiles(t, filepath.Dir(dbPath), "torrents.db")
+	})
+
+	t.Run("corrupt without auto restore", func(t *testing.T) {
+		dbPath := corruptDatabase(t)
+		before, err := os.ReadFile(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("QBT_DATA_DIR", filepath.Dir(dbPath))
+		t.Setenv("QBT_BACKUP_DIR", t.TempDir())
+
+		if err := verifyResumeDatabase(); !errors.Is(err, errSQLiteCorrupt) {
+			t.Fatalf("got %v, want errSQLiteCorrupt", err)
+		}
+		after, err := os.ReadFile(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(before, after) {
+			t.Fatal("corrupt database was modified without QBT_DB_AUTO_RESTORE")
+		}
+	})
+}
+
+func assertOnlyFiles(t *testing.T, dir string, want ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("%s contains %v, want %v", dir, got, want)
+	}
+}
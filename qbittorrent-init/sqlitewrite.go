@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// sqliteRowIDColumn carries the rowid next to the table's own columns.
+const sqliteRowIDColumn = "qbt_init_rowid"
+
+// rewriteSQLiteTable calls fn for every row of table and writes back the
+// columns it changed, all in one transaction, so the database is either
+// fully rewritten or left as it was. qBittorrent must not be running.
+func rewriteSQLiteTable(dbPath, table string, fn func(row map[string]any) bool) (int, error) {
+	ctx := context.Background()
+	db, err := openSQLite(dbPath, false)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := querySQLiteRows(ctx, tx, fmt.Sprintf("SELECT rowid AS %s, * FROM %s", sqliteRowIDColumn, quoteSQLiteIdent(table)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read table %s: %w", table, err)
+	}
+
+	changed := 0
+	for _, row := range rows {
+		rowid := row[sqliteRowIDColumn]
+		delete(row, sqliteRowIDColumn)
+		original := maps.Clone(row)
+		if !fn(row) {
+			continue
+		}
+
+		var (
+			assignments []string
+			args        []any
+		)
+		for _, column := range slices.Sorted(maps.Keys(row)) {
+			if sqliteValuesEqual(original[column], row[column]) {
+				continue
+			}
+			assignments = append(assignments, quoteSQLiteIdent(column)+" = ?")
+			args = append(args, row[column])
+		}
+		if len(assignments) == 0 {
+			continue
+		}
+		query := fmt.Sprintf("UPDATE %s SET %s WHERE rowid = ?", quoteSQLiteIdent(table), strings.Join(assignments, ", "))
+		if _, err := tx.ExecContext(ctx, query, append(args, rowid)...); err != nil {
+			return 0, fmt.Errorf("failed to update row %v: %w", rowid, err)
+		}
+		changed++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return changed, nil
+}
+
+func sqliteValuesEqual(a, b any) bool {
+	switch a := a.(type) {
+	case []byte:
+		bb, ok := b.([]byte)
+		return ok && bytes.Equal(a, bb)
+	case nil:
+		return b == nil
+	default:
+		if _, ok := b.([]byte); ok {
+			return false
+		}
+		return a == b
+	}
+}
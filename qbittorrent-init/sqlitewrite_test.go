@@ -0,0 +1,111 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createResumeDB creates a torrents table shaped like qBittorrent's, in WAL
+// mode as qBittorrent uses it.
+func createResumeDB(t *testing.T, rows int) string {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "torrents.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`PRAGMA journal_mode = WAL`,
+		`CREATE TABLE torrents (
+			id INTEGER PRIMARY KEY,
+			torrent_id BLOB NOT NULL UNIQUE,
+			name TEXT,
+			target_save_path TEXT,
+			libtorrent_resume_data BLOB NOT NULL
+		)`,
+		`CREATE INDEX torrents_save_path ON torrents (target_save_path)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range rows {
+		path := fmt.Sprintf("/data/old/%d", i)
+		resume := fmt.Sprintf("d9:save_path%d:%se", len(path), path)
+		if _, err := db.Exec(`INSERT INTO torrents (torrent_id, name, target_save_path, libtorrent_resume_data) VALUES (?, ?, ?, ?)`,
+			fmt.Sprintf("%040x", i), fmt.Sprintf("torrent %d", i), path, []byte(resume)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dbPath
+}
+
+func TestRewriteSQLiteTable(t *testing.T) {
+	dbPath := createResumeDB(t, 500)
+
+	changed, err := rewriteSQLiteTable(dbPath, "torrents", func(row map[string]any) bool {
+		path := sqliteText(row["target_save_path"])
+		if !strings.HasSuffix(path, "0") {
+			return false
+		}
+		row["target_save_path"] = strings.Replace(path, "/data/old", "/data/new", 1)
+		row["libtorrent_resume_data"] = []byte("de")
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 50 {
+		t.Fatalf("changed %d rows, want 50", changed)
+	}
+
+	if err := checkSQLiteIntegrity(dbPath); err != nil {
+		t.Fatalf("integrity check after rewrite: %v", err)
+	}
+	rows, err := readSQLiteTable(dbPath, "torrents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 500 {
+		t.Fatalf("read %d rows, want 500", len(rows))
+	}
+	for _, row := range rows {
+		path := sqliteText(row["target_save_path"])
+		moved := strings.HasPrefix(path, "/data/new/")
+		if moved != strings.HasSuffix(path, "0") {
+			t.Errorf("row %v has save path %q", row["id"], path)
+		}
+		if resume := sqliteText(row["libtorrent_resume_data"]); moved != (resume == "de") {
+			t.Errorf("row %v has resume data %q", row["id"], resume)
+		}
+	}
+}
+
+func TestRewriteSQLiteTableRollsBack(t *testing.T) {
+	dbPath := createResumeDB(t, 10)
+
+	// A duplicate torrent_id violates the unique constraint on the second
+	// change, which must undo the first.
+	_, err := rewriteSQLiteTable(dbPath, "torrents", func(row map[string]any) bool {
+		row["torrent_id"] = fmt.Sprintf("%040x", 0)
+		row["name"] = "renamed"
+		return true
+	})
+	if err == nil {
+		t.Fatal("expected a constraint error")
+	}
+
+	rows, err := readSQLiteTable(dbPath, "torrents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if sqliteText(row["name"]) == "renamed" {
+			t.Fatalf("row %v was changed by a failed rewrite", row["id"])
+		}
+	}
+}
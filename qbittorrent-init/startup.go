@@ -0,0 +1,106 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type phaseTiming struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type startupSnapshot struct {
+	StartedAt time.Time     `json:"started_at"`
+	ReadyAt   *time.Time    `json:"ready_at,omitempty"`
+	Completed bool          `json:"completed"`
+	TotalMs   float64       `json:"total_ms"`
+	Phases    []phaseTiming `json:"phases"`
+}
+
+type startupReport struct {
+	mu        sync.Mutex
+	start     time.Time
+	phases    []phaseTiming
+	completed bool
+	readyAt   time.Time
+}
+
+var startup = newStartupReport()
+
+func newStartupReport() *startupReport {
+	return &startupReport{start: time.Now()}
+}
+
+func (r *startupReport) record(name string, since time.Time, err error) {
+	phase := phaseTiming{
+		Name:       name,
+		DurationMs: durationMs(time.Since(since)),
+	}
+	if err != nil {
+		phase.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	r.phases = append(r.phases, phase)
+	r.mu.Unlock()
+
+	log.Debug("Startup phase finished",
+		"phase", name,
+		"duration_ms", phase.DurationMs,
+		"error", phase.Error)
+}
+
+func (r *startupReport) track(name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	r.record(name, start, err)
+	return err
+}
+
+func (r *startupReport) complete() {
+	r.mu.Lock()
+	r.completed = true
+	r.readyAt = time.Now()
+	total := durationMs(r.readyAt.Sub(r.start))
+	phases := make([]any, 0, len(r.phases))
+	for _, p := range r.phases {
+		phases = append(phases, p.Name, p.DurationMs)
+	}
+	r.mu.Unlock()
+
+	log.Info("Startup completed",
+		"total_ms", total,
+		slog.Group("phases_ms", phases...))
+}
+
+func (r *startupReport) isComplete() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.completed
+}
+
+func (r *startupReport) snapshot() startupSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snap := startupSnapshot{
+		StartedAt: r.start,
+		Completed: r.completed,
+		Phases:    append([]phaseTiming(nil), r.phases...),
+	}
+	if r.completed {
+		readyAt := r.readyAt
+		snap.ReadyAt = &readyAt
+		snap.TotalMs = durationMs(r.readyAt.Sub(r.start))
+	} else {
+		snap.TotalMs = durationMs(time.Since(r.start))
+	}
+	return snap
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// watchStateDump writes the initializer's state to stderr on SIGUSR1, and to
+// STATE_DUMP_PATH too when it is set. qBittorrent runs in its own process
+// group, so the signal only reaches the initializer.
+func watchStateDump(ctx context.Context) {
+	path := os.Getenv("STATE_DUMP_PATH")
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+			case <-ctx.Done():
+				return
+			}
+
+			var buf bytes.Buffer
+			dumpState(&buf)
+			os.Stderr.Write(buf.Bytes())
+			if path != "" {
+				if err := os.WriteFile(path, buf.Bytes(), 0o640); err != nil {
+					log.Error("Failed to write state dump", "path", path, "error", err)
+					continue
+				}
+			}
+			log.Info("Wrote state dump", "path", path)
+		}
+	}()
+}
+
+func dumpState(w io.Writer) {
+	now := time.Now()
+	snap := startup.snapshot()
+	fmt.Fprintf(w, "=== qbittorrent-init %s state at %s (up %s)\n",
+		version, now.UTC().Format(time.RFC3339), now.Sub(snap.StartedAt).Round(time.Second))
+
+	fmt.Fprintf(w, "\n--- startup (completed: %t, %.0f ms)\n", snap.Completed, snap.TotalMs)
+	for _, phase := range snap.Phases {
+		fmt.Fprintf(w, "%-18s %10.1f ms", phase.Name, phase.DurationMs)
+		if phase.Error != "" {
+			fmt.Fprintf(w, "  error: %s", phase.Error)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "\n--- maintenance jobs")
+	maintenanceStatus.mu.Lock()
+	if len(maintenanceStatus.jobs) == 0 {
+		fmt.Fprintln(w, "none run yet")
+	}
+	for _, name := range slices.Sorted(maps.Keys(maintenanceStatus.jobs)) {
+		st := maintenanceStatus.jobs[name]
+		fmt.Fprintf(w, "%s: %d runs, last %s ago", name, st.runs, now.Sub(st.lastRun).Round(time.Second))
+		if st.lastError != "" {
+			fmt.Fprintf(w, ", last error %s ago: %s", now.Sub(st.errorAt).Round(time.Second), st.lastError)
+		}
+		fmt.Fprintln(w)
+	}
+	maintenanceStatus.mu.Unlock()
+
+	fmt.Fprintln(w, "\n--- goroutines")
+	pprof.Lookup("goroutine").WriteTo(w, 2)
+	fmt.Fprintln(w, "=== end of state dump")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// templatePlaceholder matches ${NAME} and ${NAME:-default}. A bare $NAME is
+// left alone since qBittorrent values may legitimately contain dollar signs.
+var templatePlaceholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// initialConfig returns the configuration written when none exists yet: the
+// user's template from QBT_CONFIG_TEMPLATE with placeholders expanded, or the
+// built-in defaults.
+func initialConfig() (*iniFile, error) {
+	path := getEnv("QBT_CONFIG_TEMPLATE", "")
+	if path == "" {
+		return defaultConfig(true), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config template: %w", err)
+	}
+	expanded, err := expandTemplate(string(data), os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand config template %s: %w", path, err)
+	}
+	log.Info("Using config template", "path", path)
+	return parseINI([]byte(expanded)), nil
+}
+
+// expandTemplate replaces placeholders with values from lookup, using the
+// default as the shell does when the variable is unset or empty. Variables
+// that are unset and have no default are reported together.
+func expandTemplate(s string, lookup func(string) (string, bool)) (string, error) {
+	var missing []string
+	out := templatePlaceholder.ReplaceAllStringFunc(s, func(match string) string {
+		m := templatePlaceholder.FindStringSubmatch(match)
+		value, ok := lookup(m[1])
+		switch {
+		case ok && value != "":
+			return value
+		case strings.Contains(match, ":-"):
+			return m[2]
+		case ok:
+			return ""
+		}
+		if !slices.Contains(missing, m[1]) {
+			missing = append(missing, m[1])
+		}
+		return match
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined variables: %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultTrackersListURL = "https://raw.githubusercontent.com/ngosang/trackerslist/master/trackers_best.txt"
+
+type trackerInjector struct {
+	listURL    string
+	httpClient *http.Client
+	private    map[string]bool
+}
+
+func newTrackerInjector() *trackerInjector {
+	return &trackerInjector{
+		listURL:    getEnv("QBT_TRACKERS_LIST_URL", defaultTrackersListURL),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		private:    make(map[string]bool),
+	}
+}
+
+func (j *trackerInjector) fetchList(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.listURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := j.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var trackers []string
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 1<<20))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		trackers = append(trackers, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trackers list: %w", err)
+	}
+	return trackers, nil
+}
+
+func (j *trackerInjector) run(ctx context.Context, client *webUIClient) error {
+	list, err := j.fetchList(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to download trackers list: %w", err)
+	}
+	if len(list) == 0 {
+		log.Warn("Trackers list is empty, nothing to inject", "url", j.listURL)
+		return nil
+	}
+
+	torrents, err := client.torrents(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	var updated, skippedPrivate int
+	for _, t := range torrents {
+		private, err := j.isPrivate(ctx, client, t.Hash)
+		if err != nil {
+			log.Warn("Failed to determine private flag, skipping torrent", "hash", t.Hash, "error", err)
+			continue
+		}
+		if private {
+			skippedPrivate++
+			continue
+		}
+
+		existing, err := client.torrentTrackers(ctx, t.Hash)
+		if err != nil {
+			log.Warn("Failed to fetch trackers", "hash", t.Hash, "error", err)
+			continue
+		}
+		known := make(map[string]bool, len(existing))
+		for _, tr := range existing {
+			known[tr.URL] = true
+		}
+
+		var missing []string
+		for _, tr := range list {
+			if !known[tr] {
+				missing = append(missing, tr)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		if err := client.addTrackers(ctx, t.Hash, missing); err != nil {
+			log.Warn("Failed to add trackers", "hash", t.Hash, "error", err)
+			continue
+		}
+		log.Debug("Added public trackers", "name", t.Name, "hash", t.Hash, "count", len(missing))
+		updated++
+	}
+
+	log.Info("Public tracker injection completed",
+		"trackers", len(list),
+		"updated", updated,
+		"skipped_private", skippedPrivate)
+	return nil
+}
+
+func (j *trackerInjector) isPrivate(ctx context.Context, client *webUIClient, hash string) (bool, error) {
+	if private, ok := j.private[hash]; ok {
+		return private, nil
+	}
+	props, err := client.torrentProperties(ctx, hash)
+	if err != nil {
+		return false, err
+	}
+	j.private[hash] = props.IsPrivate
+	return props.IsPrivate, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+type hostRules map[string]string
+
+func parseHostRules(s string) hostRules {
+	rules := make(hostRules)
+	for _, entry := range splitList(s) {
+		host, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			log.Warn("Ignoring malformed tracker rule", "rule", entry)
+			continue
+		}
+		rules[strings.ToLower(strings.TrimSpace(host))] = strings.TrimSpace(value)
+	}
+	return rules
+}
+
+func (r hostRules) lookup(trackerURL string) (string, bool) {
+	host := trackerHost(trackerURL)
+	for host != "" {
+		if v, ok := r[host]; ok {
+			return v, true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	v, ok := r["*"]
+	return v, ok
+}
+
+func trackerHost(trackerURL string) string {
+	u, err := url.Parse(trackerURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func isPseudoTracker(trackerURL string) bool {
+	return strings.HasPrefix(trackerURL, "** [")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	unregisteredActionTag         = "tag"
+	unregisteredActionDelete      = "delete"
+	unregisteredActionDeleteFiles = "delete-files"
+
+	// qBittorrent 5.1 reports a tracker's error reply as its own status,
+	// separate from not working and unreachable.
+	trackerStatusNotWorking   = 4
+	trackerStatusTrackerError = 5
+)
+
+var defaultUnregisteredPatterns = []string{
+	"unregistered",
+	"not registered",
+	"torrent not found",
+	"torrent does not exist",
+	"infohash not found",
+	"torrent has been deleted",
+	"trumped",
+	"retitled",
+	"truncated",
+}
+
+type unregisteredScanner struct {
+	tag      string
+	patterns []string
+	policy   hostRules
+}
+
+func newUnregisteredScanner() *unregisteredScanner {
+	patterns := defaultUnregisteredPatterns
+	if custom := splitList(os.Getenv("QBT_UNREGISTERED_PATTERNS")); len(custom) > 0 {
+		patterns = custom
+	}
+	for i := range patterns {
+		patterns[i] = strings.ToLower(patterns[i])
+	}
+
+	return &unregisteredScanner{
+		tag:      getEnv("QBT_UNREGISTERED_TAG", "unregistered"),
+		patterns: patterns,
+		policy:   parseHostRules(os.Getenv("QBT_UNREGISTERED_POLICY")),
+	}
+}
+
+func (s *unregisteredScanner) run(ctx context.Context, client *webUIClient) error {
+	torrents, err := client.torrents(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	actions := make(map[string][]string)
+	var recovered []string
+
+	for _, t := range torrents {
+		trackers, err := client.torrentTrackers(ctx, t.Hash)
+		if err != nil {
+			log.Warn("Failed to fetch trackers", "hash", t.Hash, "error", err)
+			continue
+		}
+
+		tracker, msg, unregistered := s.match(trackers)
+		if !unregistered {
+			if hasTag(t.Tags, s.tag) {
+				recovered = append(recovered, t.Hash)
+			}
+			continue
+		}
+
+		action, ok := s.policy.lookup(tracker)
+		if !ok {
+			action = unregisteredActionTag
+		}
+		if action == unregisteredActionDeleteFiles {
+			linked, err := torrentHasExtraHardlinks(ctx, client, t)
+			if err != nil {
+				log.Warn("Hardlink check failed, skipping torrent", "hash", t.Hash, "error", err)
+				continue
+			}
+			if linked {
+				action = unregisteredActionDelete
+			}
+		}
+
+		log.Info("Unregistered torrent detected",
+			"name", t.Name,
+			"hash", t.Hash,
+			"tracker", trackerHost(tracker),
+			"message", msg,
+			"action", action)
+
+		actions[action] = append(actions[action], t.Hash)
+	}
+
+	for action, hashes := range actions {
+		switch action {
+		case unregisteredActionDelete, unregisteredActionDeleteFiles:
+			if err := client.deleteTorrents(ctx, hashes, action == unregisteredActionDeleteFiles); err != nil {
+				return fmt.Errorf("failed to delete unregistered torrents: %w", err)
+			}
+		case unregisteredActionTag:
+			if err := client.addTags(ctx, hashes, s.tag); err != nil {
+				return fmt.Errorf("failed to tag unregistered torrents: %w", err)
+			}
+		default:
+			log.Warn("Unknown unregistered torrent action, skipping", "action", action, "count", len(hashes))
+		}
+	}
+
+	if len(recovered) > 0 {
+		if err := client.removeTags(ctx, recovered, s.tag); err != nil {
+			return fmt.Errorf("failed to untag recovered torrents: %w", err)
+		}
+		log.Info("Removed unregistered tag from recovered torrents", "count", len(recovered))
+	}
+
+	return nil
+}
+
+func (s *unregisteredScanner) match(trackers []torrentTracker) (string, string, bool) {
+	for _, tr := range trackers {
+		if isPseudoTracker(tr.URL) || (tr.Status != trackerStatusNotWorking && tr.Status != trackerStatusTrackerError) {
+			continue
+		}
+		msg := strings.ToLower(tr.Msg)
+		for _, pattern := range s.patterns {
+			if strings.Contains(msg, pattern) {
+				return tr.URL, tr.Msg, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func hasTag(tags, tag string) bool {
+	for _, t := range strings.Split(tags, ",") {
+		if strings.TrimSpace(t) == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestUnregisteredScannerMatch(t *testing.T) {
+	s := &unregisteredScanner{patterns: defaultUnregisteredPatterns}
+	for _, tc := range []struct {
+		name    string
+		tracker torrentTracker
+		want    bool
+	}{
+		{"not working", torrentTracker{URL: "https://t/announce", Status: 4, Msg: "Unregistered torrent"}, true},
+		{"tracker error", torrentTracker{URL: "https://t/announce", Status: 5, Msg: "Torrent not found"}, true},
+		{"tracker error, other message", torrentTracker{URL: "https://t/announce", Status: 5, Msg: "rate limited"}, false},
+		{"unreachable", torrentTracker{URL: "https://t/announce", Status: 6, Msg: "unregistered"}, false},
+		{"working", torrentTracker{URL: "https://t/announce", Status: 2, Msg: "unregistered"}, false},
+		{"pseudo tracker", torrentTracker{URL: "** [DHT] **", Status: 4, Msg: "unregistered"}, false},
+	} {
+		if _, _, got := s.match([]torrentTracker{tc.tracker}); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type backupUploader interface {
+	upload(ctx context.Context, archivePath string) error
+	String() string
+}
+
+func newBackupUploader() (backupUploader, error) {
+	target := os.Getenv("QBT_BACKUP_UPLOAD_URL")
+	if target == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid QBT_BACKUP_UPLOAD_URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid upload URL scheme: %s", u.Scheme)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Minute}
+
+	switch kind := strings.ToLower(getEnv("QBT_BACKUP_UPLOAD_TYPE", "webdav")); kind {
+	case "webdav":
+		return &webdavUploader{
+			baseURL:  u,
+			username: os.Getenv("QBT_BACKUP_WEBDAV_USER"),
+			password: os.Getenv("QBT_BACKUP_WEBDAV_PASSWORD"),
+			client:   client,
+		}, nil
+	case "s3":
+		s := &s3Uploader{
+			baseURL:   u,
+			region:    getEnv("QBT_BACKUP_S3_REGION", "us-east-1"),
+			accessKey: os.Getenv("QBT_BACKUP_S3_ACCESS_KEY_ID"),
+			secretKey: os.Getenv("QBT_BACKUP_S3_SECRET_ACCESS_KEY"),
+			client:    client,
+		}
+		if s.accessKey == "" || s.secretKey == "" {
+			return nil, fmt.Errorf("s3 upload enabled but missing credentials")
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unsupported QBT_BACKUP_UPLOAD_TYPE: %s", kind)
+	}
+}
+
+func objectURL(base *url.URL, name string) *url.URL {
+	u := *base
+	u.Path = path.Join("/", u.Path, name)
+	u.RawPath = ""
+	return &u
+}
+
+type webdavUploader struct {
+	baseURL  *url.URL
+	username string
+	password string
+	client   *http.Client
+}
+
+func (w *webdavUploader) String() string {
+	return w.baseURL.Redacted()
+}
+
+func (w *webdavUploader) upload(ctx context.Context, archivePath string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	target := objectURL(w.baseURL, filepath.Base(archivePath))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), f)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/gzip")
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+
+	return doUpload(w.client, req)
+}
+
+type s3Uploader struct {
+	baseURL   *url.URL
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func (s *s3Uploader) String() string {
+	return s.baseURL.Redacted()
+}
+
+func (s *s3Uploader) upload(ctx context.Context, archivePath string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, f)
+	if err != nil {
+		return fmt.Errorf("failed to hash archive: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	payloadHash := hex.EncodeToString(hasher.Sum(nil))
+
+	target := objectURL(s.baseURL, filepath.Base(archivePath))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), f)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/gzip")
+	s.sign(req, payloadHash, time.Now().UTC())
+
+	return doUpload(s.client, req)
+}
+
+func (s *s3Uploader) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signed := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	sort.Strings(signed)
+
+	var canonicalHeaders strings.Builder
+	for _, h := range signed {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := dateStamp + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), dateStamp)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func doUpload(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"slices"
+)
+
+type versionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"go_version"`
+	// QBittorrent and Libtorrent are detected from qbittorrent-nox --version.
+	QBittorrent      string `json:"qbittorrent_version,omitempty"`
+	Libtorrent       string `json:"libtorrent_version,omitempty"`
+	QBittorrentError string `json:"qbittorrent_error,omitempty"`
+}
+
+func currentVersionInfo() versionInfo {
+	info := versionInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+	}
+	if v, err := qbittorrentVersions(); err != nil {
+		info.QBittorrentError = err.Error()
+	} else {
+		info.QBittorrent = v.qbittorrent
+		info.Libtorrent = v.libtorrent
+	}
+	return info
+}
+
+// versionJSONRequested reports whether the initializer was started with
+// --version --json, which it answers itself instead of passing --version on
+// to qbittorrent-nox.
+func versionJSONRequested(args []string) bool {
+	if i := slices.Index(args, "--"); i >= 0 {
+		args = args[:i]
+	}
+	return slices.Contains(args, "--json") &&
+		(slices.Contains(args, "--version") || slices.Contains(args, "-v"))
+}
+
+func printVersionJSON() error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(currentVersionInfo()); err != nil {
+		return fmt.Errorf("failed to write version: %w", err)
+	}
+	return nil
+}
+
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentVersionInfo())
+}
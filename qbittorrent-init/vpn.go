@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// waitForVPN blocks until the VPN sidecar at QBT_WAIT_FOR_VPN reports a
+// healthy tunnel, so qBittorrent never starts before traffic can go through
+// it. The endpoint must answer 200; a JSON "status" field, as in gluetun's
+// /v1/openvpn/status and /v1/vpn/status, must also be "running".
+// QBT_VPN_API_KEY is sent as X-API-Key for gluetun's control server auth.
+func waitForVPN(ctx context.Context) error {
+	target := os.Getenv("QBT_WAIT_FOR_VPN")
+	if target == "" {
+		return nil
+	}
+	timeout := getEnvDuration("QBT_VPN_WAIT_TIMEOUT", 5*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	apiKey := os.Getenv("QBT_VPN_API_KEY")
+	log.Info("Waiting for VPN", "url", target, "timeout", timeout)
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		err := checkVPN(ctx, client, target, apiKey)
+		if err == nil {
+			log.Info("VPN is up", "url", target)
+			return nil
+		}
+		log.Debug("VPN not ready yet", "error", err)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("VPN at %s not ready within %s: %w", target, timeout, err)
+		}
+	}
+}
+
+func checkVPN(ctx context.Context, client *http.Client, target, apiKey string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("status %d, check QBT_VPN_API_KEY", resp.StatusCode)
+	default:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var status struct {
+		Status *string `json:"status"`
+	}
+	if json.Unmarshal(body, &status) != nil || status.Status == nil {
+		return nil
+	}
+	if !strings.EqualFold(*status.Status, "running") {
+		return errors.New("VPN status is " + *status.Status)
+	}
+	return nil
+}
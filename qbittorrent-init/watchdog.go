@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// watchdogConfig restarts qBittorrent when its WebUI stops answering while
+// the process is still alive, which otherwise needs a manual restart.
+type watchdogConfig struct {
+	interval    time.Duration
+	timeout     time.Duration
+	failures    int
+	maxRestarts int
+}
+
+// loadWatchdogConfig returns nil unless QBT_WATCHDOG_ENABLED is set.
+func loadWatchdogConfig() *watchdogConfig {
+	if !getEnvBool("QBT_WATCHDOG_ENABLED", false) {
+		return nil
+	}
+	return &watchdogConfig{
+		interval:    getEnvDuration("QBT_WATCHDOG_INTERVAL", 30*time.Second),
+		timeout:     getEnvDuration("QBT_WATCHDOG_TIMEOUT", 10*time.Second),
+		failures:    max(getEnvInt("QBT_WATCHDOG_FAILURES", 3), 1),
+		maxRestarts: max(getEnvInt("QBT_WATCHDOG_MAX_RESTARTS", 3), 0),
+	}
+}
+
+// watchWebUI probes the WebUI API and returns once it failed cfg.failures
+// times in a row, or when ctx ends. A 403 still counts as responsive.
+func watchWebUI(ctx context.Context, cfg *watchdogConfig, client *webUIClient) bool {
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+
+	failed := 0
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return false
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, cfg.timeout)
+		_, err := client.appVersion(probeCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return false
+		}
+		if err == nil || errors.Is(err, errWebUIForbidden) {
+			if failed > 0 {
+				log.Info("WebUI is responding again", "failed_probes", failed)
+			}
+			failed = 0
+			continue
+		}
+
+		failed++
+		log.Warn("WebUI probe failed", "error", err, "consecutive_failures", failed, "threshold", cfg.failures)
+		if failed >= cfg.failures {
+			return true
+		}
+	}
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type webUIClient struct {
+	baseURL    string
+	httpClient *http.Client
+	// username and password log in when qBittorrent does not bypass
+	// authentication for localhost. passwordFile is re-read on each login
+	// so it follows password rotation.
+	username     string
+	password     string
+	passwordFile string
+}
+
+// newWebUIClient logs in with QBT_USERNAME and QBT_PASSWORD or
+// QBT_PASSWORD_FILE, which defaults to the rotated password file when
+// rotation is enabled. QBT_PASSWORD is used while that file does not exist
+// yet. The QBT_WEBUI_ prefixed names are accepted as deprecated aliases.
+func newWebUIClient() *webUIClient {
+	jar, _ := cookiejar.New(nil)
+	passwordFile := getEnvAlias("QBT_PASSWORD_FILE", "QBT_WEBUI_PASSWORD_FILE")
+	if passwordFile == "" && getEnvBool("QBT_PASSWORD_ROTATION_ENABLED", false) {
+		passwordFile = newPasswordRotator().path
+	}
+	return &webUIClient{
+		baseURL:      strings.TrimRight(getEnv("QBT_WEBUI_URL", "http://127.0.0.1:"+getEnv("QBT_WEBUI_PORT", "8080")), "/"),
+		httpClient:   &http.Client{Timeout: 10 * time.Second, Jar: jar},
+		username:     getEnvAlias("QBT_USERNAME", "QBT_WEBUI_USERNAME"),
+		password:     getEnvAlias("QBT_PASSWORD", "QBT_WEBUI_PASSWORD"),
+		passwordFile: passwordFile,
+	}
+}
+
+func (c *webUIClient) hasCredentials() bool {
+	return c.username != "" && (c.password != "" || c.passwordFile != "")
+}
+
+// loginPassword returns the contents of passwordFile, or password if there
+// is no file or rotation has not written it yet.
+func loginPassword(password, passwordFile string) (string, error) {
+	if passwordFile == "" {
+		return password, nil
+	}
+	data, err := os.ReadFile(passwordFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && password != "":
+		return password, nil
+	case err != nil:
+		return "", fmt.Errorf("failed to read password file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (c *webUIClient) login(ctx context.Context) error {
+	password, err := loginPassword(c.password, c.passwordFile)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{"username": {c.username}, "password": {password}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v2/auth/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", c.baseURL)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("login request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "Ok." {
+		return fmt.Errorf("%w: login as %s rejected with status %d", errWebUIForbidden, c.username, resp.StatusCode)
+	}
+	return nil
+}
+
+// do sends req, logging in and retrying once when qBittorrent asks for
+// authentication and credentials are configured.
+func (c *webUIClient) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusForbidden || !c.hasCredentials() {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	if err := c.login(req.Context()); err != nil {
+		return nil, err
+	}
+	retry := req.Clone(req.Context())
+	// The jar adds the new session cookie; drop the rejected one.
+	retry.Header.Del("Cookie")
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay request body: %w", err)
+		}
+		retry.Body = body
+	}
+	return c.httpClient.Do(retry)
+}
+
+// checkWebUICredentials refuses to start when qBittorrent requires a login
+// from localhost but the initializer has nothing to log in with, since every
+// API call it makes would be rejected.
+func checkWebUICredentials(configPath string) error {
+	conf, err := readINIFile(configPath)
+	if err != nil {
+		return nil
+	}
+	// qBittorrent requires authentication from localhost unless told not to.
+	required := true
+	if value, ok := conf.get("Preferences", `WebUI\LocalHostAuth`); ok {
+		required, _ = strconv.ParseBool(value)
+	}
+	if required && !newWebUIClient().hasCredentials() {
+		return errors.New(`WebUI\LocalHostAuth is enabled: set QBT_USERNAME and QBT_PASSWORD or QBT_PASSWORD_FILE`)
+	}
+	return nil
+}
+
+func (c *webUIClient) appVersion(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v2/app/version", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return strings.TrimSpace(string(body)), nil
+	case http.StatusForbidden:
+		return "", errWebUIForbidden
+	default:
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
+
+func (c *webUIClient) get(ctx context.Context, apiPath string, query url.Values) (io.ReadCloser, error) {
+	target := c.baseURL + apiPath
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusForbidden:
+		resp.Body.Close()
+		return nil, errWebUIForbidden
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", apiPath, errWebUINotFound)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, apiPath)
+	}
+}
+
+func (c *webUIClient) getJSON(ctx context.Context, apiPath string, query url.Values, out any) error {
+	body, err := c.get(ctx, apiPath, query)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", apiPath, err)
+	}
+	return nil
+}
+
+func (c *webUIClient) getBytes(ctx context.Context, apiPath string, query url.Values, limit int64) ([]byte, error) {
+	body, err := c.get(ctx, apiPath, query)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", apiPath, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s response exceeds %d bytes", apiPath, limit)
+	}
+	return data, nil
+}
+
+func (c *webUIClient) exportTorrent(ctx context.Context, hash string) ([]byte, error) {
+	return c.getBytes(ctx, "/api/v2/torrents/export", url.Values{"hash": {hash}}, 64<<20)
+}
+
+func (c *webUIClient) preferences(ctx context.Context) (map[string]any, error) {
+	var prefs map[string]any
+	if err := c.getJSON(ctx, "/api/v2/app/preferences", nil, &prefs); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+func (c *webUIClient) postForm(ctx context.Context, apiPath string, form url.Values) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+apiPath, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		return errWebUIForbidden
+	case http.StatusNotFound:
+		return fmt.Errorf("%s: %w", apiPath, errWebUINotFound)
+	default:
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, apiPath)
+	}
+}
+
+func (c *webUIClient) setPreferences(ctx context.Context, prefs map[string]any) error {
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return c.postForm(ctx, "/api/v2/app/setPreferences", url.Values{"json": {string(data)}})
+}
+
+func (c *webUIClient) addTorrentFile(ctx context.Context, fileName string, data []byte, options map[string]string) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	part, err := mw.CreateFormFile("torrents", fileName)
+	if err != nil {
+		return fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return fmt.Errorf("failed to write form file: %w", err)
+	}
+	for k, v := range options {
+		if err := mw.WriteField(k, v); err != nil {
+			return fmt.Errorf("failed to write form field %s: %w", k, err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize form: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v2/torrents/add", &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return errWebUIForbidden
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected status %d from torrents/add", resp.StatusCode)
+	case strings.TrimSpace(string(respBody)) == "Fails.":
+		return errors.New("qBittorrent rejected the torrent")
+	}
+	return nil
+}
+
+type torrentInfo struct {
+	Hash         string  `json:"hash"`
+	Name         string  `json:"name"`
+	State        string  `json:"state"`
+	Category     string  `json:"category"`
+	Tags         string  `json:"tags"`
+	Tracker      string  `json:"tracker"`
+	SavePath     string  `json:"save_path"`
+	DownloadPath string  `json:"download_path"`
+	ContentPath  string  `json:"content_path"`
+	Size         int64   `json:"size"`
+	Progress     float64 `json:"progress"`
+	Ratio        float64 `json:"ratio"`
+	SeedingTime  int64   `json:"seeding_time"`
+	AddedOn      int64   `json:"added_on"`
+	CompletionOn int64   `json:"completion_on"`
+	NumSeeds     int     `json:"num_seeds"`
+	NumLeechs    int     `json:"num_leechs"`
+}
+
+type torrentFile struct {
+	Index    int     `json:"index"`
+	Name     string  `json:"name"`
+	Size     int64   `json:"size"`
+	Progress float64 `json:"progress"`
+}
+
+func (c *webUIClient) torrents(ctx context.Context, query url.Values) ([]torrentInfo, error) {
+	var torrents []torrentInfo
+	if err := c.getJSON(ctx, "/api/v2/torrents/info", query, &torrents); err != nil {
+		return nil, err
+	}
+	return torrents, nil
+}
+
+func (c *webUIClient) torrentFiles(ctx context.Context, hash string) ([]torrentFile, error) {
+	var files []torrentFile
+	if err := c.getJSON(ctx, "/api/v2/torrents/files", url.Values{"hash": {hash}}, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+type torrentTracker struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	Msg    string `json:"msg"`
+}
+
+func (c *webUIClient) torrentTrackers(ctx context.Context, hash string) ([]torrentTracker, error) {
+	var trackers []torrentTracker
+	if err := c.getJSON(ctx, "/api/v2/torrents/trackers", url.Values{"hash": {hash}}, &trackers); err != nil {
+		return nil, err
+	}
+	return trackers, nil
+}
+
+func (c *webUIClient) addTags(ctx context.Context, hashes []string, tags ...string) error {
+	return c.postForm(ctx, "/api/v2/torrents/addTags", url.Values{
+		"hashes": {strings.Join(hashes, "|")},
+		"tags":   {strings.Join(tags, ",")},
+	})
+}
+
+func (c *webUIClient) removeTags(ctx context.Context, hashes []string, tags ...string) error {
+	return c.postForm(ctx, "/api/v2/torrents/removeTags", url.Values{
+		"hashes": {strings.Join(hashes, "|")},
+		"tags":   {strings.Join(tags, ",")},
+	})
+}
+
+func (c *webUIClient) deleteTorrents(ctx context.Context, hashes []string, deleteFiles bool) error {
+	return c.postForm(ctx, "/api/v2/torrents/delete", url.Values{
+		"hashes":      {strings.Join(hashes, "|")},
+		"deleteFiles": {strconv.FormatBool(deleteFiles)},
+	})
+}
+
+func (c *webUIClient) reannounce(ctx context.Context, hashes []string) error {
+	return c.postForm(ctx, "/api/v2/torrents/reannounce", url.Values{
+		"hashes": {strings.Join(hashes, "|")},
+	})
+}
+
+func (c *webUIClient) stopTorrents(ctx context.Context, hashes []string) error {
+	form := url.Values{"hashes": {strings.Join(hashes, "|")}}
+	err := c.postForm(ctx, "/api/v2/torrents/stop", form)
+	if errors.Is(err, errWebUINotFound) {
+		return c.postForm(ctx, "/api/v2/torrents/pause", form)
+	}
+	return err
+}
+
+func (c *webUIClient) startTorrents(ctx context.Context, hashes []string) error {
+	form := url.Values{"hashes": {strings.Join(hashes, "|")}}
+	err := c.postForm(ctx, "/api/v2/torrents/start", form)
+	if errors.Is(err, errWebUINotFound) {
+		return c.postForm(ctx, "/api/v2/torrents/resume", form)
+	}
+	return err
+}
+
+type torrentProperties struct {
+	SavePath  string `json:"save_path"`
+	IsPrivate bool   `json:"is_private"`
+	Comment   string `json:"comment"`
+}
+
+func (c *webUIClient) torrentProperties(ctx context.Context, hash string) (*torrentProperties, error) {
+	var props torrentProperties
+	if err := c.getJSON(ctx, "/api/v2/torrents/properties", url.Values{"hash": {hash}}, &props); err != nil {
+		return nil, err
+	}
+	return &props, nil
+}
+
+func (c *webUIClient) addTrackers(ctx context.Context, hash string, trackers []string) error {
+	return c.postForm(ctx, "/api/v2/torrents/addTrackers", url.Values{
+		"hash": {hash},
+		"urls": {strings.Join(trackers, "\n")},
+	})
+}
+
+var errWebUINotFound = errors.New("webui endpoint not found")
+
+var errWebUIForbidden = errors.New("webui rejected request: authentication required")
+
+func waitForWebUI(ctx context.Context, client *webUIClient, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		v, err := client.appVersion(ctx)
+		switch {
+		case err == nil:
+			log.Info("WebUI API is ready", "url", client.baseURL, "qbittorrent_version", v)
+			return nil
+		case errors.Is(err, errWebUIForbidden) && client.hasCredentials():
+			return fmt.Errorf("webui rejected the configured credentials: %w", err)
+		case errors.Is(err, errWebUIForbidden):
+			log.Warn("WebUI API requires authentication, set QBT_USERNAME and QBT_PASSWORD", "url", client.baseURL)
+			return nil
+		}
+
+		log.Debug("WebUI API not ready yet", "error", err)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("webui did not become ready within %s: %w", timeout, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfigYAML reads CONFIG_YAML, the whole configuration as one YAML
+// document for Helm charts that would rather not template every variable.
+// Keys are environment variable names, and nested mappings are joined with
+// underscores, so "pushover: {user_key: x}" sets PUSHOVER_USER_KEY. Lists
+// become comma separated values. Variables that are already set win.
+func loadConfigYAML() error {
+	doc := os.Getenv("CONFIG_YAML")
+	if strings.TrimSpace(doc) == "" {
+		return nil
+	}
+	values, err := parseConfigYAML(doc)
+	if err != nil {
+		return fmt.Errorf("invalid CONFIG_YAML: %w", err)
+	}
+
+	var loaded []string
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if os.Getenv(name) != "" {
+			log.Debug("CONFIG_YAML value overridden by the environment", "variable", name)
+			continue
+		}
+		os.Setenv(name, values[name])
+		loaded = append(loaded, name)
+	}
+	log.Info("Loaded CONFIG_YAML", "variables", loaded)
+	return nil
+}
+
+// parseConfigYAML flattens the document into variables. Scalars keep the
+// text they were written with, so 010 or 1.50 reach the variable unchanged,
+// and null leaves the variable unset.
+func parseConfigYAML(doc string) (map[string]string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(doc), &root); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	if len(root.Content) == 0 {
+		return values, nil
+	}
+	top := root.Content[0]
+	if top.Kind == yaml.ScalarNode && top.Tag == "!!null" {
+		return values, nil
+	}
+	if top.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a mapping", top.Line)
+	}
+	if err := flattenYAMLMapping(top, "", values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func flattenYAMLMapping(node *yaml.Node, prefix string, values map[string]string) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveYAMLAlias(node.Content[i+1])
+		if key.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: keys must be plain names", key.Line)
+		}
+		name := prefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(strings.TrimSpace(key.Value)))
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("line %d: %q is not a valid variable name", key.Line, name)
+		}
+		if _, dup := values[name]; dup {
+			return fmt.Errorf("line %d: %s is set twice", key.Line, name)
+		}
+
+		switch value.Kind {
+		case yaml.MappingNode:
+			if err := flattenYAMLMapping(value, name+"_", values); err != nil {
+				return err
+			}
+		case yaml.SequenceNode:
+			var items []string
+			for _, item := range value.Content {
+				item = resolveYAMLAlias(item)
+				if item.Kind != yaml.ScalarNode {
+					return fmt.Errorf("line %d: lists may only hold scalars", item.Line)
+				}
+				if item.Tag != "!!null" {
+					items = append(items, item.Value)
+				}
+			}
+			values[name] = strings.Join(items, ",")
+		case yaml.ScalarNode:
+			if value.Tag != "!!null" {
+				values[name] = value.Value
+			}
+		}
+	}
+	return nil
+}
+
+func resolveYAMLAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"testing"
+)
+
+func TestParseConfigYAML(t *testing.T) {
+	doc := `# settings
+qbt-webui-port: 8080
+pushover:
+  user_key: "abc # not a comment"
+  token: 'it''s'
+trackers:
+  - udp://a:1337
+  - udp://b:1337
+tags: [one, two]
+ratio: 1.50
+umask: 0027
+unset: ~
+defaults: &defaults
+  level: info
+log: *defaults
+script: |
+  echo one
+  echo two
+`
+	got, err := parseConfigYAML(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"QBT_WEBUI_PORT":    "8080",
+		"PUSHOVER_USER_KEY": "abc # not a comment",
+		"PUSHOVER_TOKEN":    "it's",
+		"TRACKERS":          "udp://a:1337,udp://b:1337",
+		"TAGS":              "one,two",
+		"RATIO":             "1.50",
+		"UMASK":             "0027",
+		"DEFAULTS_LEVEL":    "info",
+		"LOG_LEVEL":         "info",
+		"SCRIPT":            "echo one\necho two\n",
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestParseConfigYAMLEmpty(t *testing.T) {
+	for _, doc := range []string{"", "# nothing\n", "---\n"} {
+		got, err := parseConfigYAML(doc)
+		if err != nil || len(got) != 0 {
+			t.Errorf("%q: got %v, %v", doc, got, err)
+		}
+	}
+}
+
+func TestParseConfigYAMLInvalid(t *testing.T) {
+	for name, doc := range map[string]string{
+		"not a mapping":   "- a\n- b\n",
+		"same variable":   "a-b: 1\na_b: 2\n",
+		"invalid name":    "1st: x\n",
+		"nested list":     "a:\n  - [x]\n",
+		"mapping in list": "a:\n  - b: c\n",
+		"syntax":          "a: [1, 2\n",
+		"tab indentation": "a:\n\tb: c\n",
+	} {
+		if got, err := parseConfigYAML(doc); err == nil {
+			t.Errorf("%s: got %v, want an error", name, got)
+		}
+	}
+}
+
+func TestLoadConfigYAMLKeepsEnvironment(t *testing.T) {
+	t.Setenv("CONFIG_YAML", "qbt_test_set: yaml\nqbt_test_unset: yaml\n")
+	t.Setenv("QBT_TEST_SET", "env")
+	t.Setenv("QBT_TEST_UNSET", "")
+	if err := loadConfigYAML(); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("QBT_TEST_SET"); got != "env" {
+		t.Errorf("QBT_TEST_SET = %q, want the environment's value", got)
+	}
+	if got := os.Getenv("QBT_TEST_UNSET"); got != "yaml" {
+		t.Errorf("QBT_TEST_UNSET = %q, want yaml", got)
+	}
+}
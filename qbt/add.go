@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxTorrentFileSize = 64 << 20
+
+func runAdd(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	category := flags.String("category", "", "category to assign")
+	tags := flags.String("tags", "", "comma-separated tags to assign")
+	savePath := flags.String("save-path", "", "download location (disables automatic torrent management)")
+	paused := flags.Bool("paused", false, "add torrents in stopped state")
+	skipCheck := flags.Bool("skip-hash-check", false, "skip hash checking")
+	sequential := flags.Bool("sequential", false, "download pieces in sequential order")
+	firstLast := flags.Bool("first-last", false, "download first and last pieces first")
+	rename := flags.String("rename", "", "rename the torrent")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: qbt add [flags] <magnet|url|file|->...")
+	}
+
+	var urls []string
+	files := make(map[string][]byte)
+	for _, source := range flags.Args() {
+		switch {
+		case source == "-":
+			data, err := readTorrentData(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read torrent from stdin: %w", err)
+			}
+			files["stdin.torrent"] = data
+		case isTorrentURL(source):
+			urls = append(urls, source)
+		default:
+			f, err := os.Open(source)
+			if err != nil {
+				return err
+			}
+			data, err := readTorrentData(f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", source, err)
+			}
+			name := filepath.Base(source)
+			if _, dup := files[name]; dup {
+				name = fmt.Sprintf("%d-%s", len(files), name)
+			}
+			files[name] = data
+		}
+	}
+
+	if *rename != "" && len(urls)+len(files) > 1 {
+		return errors.New("--rename can only be used when adding a single torrent")
+	}
+
+	fields := map[string]string{
+		"paused":             fmt.Sprint(*paused),
+		"stopped":            fmt.Sprint(*paused),
+		"skip_checking":      fmt.Sprint(*skipCheck),
+		"sequentialDownload": fmt.Sprint(*sequential),
+		"firstLastPiecePrio": fmt.Sprint(*firstLast),
+	}
+	if len(urls) > 0 {
+		fields["urls"] = strings.Join(urls, "\n")
+	}
+	if *category != "" {
+		fields["category"] = *category
+	}
+	if *tags != "" {
+		fields["tags"] = *tags
+	}
+	if *savePath != "" {
+		fields["savepath"] = *savePath
+		fields["autoTMM"] = "false"
+	}
+	if *rename != "" {
+		fields["rename"] = *rename
+	}
+
+	result, err := client.postMultipart(ctx, "/api/v2/torrents/add", fields, files, "torrents")
+	if err != nil {
+		return err
+	}
+	if result == "Fails." {
+		return errors.New("qBittorrent rejected the torrent (invalid or already added)")
+	}
+
+	fmt.Printf("added %d torrent(s)\n", len(urls)+len(files))
+	return nil
+}
+
+func isTorrentURL(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "magnet:") ||
+		strings.HasPrefix(lower, "http://") ||
+		strings.HasPrefix(lower, "https://") ||
+		strings.HasPrefix(lower, "bc://bt/")
+}
+
+func readTorrentData(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxTorrentFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxTorrentFileSize {
+		return nil, fmt.Errorf("torrent exceeds %d bytes", maxTorrentFileSize)
+	}
+	if len(data) == 0 || data[0] != 'd' {
+		return nil, errors.New("not a torrent file")
+	}
+	return data, nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type categoryInfo struct {
+	Name         string `json:"name"`
+	SavePath     string `json:"savePath"`
+	DownloadPath any    `json:"download_path,omitempty"`
+}
+
+func runCategory(ctx context.Context, client *apiClient, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: qbt category list | create | edit | delete | recategorize")
+	}
+	switch args[0] {
+	case "list":
+		return runCategoryList(ctx, client, args[1:])
+	case "create", "edit":
+		return runCategoryUpsert(ctx, client, args[0], args[1:])
+	case "delete":
+		if len(args) < 2 {
+			return errors.New("usage: qbt category delete <name>...")
+		}
+		if err := client.postForm(ctx, "/api/v2/torrents/removeCategories", url.Values{
+			"categories": {strings.Join(args[1:], "\n")},
+		}); err != nil {
+			return err
+		}
+		fmt.Printf("deleted %d category(ies)\n", len(args)-1)
+		return nil
+	case "recategorize":
+		return runRecategorize(ctx, client, args[1:])
+	default:
+		return fmt.Errorf("unknown category subcommand %q", args[0])
+	}
+}
+
+func runCategoryList(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("category list", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var categories map[string]categoryInfo
+	if err := client.getJSON(ctx, "/api/v2/torrents/categories", nil, &categories); err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(categories)
+	}
+
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	t := newTable(os.Stdout, "NAME", "SAVE PATH")
+	for _, name := range names {
+		t.row(name, categories[name].SavePath)
+	}
+	return t.flush()
+}
+
+func runCategoryUpsert(ctx context.Context, client *apiClient, action string, args []string) error {
+	flags := flag.NewFlagSet("category "+action, flag.ContinueOnError)
+	savePath := flags.String("save-path", "", "category save path")
+	downloadPath := flags.String("download-path", "", "category incomplete download path")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: qbt category %s [--save-path p] [--download-path p] <name>", action)
+	}
+
+	form := url.Values{
+		"category": {flags.Arg(0)},
+		"savePath": {*savePath},
+	}
+	if *downloadPath != "" {
+		form.Set("downloadPathEnabled", "true")
+		form.Set("downloadPath", *downloadPath)
+	}
+
+	endpoint := "/api/v2/torrents/createCategory"
+	if action == "edit" {
+		endpoint = "/api/v2/torrents/editCategory"
+	}
+	if err := client.postForm(ctx, endpoint, form); err != nil {
+		return err
+	}
+	verb := "created"
+	if action == "edit" {
+		verb = "updated"
+	}
+	fmt.Printf("%s category %s\n", verb, flags.Arg(0))
+	return nil
+}
+
+func runRecategorize(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("category recategorize", flag.ContinueOnError)
+	target := flags.String("to", "", "category to assign (empty string removes the category)")
+	namePattern := flags.String("name", "", "select torrents whose name matches this regular expression")
+	var sel torrentSelector
+	sel.register(flags)
+	dryRun := flags.Bool("dry-run", false, "print the changes without applying them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	targetSet := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "to" {
+			targetSet = true
+		}
+	})
+	if !targetSet || (*namePattern == "" && !sel.active() && flags.NArg() == 0) {
+		return errors.New("usage: qbt category recategorize --to <category> [--name regex] [--tracker glob] [--category c] [--tag t] [<hash>...]")
+	}
+
+	var nameRe *regexp.Regexp
+	if *namePattern != "" {
+		var err error
+		if nameRe, err = regexp.Compile(*namePattern); err != nil {
+			return fmt.Errorf("invalid --name pattern: %w", err)
+		}
+	}
+
+	var hashes []string
+	if sel.active() || flags.NArg() > 0 {
+		var err error
+		if hashes, err = sel.resolve(ctx, client, flags.Args()); err != nil {
+			return err
+		}
+	}
+
+	query := url.Values{}
+	if len(hashes) > 0 && hashes[0] != "all" {
+		query.Set("hashes", strings.Join(hashes, "|"))
+	}
+	if sel.active() && len(hashes) == 0 {
+		fmt.Println("recategorize: no matching torrents")
+		return nil
+	}
+	torrents, err := client.torrents(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	var selected []string
+	for _, t := range torrents {
+		if nameRe != nil && !nameRe.MatchString(t.Name) {
+			continue
+		}
+		if t.Category == *target {
+			continue
+		}
+		fmt.Printf("%s  %s: %q -> %q\n", shortHash(t.Hash), t.Name, t.Category, *target)
+		selected = append(selected, t.Hash)
+	}
+
+	if len(selected) == 0 {
+		fmt.Println("recategorize: no matching torrents")
+		return nil
+	}
+	if *dryRun {
+		return nil
+	}
+
+	if err := client.postForm(ctx, "/api/v2/torrents/setCategory", url.Values{
+		"hashes":   {strings.Join(selected, "|")},
+		"category": {*target},
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("recategorized %d torrent(s)\n", len(selected))
+	return nil
+}
+
+func runTag(ctx context.Context, client *apiClient, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: qbt tag list | create | delete | add | remove")
+	}
+	switch args[0] {
+	case "list":
+		var tags []string
+		if err := client.getJSON(ctx, "/api/v2/torrents/tags", nil, &tags); err != nil {
+			return err
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			fmt.Println(tag)
+		}
+		return nil
+	case "create", "delete":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: qbt tag %s <tag>...", args[0])
+		}
+		endpoint := "/api/v2/torrents/createTags"
+		if args[0] == "delete" {
+			endpoint = "/api/v2/torrents/deleteTags"
+		}
+		if err := client.postForm(ctx, endpoint, url.Values{"tags": {strings.Join(args[1:], ",")}}); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d tag(s)\n", args[0], len(args)-1)
+		return nil
+	case "add", "remove":
+		return runTagTorrents(ctx, client, args[0], args[1:])
+	default:
+		return fmt.Errorf("unknown tag subcommand %q", args[0])
+	}
+}
+
+func runTagTorrents(ctx context.Context, client *apiClient, action string, args []string) error {
+	usage := fmt.Errorf("usage: qbt tag %s <tags> [--category c] [--tag t] [--tracker glob] [<hash>... | all]", action)
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usage
+	}
+	tags := args[0]
+
+	flags := flag.NewFlagSet("tag "+action, flag.ContinueOnError)
+	var sel torrentSelector
+	sel.register(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if !sel.active() && flags.NArg() == 0 {
+		return usage
+	}
+
+	hashes, err := sel.resolve(ctx, client, flags.Args())
+	if err != nil {
+		return err
+	}
+	if len(hashes) == 0 {
+		fmt.Printf("tag %s: no matching torrents\n", action)
+		return nil
+	}
+
+	endpoint := "/api/v2/torrents/addTags"
+	if action == "remove" {
+		endpoint = "/api/v2/torrents/removeTags"
+	}
+	return applyHashAction("tag "+action, hashes, func(h string) error {
+		return client.postForm(ctx, endpoint, url.Values{"hashes": {h}, "tags": {tags}})
+	})
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	errNotFound  = errors.New("endpoint not found")
+	errForbidden = errors.New("request rejected: authentication required (set QBT_USERNAME and QBT_PASSWORD)")
+)
+
+type apiClient struct {
+	baseURL  string
+	username string
+	password string
+	// passwordFile is read at login, so a password rotated by
+	// qbittorrent-init is picked up; password is used while it is missing.
+	passwordFile string
+	httpClient   *http.Client
+	loggedIn     bool
+}
+
+func newAPIClient() *apiClient {
+	jar, _ := cookiejar.New(nil)
+	return &apiClient{
+		baseURL:      strings.TrimRight(getEnv("QBT_WEBUI_URL", "http://127.0.0.1:8080"), "/"),
+		username:     getEnv("QBT_USERNAME", ""),
+		password:     getEnv("QBT_PASSWORD", ""),
+		passwordFile: getEnv("QBT_PASSWORD_FILE", ""),
+		httpClient:   &http.Client{Timeout: 30 * time.Second, Jar: jar},
+	}
+}
+
+func (c *apiClient) login(ctx context.Context) error {
+	password := c.password
+	if c.passwordFile != "" {
+		data, err := os.ReadFile(c.passwordFile)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && c.password != "":
+			// The password has not been rotated yet.
+		case err != nil:
+			return fmt.Errorf("failed to read password file: %w", err)
+		default:
+			password = strings.TrimSpace(string(data))
+		}
+	}
+
+	form := url.Values{"username": {c.username}, "password": {password}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v2/auth/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", c.baseURL)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("login request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "Ok." {
+		return fmt.Errorf("login failed: %s", strings.TrimSpace(string(body)))
+	}
+	c.loggedIn = true
+	return nil
+}
+
+func (c *apiClient) do(ctx context.Context, method, apiPath string, query url.Values, body func() (io.Reader, string, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		target := c.baseURL + apiPath
+		if len(query) > 0 {
+			target += "?" + query.Encode()
+		}
+
+		var (
+			reader      io.Reader
+			contentType string
+		)
+		if body != nil {
+			var err error
+			if reader, contentType, err = body(); err != nil {
+				return nil, err
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, target, reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("Referer", c.baseURL)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("request failed: %w", err)
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return resp, nil
+		case http.StatusForbidden, http.StatusUnauthorized:
+			resp.Body.Close()
+			if attempt > 0 || c.loggedIn || c.username == "" {
+				return nil, errForbidden
+			}
+			if err := c.login(ctx); err != nil {
+				return nil, err
+			}
+		case http.StatusNotFound:
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %w", apiPath, errNotFound)
+		default:
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			resp.Body.Close()
+			if text := strings.TrimSpace(string(msg)); text != "" {
+				return nil, fmt.Errorf("%s: unexpected status %d: %s", apiPath, resp.StatusCode, text)
+			}
+			return nil, fmt.Errorf("%s: unexpected status %d", apiPath, resp.StatusCode)
+		}
+	}
+}
+
+func (c *apiClient) getJSON(ctx context.Context, apiPath string, query url.Values, out any) error {
+	resp, err := c.do(ctx, http.MethodGet, apiPath, query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", apiPath, err)
+	}
+	return nil
+}
+
+func (c *apiClient) getText(ctx context.Context, apiPath string, query url.Values) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, apiPath, query, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s response: %w", apiPath, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (c *apiClient) postForm(ctx context.Context, apiPath string, form url.Values) error {
+	resp, err := c.do(ctx, http.MethodPost, apiPath, nil, func() (io.Reader, string, error) {
+		return strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	return nil
+}
+
+func (c *apiClient) postFormJSON(ctx context.Context, apiPath string, form url.Values, out any) error {
+	resp, err := c.do(ctx, http.MethodPost, apiPath, nil, func() (io.Reader, string, error) {
+		return strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", apiPath, err)
+	}
+	return nil
+}
+
+func (c *apiClient) postMultipart(ctx context.Context, apiPath string, fields map[string]string, files map[string][]byte, fileField string) (string, error) {
+	resp, err := c.do(ctx, http.MethodPost, apiPath, nil, func() (io.Reader, string, error) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for k, v := range fields {
+			if err := mw.WriteField(k, v); err != nil {
+				return nil, "", err
+			}
+		}
+		for name, data := range files {
+			part, err := mw.CreateFormFile(fileField, name)
+			if err != nil {
+				return nil, "", err
+			}
+			if _, err := part.Write(data); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, mw.FormDataContentType(), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s response: %w", apiPath, err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// postCompat posts to the qBittorrent 5.x endpoint and falls back to the
+// pre-5.0 name when the server does not know it.
+func (c *apiClient) postCompat(ctx context.Context, apiPath, legacyPath string, form url.Values) error {
+	err := c.postForm(ctx, apiPath, form)
+	if errors.Is(err, errNotFound) {
+		return c.postForm(ctx, legacyPath, form)
+	}
+	return err
+}
+
+type torrentInfo struct {
+	Hash         string  `json:"hash"`
+	Name         string  `json:"name"`
+	State        string  `json:"state"`
+	Category     string  `json:"category"`
+	Tags         string  `json:"tags"`
+	Tracker      string  `json:"tracker"`
+	SavePath     string  `json:"save_path"`
+	ContentPath  string  `json:"content_path"`
+	Size         int64   `json:"size"`
+	Progress     float64 `json:"progress"`
+	Ratio        float64 `json:"ratio"`
+	DlSpeed      int64   `json:"dlspeed"`
+	UpSpeed      int64   `json:"upspeed"`
+	Downloaded   int64   `json:"downloaded"`
+	Uploaded     int64   `json:"uploaded"`
+	NumSeeds     int     `json:"num_seeds"`
+	NumLeechs    int     `json:"num_leechs"`
+	Eta          int64   `json:"eta"`
+	AddedOn      int64   `json:"added_on"`
+	CompletionOn int64   `json:"completion_on"`
+	SeedingTime  int64   `json:"seeding_time"`
+	LastActivity int64   `json:"last_activity"`
+}
+
+func (c *apiClient) torrents(ctx context.Context, query url.Values) ([]torrentInfo, error) {
+	var torrents []torrentInfo
+	if err := c.getJSON(ctx, "/api/v2/torrents/info", query, &torrents); err != nil {
+		return nil, err
+	}
+	return torrents, nil
+}
+
+type torrentTracker struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Tier     int    `json:"tier"`
+	NumPeers int    `json:"num_peers"`
+	Msg      string `json:"msg"`
+}
+
+func (c *apiClient) torrentTrackers(ctx context.Context, hash string) ([]torrentTracker, error) {
+	var trackers []torrentTracker
+	if err := c.getJSON(ctx, "/api/v2/torrents/trackers", url.Values{"hash": {hash}}, &trackers); err != nil {
+		return nil, err
+	}
+	return trackers, nil
+}
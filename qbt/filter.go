@@ -0,0 +1,401 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// A filterExpr is a list of conditions that must all match, e.g.
+//
+//	state=stalledUP tracker~=redacted ratio>2 added<30d
+//
+// String fields compare case-insensitively with = and !=, and as regular
+// expressions with ~= and !~. Numeric fields accept units (10GiB, 50%, 3d),
+// and the time fields added, completed and last_activity compare the age of
+// the timestamp, so added<30d selects torrents added in the last 30 days.
+type filterExpr []filterCond
+
+type filterCond struct {
+	field string
+	op    string
+	text  string
+	re    *regexp.Regexp
+	num   float64
+}
+
+type fieldKind int
+
+const (
+	stringField fieldKind = iota
+	numberField
+	sizeField
+	durationField
+	ageField
+	percentField
+)
+
+var filterFields = map[string]fieldKind{
+	"name":          stringField,
+	"hash":          stringField,
+	"state":         stringField,
+	"category":      stringField,
+	"tag":           stringField,
+	"tracker":       stringField,
+	"save_path":     stringField,
+	"ratio":         numberField,
+	"seeds":         numberField,
+	"leechs":        numberField,
+	"progress":      percentField,
+	"size":          sizeField,
+	"downloaded":    sizeField,
+	"uploaded":      sizeField,
+	"dlspeed":       sizeField,
+	"upspeed":       sizeField,
+	"eta":           durationField,
+	"seeding_time":  durationField,
+	"added":         ageField,
+	"completed":     ageField,
+	"last_activity": ageField,
+}
+
+// filterOps is ordered so that two-character operators are tried first.
+var filterOps = []string{"~=", "!~", "!=", ">=", "<=", "=", ">", "<"}
+
+func (f *filterExpr) String() string {
+	parts := make([]string, len(*f))
+	for i, c := range *f {
+		parts[i] = c.field + c.op + c.text
+	}
+	return strings.Join(parts, " ")
+}
+
+// Set parses an expression and appends its conditions, so the flag can be
+// repeated.
+func (f *filterExpr) Set(expr string) error {
+	conds, err := parseFilter(expr)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, conds...)
+	return nil
+}
+
+func parseFilter(expr string) (filterExpr, error) {
+	terms, err := splitFilterTerms(expr)
+	if err != nil {
+		return nil, err
+	}
+	var conds filterExpr
+	for _, term := range terms {
+		c, err := parseFilterCond(term)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, c)
+	}
+	return conds, nil
+}
+
+// splitFilterTerms splits on whitespace and commas outside double quotes and
+// strips the quotes, so name~="foo bar" stays a single term.
+func splitFilterTerms(expr string) ([]string, error) {
+	var (
+		terms   []string
+		current strings.Builder
+		quoted  bool
+	)
+	flush := func() {
+		if current.Len() > 0 {
+			terms = append(terms, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (unicode.IsSpace(r) || r == ','):
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in %q", expr)
+	}
+	flush()
+	return terms, nil
+}
+
+func parseFilterCond(term string) (filterCond, error) {
+	end := strings.IndexFunc(term, func(r rune) bool { return !(r == '_' || unicode.IsLetter(r)) })
+	if end <= 0 {
+		return filterCond{}, fmt.Errorf("invalid condition %q", term)
+	}
+	c := filterCond{field: strings.ToLower(term[:end])}
+	rest := term[end:]
+	for _, op := range filterOps {
+		if strings.HasPrefix(rest, op) {
+			c.op = op
+			c.text = rest[len(op):]
+			break
+		}
+	}
+	if c.op == "" {
+		return filterCond{}, fmt.Errorf("invalid condition %q (expected field, operator and value)", term)
+	}
+	if c.field == "tags" {
+		c.field = "tag"
+	}
+
+	kind, ok := filterFields[c.field]
+	if !ok {
+		return filterCond{}, fmt.Errorf("unknown filter field %q", c.field)
+	}
+
+	if kind == stringField {
+		switch c.op {
+		case "=", "!=":
+		case "~=", "!~":
+			re, err := regexp.Compile("(?i)" + c.text)
+			if err != nil {
+				return filterCond{}, fmt.Errorf("%s: %w", term, err)
+			}
+			c.re = re
+		default:
+			return filterCond{}, fmt.Errorf("%s: operator %s is not supported for %s", term, c.op, c.field)
+		}
+		return c, nil
+	}
+
+	if c.op == "~=" || c.op == "!~" {
+		return filterCond{}, fmt.Errorf("%s: operator %s is only supported for text fields", term, c.op)
+	}
+	var err error
+	switch kind {
+	case numberField:
+		c.num, err = strconv.ParseFloat(c.text, 64)
+	case percentField:
+		c.num, err = parsePercent(c.text)
+	case sizeField:
+		c.num, err = parseSize(c.text)
+	case durationField, ageField:
+		var d time.Duration
+		d, err = parseAge(c.text)
+		c.num = d.Seconds()
+	}
+	if err != nil {
+		return filterCond{}, fmt.Errorf("%s: %w", term, err)
+	}
+	return c, nil
+}
+
+func (f filterExpr) match(t torrentInfo, now time.Time) bool {
+	for _, c := range f {
+		if !c.match(t, now) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c filterCond) match(t torrentInfo, now time.Time) bool {
+	switch c.field {
+	case "name":
+		return c.matchString(t.Name)
+	case "hash":
+		return c.matchString(t.Hash)
+	case "state":
+		return c.matchString(t.State)
+	case "category":
+		return c.matchString(t.Category)
+	case "save_path":
+		return c.matchString(t.SavePath)
+	case "tag":
+		return c.matchTags(t.Tags)
+	case "tracker":
+		return c.matchTracker(t.Tracker)
+	case "ratio":
+		return c.compare(t.Ratio)
+	case "seeds":
+		return c.compare(float64(t.NumSeeds))
+	case "leechs":
+		return c.compare(float64(t.NumLeechs))
+	case "progress":
+		return c.compare(t.Progress)
+	case "size":
+		return c.compare(float64(t.Size))
+	case "downloaded":
+		return c.compare(float64(t.Downloaded))
+	case "uploaded":
+		return c.compare(float64(t.Uploaded))
+	case "dlspeed":
+		return c.compare(float64(t.DlSpeed))
+	case "upspeed":
+		return c.compare(float64(t.UpSpeed))
+	case "eta":
+		return c.compare(float64(t.Eta))
+	case "seeding_time":
+		return c.compare(float64(t.SeedingTime))
+	case "added":
+		return c.compareAge(t.AddedOn, now)
+	case "completed":
+		return c.compareAge(t.CompletionOn, now)
+	case "last_activity":
+		return c.compareAge(t.LastActivity, now)
+	}
+	return false
+}
+
+func (c filterCond) matchString(s string) bool {
+	switch c.op {
+	case "=":
+		return strings.EqualFold(s, c.text)
+	case "!=":
+		return !strings.EqualFold(s, c.text)
+	case "~=":
+		return c.re.MatchString(s)
+	case "!~":
+		return !c.re.MatchString(s)
+	}
+	return false
+}
+
+// matchTags treats = and ~= as "any tag matches" and their negations as "no
+// tag matches".
+func (c filterCond) matchTags(tags string) bool {
+	positive := filterCond{field: c.field, op: c.op, text: c.text, re: c.re}
+	switch c.op {
+	case "!=":
+		positive.op = "="
+	case "!~":
+		positive.op = "~="
+	}
+	found := false
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" && positive.matchString(tag) {
+			found = true
+			break
+		}
+	}
+	if positive.op != c.op {
+		return !found
+	}
+	return found
+}
+
+func (c filterCond) matchTracker(trackerURL string) bool {
+	switch c.op {
+	case "=":
+		return trackerMatches(c.text, trackerURL)
+	case "!=":
+		return !trackerMatches(c.text, trackerURL)
+	}
+	return c.matchString(trackerURL)
+}
+
+func (c filterCond) compare(v float64) bool {
+	switch c.op {
+	case "=":
+		return v == c.num
+	case "!=":
+		return v != c.num
+	case ">":
+		return v > c.num
+	case ">=":
+		return v >= c.num
+	case "<":
+		return v < c.num
+	case "<=":
+		return v <= c.num
+	}
+	return false
+}
+
+// compareAge compares how long ago unix was; unset timestamps never match.
+func (c filterCond) compareAge(unix int64, now time.Time) bool {
+	if unix <= 0 {
+		return false
+	}
+	return c.compare(now.Sub(time.Unix(unix, 0)).Seconds())
+}
+
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kib": 1 << 10,
+	"kb":  1e3,
+	"m":   1 << 20,
+	"mib": 1 << 20,
+	"mb":  1e6,
+	"g":   1 << 30,
+	"gib": 1 << 30,
+	"gb":  1e9,
+	"t":   1 << 40,
+	"tib": 1 << 40,
+	"tb":  1e12,
+}
+
+func parseSize(s string) (float64, error) {
+	num, unit := splitNumber(s)
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	mult, ok := sizeUnits[strings.TrimSuffix(strings.ToLower(unit), "/s")]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit %q", unit)
+	}
+	return n * mult, nil
+}
+
+func parsePercent(s string) (float64, error) {
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		n, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid percentage %q", s)
+		}
+		return n / 100, nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid progress %q", s)
+	}
+	return n, nil
+}
+
+// parseAge parses a Go duration extended with d (days) and w (weeks); a bare
+// number is taken as seconds.
+func parseAge(s string) (time.Duration, error) {
+	num, unit := splitNumber(s)
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	switch unit {
+	case "":
+		return time.Duration(n * float64(time.Second)), nil
+	case "d":
+		return time.Duration(n * float64(24*time.Hour)), nil
+	case "w":
+		return time.Duration(n * float64(7*24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+func splitNumber(s string) (string, string) {
+	i := strings.IndexFunc(s, func(r rune) bool { return !(unicode.IsDigit(r) || r == '.' || r == '-') })
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
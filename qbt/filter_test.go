@@ -0,0 +1,120 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilterMatch(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	torrent := torrentInfo{
+		Name:         "Some.Show.S01.1080p",
+		State:        "stalledUP",
+		Category:     "tv",
+		Tags:         "cross-seed, keep",
+		Tracker:      "https://tracker.example.org/announce",
+		Size:         10 << 30,
+		Progress:     0.5,
+		Ratio:        2.5,
+		NumSeeds:     4,
+		Eta:          3600,
+		AddedOn:      now.Add(-10 * 24 * time.Hour).Unix(),
+		LastActivity: 0,
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"state=stalledup", true},
+		{"state!=stalledUP", false},
+		{"name~=s01", true},
+		{"name!~s01", false},
+		{"name~=s02", false},
+		{`name~="show s01"`, false},
+		{"tag=keep", true},
+		{"tag!=keep", false},
+		{"tags~=^cross", true},
+		{"tag!~^cross", false},
+		{"tracker=example.org", true},
+		{"tracker=tracker.example.org", true},
+		{"tracker!=example.org", false},
+		{"tracker=other.org", false},
+		{"ratio>2", true},
+		{"ratio>=2.5", true},
+		{"ratio<2.5", false},
+		{"ratio<=2.5", true},
+		{"ratio=2.5", true},
+		{"ratio!=2.5", false},
+		{"seeds>3", true},
+		{"size>=10GiB", true},
+		{"size>10GiB", false},
+		{"size>10GB", true},
+		{"progress=50%", true},
+		{"progress<0.6", true},
+		{"eta=1h", true},
+		{"eta=3600", true},
+		{"eta<59m", false},
+		{"added<30d", true},
+		{"added>30d", false},
+		{"added>1w", true},
+		{"added<1w", false},
+		{"last_activity<30d", false},
+		{"last_activity>30d", false},
+		{"category=tv ratio>2 added<30d", true},
+		{"category=tv,ratio>3", false},
+	}
+	for _, tt := range tests {
+		f, err := parseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("parseFilter(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := f.match(torrent, now); got != tt.want {
+			t.Errorf("%q: match = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"state",
+		"=stalledUP",
+		"bogus=1",
+		"ratio~=2",
+		"state>1",
+		"name~=(",
+		`name="unterminated`,
+		"ratio>many",
+		"size>10XB",
+		"progress>half%",
+		"added<30y",
+	} {
+		if _, err := parseFilter(expr); err == nil {
+			t.Errorf("parseFilter(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"90", 90 * time.Second},
+		{"1.5d", 36 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"30m", 30 * time.Minute},
+		{"1h30m", 90 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, err := parseAge(tt.in)
+		if err != nil {
+			t.Errorf("parseAge(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAge(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/qbittorrent-distroless/qbt
+
+go 1.24.2
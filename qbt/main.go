@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, client *apiClient, args []string) error
+}
+
+var commands = map[string]command{
+	"add":        {usage: "add torrents from magnets, URLs, files or stdin", run: runAdd},
+	"category":   {usage: "manage categories and bulk re-categorize torrents", run: runCategory},
+	"delete":     {usage: "delete torrents", run: runDelete},
+	"info":       {usage: "show details of a torrent", run: runInfo},
+	"list":       {usage: "list torrents", run: runList},
+	"pause":      {usage: "pause (stop) torrents", run: runPause},
+	"prefs":      {usage: "get or set application preferences", run: runPrefs},
+	"reannounce": {usage: "force a reannounce of torrents to their trackers", run: runReannounce},
+	"recheck":    {usage: "force a hash recheck of torrents", run: runRecheck},
+	"resume":     {usage: "resume (start) torrents", run: runResume},
+	"rss":        {usage: "manage RSS feeds and import or export auto-download rules", run: runRSS},
+	"search":     {usage: "search using installed search plugins", run: runSearch},
+	"stats":      {usage: "export transfer, torrent and tracker statistics as JSON or CSV", run: runStats},
+	"tag":        {usage: "manage tags and tag or untag torrents", run: runTag},
+	"top":        {usage: "live dashboard of torrents, rates and tracker errors", run: runTop},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	switch name {
+	case "-h", "--help", "help":
+		printUsage()
+		return
+	case "-v", "--version", "version":
+		fmt.Printf("qbt %s (commit %s, built %s)\n", version, commit, date)
+		return
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "qbt: unknown command %q\n\n", name)
+		printUsage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := cmd.run(ctx, newAPIClient(), os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "qbt %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: qbt <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Environment:")
+	fmt.Fprintln(os.Stderr, "  QBT_WEBUI_URL   WebUI address (default http://127.0.0.1:8080)")
+	fmt.Fprintln(os.Stderr, "  QBT_USERNAME    WebUI username, if authentication is required")
+	fmt.Fprintln(os.Stderr, "  QBT_PASSWORD    WebUI password")
+}
+
+func getEnv(key, defaultValue string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defaultValue
+}
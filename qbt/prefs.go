@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func runPrefs(ctx context.Context, client *apiClient, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: qbt prefs get [key...] | qbt prefs set [-f patch.json] [key=value...]")
+	}
+	switch args[0] {
+	case "get":
+		return runPrefsGet(ctx, client, args[1:])
+	case "set":
+		return runPrefsSet(ctx, client, args[1:])
+	default:
+		return fmt.Errorf("unknown prefs subcommand %q", args[0])
+	}
+}
+
+func fetchPreferences(ctx context.Context, client *apiClient) (map[string]any, error) {
+	var prefs map[string]any
+	if err := client.getJSON(ctx, "/api/v2/app/preferences", nil, &prefs); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+func runPrefsGet(ctx context.Context, client *apiClient, args []string) error {
+	prefs, err := fetchPreferences(ctx, client)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return printJSON(prefs)
+	}
+
+	if len(args) == 1 {
+		v, ok := prefs[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown preference %q", args[0])
+		}
+		if s, ok := v.(string); ok {
+			fmt.Println(s)
+			return nil
+		}
+		return printJSON(v)
+	}
+
+	selected := make(map[string]any, len(args))
+	for _, key := range args {
+		v, ok := prefs[key]
+		if !ok {
+			return fmt.Errorf("unknown preference %q", key)
+		}
+		selected[key] = v
+	}
+	return printJSON(selected)
+}
+
+func runPrefsSet(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("prefs set", flag.ContinueOnError)
+	file := flags.String("f", "", "JSON merge patch file (- for stdin)")
+	dryRun := flags.Bool("dry-run", false, "print the diff without applying it")
+	force := flags.Bool("force", false, "allow keys that the server does not report")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" && flags.NArg() == 0 {
+		return errors.New("usage: qbt prefs set [-f patch.json] [--dry-run] [key=value...]")
+	}
+
+	current, err := fetchPreferences(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	patch := make(map[string]any)
+	if *file != "" {
+		var r io.Reader = os.Stdin
+		if *file != "-" {
+			f, err := os.Open(*file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		if err := json.NewDecoder(r).Decode(&patch); err != nil {
+			return fmt.Errorf("failed to parse patch: %w", err)
+		}
+	}
+	for _, arg := range flags.Args() {
+		key, raw, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid assignment %q (expected key=value)", arg)
+		}
+		v, err := coercePreference(current[key], raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		patch[key] = v
+	}
+
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	changes := make(map[string]any)
+	for _, key := range keys {
+		value := patch[key]
+		old, known := current[key]
+		if !known && !*force {
+			return fmt.Errorf("unknown preference %q (use --force to send it anyway)", key)
+		}
+		if value == nil {
+			return fmt.Errorf("%s: preferences cannot be removed", key)
+		}
+		if known && old != nil && reflect.TypeOf(old) != reflect.TypeOf(value) {
+			return fmt.Errorf("%s: expected %s, got %s", key, jsonKind(old), jsonKind(value))
+		}
+		if reflect.DeepEqual(old, value) {
+			continue
+		}
+		changes[key] = value
+		fmt.Printf("%s: %s -> %s\n", key, compactJSON(old), compactJSON(value))
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("no changes")
+		return nil
+	}
+	if *dryRun {
+		return nil
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	if err := client.postForm(ctx, "/api/v2/app/setPreferences", url.Values{"json": {string(data)}}); err != nil {
+		return err
+	}
+	fmt.Printf("updated %d preference(s)\n", len(changes))
+	return nil
+}
+
+func coercePreference(current any, raw string) (any, error) {
+	switch current.(type) {
+	case string:
+		return raw, nil
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected a boolean, got %q", raw)
+		}
+		return b, nil
+	case float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number, got %q", raw)
+		}
+		return n, nil
+	default:
+		var v any
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			return raw, nil
+		}
+		return v, nil
+	}
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func compactJSON(v any) string {
+	if v == nil {
+		return "(unset)"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type rssFeed struct {
+	Path string `json:"path"`
+	UID  string `json:"uid"`
+	URL  string `json:"url"`
+}
+
+func runRSS(ctx context.Context, client *apiClient, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: qbt rss list | add | remove | refresh | rules")
+	}
+	switch args[0] {
+	case "list":
+		return runRSSList(ctx, client, args[1:])
+	case "add":
+		return runRSSAdd(ctx, client, args[1:])
+	case "remove":
+		if len(args) < 2 {
+			return errors.New("usage: qbt rss remove <path>...")
+		}
+		for _, path := range args[1:] {
+			if err := client.postForm(ctx, "/api/v2/rss/removeItem", url.Values{"path": {path}}); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+		fmt.Printf("removed %d item(s)\n", len(args)-1)
+		return nil
+	case "refresh":
+		paths := args[1:]
+		if len(paths) == 0 {
+			paths = []string{""}
+		}
+		for _, path := range paths {
+			if err := client.postForm(ctx, "/api/v2/rss/refreshItem", url.Values{"itemPath": {path}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "rules":
+		return runRSSRules(ctx, client, args[1:])
+	default:
+		return fmt.Errorf("unknown rss subcommand %q", args[0])
+	}
+}
+
+func fetchRSSFeeds(ctx context.Context, client *apiClient) ([]rssFeed, error) {
+	var items map[string]any
+	if err := client.getJSON(ctx, "/api/v2/rss/items", nil, &items); err != nil {
+		return nil, err
+	}
+	var feeds []rssFeed
+	collectRSSFeeds(items, "", &feeds)
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].Path < feeds[j].Path })
+	return feeds, nil
+}
+
+// collectRSSFeeds walks the folder tree returned by rss/items. Feeds are the
+// objects carrying a url; every other object is a folder.
+func collectRSSFeeds(items map[string]any, prefix string, feeds *[]rssFeed) {
+	for name, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + `\` + name
+		}
+		if feedURL, ok := obj["url"].(string); ok {
+			uid, _ := obj["uid"].(string)
+			*feeds = append(*feeds, rssFeed{Path: path, UID: uid, URL: feedURL})
+			continue
+		}
+		collectRSSFeeds(obj, path, feeds)
+	}
+}
+
+func runRSSList(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("rss list", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	feeds, err := fetchRSSFeeds(ctx, client)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(feeds)
+	}
+
+	t := newTable(os.Stdout, "PATH", "URL")
+	for _, f := range feeds {
+		t.row(f.Path, f.URL)
+	}
+	return t.flush()
+}
+
+func runRSSAdd(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("rss add", flag.ContinueOnError)
+	path := flags.String("path", "", `feed path, with folders separated by \ (default: feed title)`)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: qbt rss add [--path folder\\name] <url>")
+	}
+
+	// qBittorrent does not create missing folders when adding a feed.
+	if folder, _, ok := cutLast(*path, `\`); ok {
+		var prefix string
+		for _, part := range strings.Split(folder, `\`) {
+			prefix = strings.TrimPrefix(prefix+`\`+part, `\`)
+			// Fails with 409 when the folder already exists.
+			_ = client.postForm(ctx, "/api/v2/rss/addFolder", url.Values{"path": {prefix}})
+		}
+	}
+
+	if err := client.postForm(ctx, "/api/v2/rss/addFeed", url.Values{
+		"url":  {flags.Arg(0)},
+		"path": {*path},
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("added feed %s\n", flags.Arg(0))
+	return nil
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+func runRSSRules(ctx context.Context, client *apiClient, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: qbt rss rules list | export | import | remove")
+	}
+	switch args[0] {
+	case "list":
+		rules, err := fetchRSSRules(ctx, client)
+		if err != nil {
+			return err
+		}
+		t := newTable(os.Stdout, "NAME", "ENABLED", "MUST CONTAIN", "CATEGORY", "FEEDS")
+		for _, name := range sortedKeys(rules) {
+			rule := rules[name]
+			feeds, _ := rule["affectedFeeds"].([]any)
+			t.row(name, fmt.Sprint(rule["enabled"]), fmt.Sprint(rule["mustContain"]), fmt.Sprint(rule["assignedCategory"]), fmt.Sprint(len(feeds)))
+		}
+		return t.flush()
+	case "export":
+		return runRSSRulesExport(ctx, client, args[1:])
+	case "import":
+		return runRSSRulesImport(ctx, client, args[1:])
+	case "remove":
+		if len(args) < 2 {
+			return errors.New("usage: qbt rss rules remove <name>...")
+		}
+		for _, name := range args[1:] {
+			if err := client.postForm(ctx, "/api/v2/rss/removeRule", url.Values{"ruleName": {name}}); err != nil {
+				return fmt.Errorf("failed to remove rule %s: %w", name, err)
+			}
+		}
+		fmt.Printf("removed %d rule(s)\n", len(args)-1)
+		return nil
+	default:
+		return fmt.Errorf("unknown rss rules subcommand %q", args[0])
+	}
+}
+
+func fetchRSSRules(ctx context.Context, client *apiClient) (map[string]map[string]any, error) {
+	var rules map[string]map[string]any
+	if err := client.getJSON(ctx, "/api/v2/rss/rules", nil, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func runRSSRulesExport(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("rss rules export", flag.ContinueOnError)
+	output := flags.String("o", "", "write to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	rules, err := fetchRSSRules(ctx, client)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		return printJSON(rules)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(f, rules); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runRSSRulesImport makes the server's rules match a JSON file in the format
+// written by export. Rules missing from the file are kept unless --prune is
+// given.
+func runRSSRulesImport(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("rss rules import", flag.ContinueOnError)
+	prune := flags.Bool("prune", false, "remove rules that are not in the file")
+	dryRun := flags.Bool("dry-run", false, "print the changes without applying them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: qbt rss rules import [--prune] [--dry-run] <file | ->")
+	}
+
+	var r io.Reader = os.Stdin
+	if flags.Arg(0) != "-" {
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	var wanted map[string]map[string]any
+	if err := json.NewDecoder(r).Decode(&wanted); err != nil {
+		return fmt.Errorf("failed to parse rules: %w", err)
+	}
+
+	current, err := fetchRSSRules(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	changes := 0
+	for _, name := range sortedKeys(wanted) {
+		rule := wanted[name]
+		existing, exists := current[name]
+		if exists && rssRuleEqual(existing, rule) {
+			continue
+		}
+		verb := "add"
+		if exists {
+			verb = "update"
+		}
+		fmt.Printf("%s rule %s\n", verb, name)
+		changes++
+		if *dryRun {
+			continue
+		}
+		data, err := json.Marshal(rule)
+		if err != nil {
+			return err
+		}
+		if err := client.postForm(ctx, "/api/v2/rss/setRule", url.Values{
+			"ruleName": {name},
+			"ruleDef":  {string(data)},
+		}); err != nil {
+			return fmt.Errorf("failed to set rule %s: %w", name, err)
+		}
+	}
+
+	if *prune {
+		for _, name := range sortedKeys(current) {
+			if _, ok := wanted[name]; ok {
+				continue
+			}
+			fmt.Printf("remove rule %s\n", name)
+			changes++
+			if *dryRun {
+				continue
+			}
+			if err := client.postForm(ctx, "/api/v2/rss/removeRule", url.Values{"ruleName": {name}}); err != nil {
+				return fmt.Errorf("failed to remove rule %s: %w", name, err)
+			}
+		}
+	}
+
+	if changes == 0 {
+		fmt.Println("no changes")
+	}
+	return nil
+}
+
+// rssRuleEqual compares only the keys present in the wanted rule, so a file
+// written by an older qBittorrent does not show every rule as changed.
+func rssRuleEqual(existing, wanted map[string]any) bool {
+	for key, value := range wanted {
+		if !reflect.DeepEqual(existing[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type searchResult struct {
+	FileName   string `json:"fileName"`
+	FileURL    string `json:"fileUrl"`
+	FileSize   int64  `json:"fileSize"`
+	NbSeeders  int    `json:"nbSeeders"`
+	NbLeechers int    `json:"nbLeechers"`
+	SiteURL    string `json:"siteUrl"`
+	DescrLink  string `json:"descrLink"`
+}
+
+type searchPlugin struct {
+	Name     string `json:"name"`
+	FullName string `json:"fullName"`
+	Version  string `json:"version"`
+	Enabled  bool   `json:"enabled"`
+	URL      string `json:"url"`
+}
+
+func runSearch(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	plugins := flags.String("plugins", "enabled", "plugins to use: all, enabled or a comma-separated list")
+	category := flags.String("category", "all", "search category (all, movies, tv, music, games, anime, software, pictures, books)")
+	limit := flags.Int("limit", 50, "maximum number of results to print (0 for all)")
+	timeout := flags.Duration("timeout", time.Minute, "maximum time to wait for plugins to finish")
+	minSeeds := flags.Int("min-seeds", 0, "hide results with fewer seeders")
+	listPlugins := flags.Bool("list-plugins", false, "list installed search plugins and exit")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *listPlugins {
+		return printSearchPlugins(ctx, client, *asJSON)
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: qbt search [flags] <pattern>")
+	}
+
+	var job struct {
+		ID int `json:"id"`
+	}
+	err := client.postFormJSON(ctx, "/api/v2/search/start", url.Values{
+		"pattern":  {strings.Join(flags.Args(), " ")},
+		"plugins":  {strings.ReplaceAll(*plugins, ",", "|")},
+		"category": {*category},
+	}, &job)
+	if err != nil {
+		return fmt.Errorf("failed to start search: %w", err)
+	}
+	id := url.Values{"id": {fmt.Sprint(job.ID)}}
+	defer client.postForm(context.Background(), "/api/v2/search/delete", id)
+
+	results, err := waitForSearch(ctx, client, job.ID, *timeout)
+	if err != nil {
+		return err
+	}
+
+	filtered := results[:0]
+	for _, r := range results {
+		if r.NbSeeders >= *minSeeds {
+			filtered = append(filtered, r)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].NbSeeders > filtered[j].NbSeeders })
+	if *limit > 0 && len(filtered) > *limit {
+		filtered = filtered[:*limit]
+	}
+
+	if *asJSON {
+		return printJSON(filtered)
+	}
+
+	t := newTable(os.Stdout, "SEEDS", "PEERS", "SIZE", "SITE", "NAME", "URL")
+	for _, r := range filtered {
+		t.row(
+			fmt.Sprint(r.NbSeeders),
+			fmt.Sprint(r.NbLeechers),
+			formatBytes(r.FileSize),
+			siteHost(r.SiteURL),
+			truncate(r.FileName, 70),
+			r.FileURL,
+		)
+	}
+	return t.flush()
+}
+
+func waitForSearch(ctx context.Context, client *apiClient, id int, timeout time.Duration) ([]searchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	query := url.Values{"id": {fmt.Sprint(id)}}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var page struct {
+		Results []searchResult `json:"results"`
+		Status  string         `json:"status"`
+		Total   int            `json:"total"`
+	}
+	for ctx.Err() == nil {
+		if err := client.getJSON(ctx, "/api/v2/search/results", query, &page); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, fmt.Errorf("failed to fetch search results: %w", err)
+		}
+		if page.Status == "Stopped" {
+			return page.Results, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+		}
+	}
+
+	client.postForm(context.Background(), "/api/v2/search/stop", query)
+	fmt.Fprintf(os.Stderr, "qbt search: timed out after %s, showing partial results\n", timeout)
+	return page.Results, nil
+}
+
+func printSearchPlugins(ctx context.Context, client *apiClient, asJSON bool) error {
+	var plugins []searchPlugin
+	if err := client.getJSON(ctx, "/api/v2/search/plugins", nil, &plugins); err != nil {
+		return err
+	}
+	if asJSON {
+		return printJSON(plugins)
+	}
+
+	t := newTable(os.Stdout, "NAME", "VERSION", "ENABLED", "URL")
+	for _, p := range plugins {
+		t.row(p.Name, p.Version, fmt.Sprint(p.Enabled), p.URL)
+	}
+	return t.flush()
+}
+
+func siteHost(site string) string {
+	u, err := url.Parse(site)
+	if err != nil || u.Host == "" {
+		return site
+	}
+	return u.Host
+}
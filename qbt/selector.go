@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+type torrentSelector struct {
+	category    string
+	tag         string
+	tracker     string
+	stalledOnly bool
+	where       filterExpr
+}
+
+func (s *torrentSelector) register(flags *flag.FlagSet) {
+	flags.StringVar(&s.category, "category", "", "select torrents in this category")
+	flags.StringVar(&s.tag, "tag", "", "select torrents with this tag")
+	flags.StringVar(&s.tracker, "tracker", "", "select torrents whose tracker host or URL matches this glob")
+	flags.BoolVar(&s.stalledOnly, "stalled-only", false, "only select stalled torrents")
+	flags.Var(&s.where, "where", "select torrents matching a filter expression, e.g. 'state=stalledUP ratio>2 added<30d'")
+}
+
+func (s *torrentSelector) active() bool {
+	return s.category != "" || s.tag != "" || s.tracker != "" || s.stalledOnly || len(s.where) > 0
+}
+
+// resolve returns the hashes selected by the positional arguments and the
+// selector flags. Without flags the arguments are resolved as hashes; with
+// flags the arguments (if any) further restrict the selection.
+func (s *torrentSelector) resolve(ctx context.Context, client *apiClient, args []string) ([]string, error) {
+	if !s.active() {
+		if len(args) == 0 {
+			return nil, errors.New("no torrents selected")
+		}
+		return resolveHashes(ctx, client, args)
+	}
+
+	query := url.Values{}
+	if s.category != "" {
+		query.Set("category", s.category)
+	}
+	if s.tag != "" {
+		query.Set("tag", s.tag)
+	}
+	if s.stalledOnly {
+		query.Set("filter", "stalled")
+	}
+	if len(args) > 0 {
+		hashes, err := resolveHashes(ctx, client, args)
+		if err != nil {
+			return nil, err
+		}
+		if hashes[0] != "all" {
+			query.Set("hashes", strings.Join(hashes, "|"))
+		}
+	}
+
+	torrents, err := client.torrents(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	now := time.Now()
+	for _, t := range torrents {
+		if !s.where.match(t, now) {
+			continue
+		}
+		if s.tracker != "" {
+			ok, err := s.matchTracker(ctx, client, t)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		hashes = append(hashes, t.Hash)
+	}
+	return hashes, nil
+}
+
+func (s *torrentSelector) matchTracker(ctx context.Context, client *apiClient, t torrentInfo) (bool, error) {
+	if t.Tracker != "" {
+		return trackerMatches(s.tracker, t.Tracker), nil
+	}
+
+	trackers, err := client.torrentTrackers(ctx, t.Hash)
+	if err != nil {
+		return false, err
+	}
+	for _, tr := range trackers {
+		if !strings.HasPrefix(tr.URL, "** [") && trackerMatches(s.tracker, tr.URL) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func trackerMatches(pattern, trackerURL string) bool {
+	pattern = strings.ToLower(pattern)
+	trackerURL = strings.ToLower(trackerURL)
+	if ok, _ := path.Match(pattern, trackerURL); ok {
+		return true
+	}
+
+	u, err := url.Parse(trackerURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if ok, _ := path.Match(pattern, host); ok {
+		return true
+	}
+	return strings.HasSuffix(host, "."+pattern) || host == pattern
+}
+
+// trackerHost returns the host name of a tracker URL, or the URL itself if it
+// cannot be parsed.
+func trackerHost(trackerURL string) string {
+	u, err := url.Parse(trackerURL)
+	if err != nil || u.Hostname() == "" {
+		return trackerURL
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+)
+
+type transferInfo struct {
+	DlInfoSpeed      int64  `json:"dl_info_speed"`
+	DlInfoData       int64  `json:"dl_info_data"`
+	UpInfoSpeed      int64  `json:"up_info_speed"`
+	UpInfoData       int64  `json:"up_info_data"`
+	DlRateLimit      int64  `json:"dl_rate_limit"`
+	UpRateLimit      int64  `json:"up_rate_limit"`
+	DHTNodes         int64  `json:"dht_nodes"`
+	ConnectionStatus string `json:"connection_status"`
+}
+
+type torrentStats struct {
+	Hash        string  `json:"hash"`
+	Name        string  `json:"name"`
+	Category    string  `json:"category"`
+	Tracker     string  `json:"tracker"`
+	State       string  `json:"state"`
+	Size        int64   `json:"size"`
+	Downloaded  int64   `json:"downloaded"`
+	Uploaded    int64   `json:"uploaded"`
+	Ratio       float64 `json:"ratio"`
+	SeedingTime int64   `json:"seeding_time"`
+	AddedOn     int64   `json:"added_on"`
+}
+
+type trackerStats struct {
+	Tracker    string  `json:"tracker"`
+	Torrents   int     `json:"torrents"`
+	Size       int64   `json:"size"`
+	Downloaded int64   `json:"downloaded"`
+	Uploaded   int64   `json:"uploaded"`
+	Ratio      float64 `json:"ratio"`
+}
+
+func runStats(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	format := flags.String("format", "json", "output format: json or csv")
+	section := flags.String("section", "", "section to export: transfer, torrents or trackers (default all for JSON, torrents for CSV)")
+	output := flags.String("output", "", "write to this file instead of stdout")
+	category := flags.String("category", "", "only include torrents in this category")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "csv" {
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+	if *section == "" && *format == "csv" {
+		*section = "torrents"
+	}
+	switch *section {
+	case "", "transfer", "torrents", "trackers":
+	default:
+		return fmt.Errorf("unknown section %q", *section)
+	}
+
+	var transfer transferInfo
+	if err := client.getJSON(ctx, "/api/v2/transfer/info", nil, &transfer); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *category != "" {
+		query.Set("category", *category)
+	}
+	torrents, err := client.torrents(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	perTorrent := make([]torrentStats, 0, len(torrents))
+	aggregates := make(map[string]*trackerStats)
+	for _, t := range torrents {
+		host := trackerHost(t.Tracker)
+		perTorrent = append(perTorrent, torrentStats{
+			Hash:        t.Hash,
+			Name:        t.Name,
+			Category:    t.Category,
+			Tracker:     host,
+			State:       t.State,
+			Size:        t.Size,
+			Downloaded:  t.Downloaded,
+			Uploaded:    t.Uploaded,
+			Ratio:       t.Ratio,
+			SeedingTime: t.SeedingTime,
+			AddedOn:     t.AddedOn,
+		})
+
+		agg, ok := aggregates[host]
+		if !ok {
+			agg = &trackerStats{Tracker: host}
+			aggregates[host] = agg
+		}
+		agg.Torrents++
+		agg.Size += t.Size
+		agg.Downloaded += t.Downloaded
+		agg.Uploaded += t.Uploaded
+	}
+
+	perTracker := make([]trackerStats, 0, len(aggregates))
+	for _, agg := range aggregates {
+		if agg.Downloaded > 0 {
+			agg.Ratio = float64(agg.Uploaded) / float64(agg.Downloaded)
+		}
+		perTracker = append(perTracker, *agg)
+	}
+	sort.Slice(perTracker, func(i, j int) bool { return perTracker[i].Uploaded > perTracker[j].Uploaded })
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if *format == "json" {
+		var doc any
+		switch *section {
+		case "transfer":
+			doc = transfer
+		case "torrents":
+			doc = perTorrent
+		case "trackers":
+			doc = perTracker
+		default:
+			doc = map[string]any{
+				"transfer": transfer,
+				"torrents": perTorrent,
+				"trackers": perTracker,
+			}
+		}
+		return writeJSON(out, doc)
+	}
+
+	w := csv.NewWriter(out)
+	switch *section {
+	case "transfer":
+		w.Write([]string{"dl_info_speed", "dl_info_data", "up_info_speed", "up_info_data", "dl_rate_limit", "up_rate_limit", "dht_nodes", "connection_status"})
+		w.Write([]string{
+			itoa(transfer.DlInfoSpeed), itoa(transfer.DlInfoData),
+			itoa(transfer.UpInfoSpeed), itoa(transfer.UpInfoData),
+			itoa(transfer.DlRateLimit), itoa(transfer.UpRateLimit),
+			itoa(transfer.DHTNodes), transfer.ConnectionStatus,
+		})
+	case "torrents":
+		w.Write([]string{"hash", "name", "category", "tracker", "state", "size", "downloaded", "uploaded", "ratio", "seeding_time", "added_on"})
+		for _, t := range perTorrent {
+			w.Write([]string{
+				t.Hash, t.Name, t.Category, t.Tracker, t.State,
+				itoa(t.Size), itoa(t.Downloaded), itoa(t.Uploaded),
+				strconv.FormatFloat(t.Ratio, 'f', 4, 64),
+				itoa(t.SeedingTime), itoa(t.AddedOn),
+			})
+		}
+	case "trackers":
+		w.Write([]string{"tracker", "torrents", "size", "downloaded", "uploaded", "ratio"})
+		for _, t := range perTracker {
+			w.Write([]string{
+				t.Tracker, strconv.Itoa(t.Torrents),
+				itoa(t.Size), itoa(t.Downloaded), itoa(t.Uploaded),
+				strconv.FormatFloat(t.Ratio, 'f', 4, 64),
+			})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
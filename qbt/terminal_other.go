@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func terminalSize(fd uintptr) (cols, rows int, ok bool) {
+	return 0, 0, false
+}
+
+func enableCbreak(fd uintptr) (func(), error) {
+	return nil, errors.New("not supported on this platform")
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var topSortOrders = []string{"speed", "progress", "ratio", "added", "name"}
+
+// mainData mirrors the incremental state of the sync/maindata endpoint.
+// Torrents and server state are kept as raw maps because partial updates
+// only carry the fields that changed.
+type mainData struct {
+	rid         int64
+	torrents    map[string]map[string]any
+	serverState map[string]any
+}
+
+type mainDataResponse struct {
+	Rid             int64                     `json:"rid"`
+	FullUpdate      bool                      `json:"full_update"`
+	Torrents        map[string]map[string]any `json:"torrents"`
+	TorrentsRemoved []string                  `json:"torrents_removed"`
+	ServerState     map[string]any            `json:"server_state"`
+}
+
+func (m *mainData) update(ctx context.Context, client *apiClient) error {
+	var resp mainDataResponse
+	if err := client.getJSON(ctx, "/api/v2/sync/maindata", url.Values{"rid": {strconv.FormatInt(m.rid, 10)}}, &resp); err != nil {
+		return err
+	}
+	if resp.FullUpdate || m.torrents == nil {
+		m.torrents = make(map[string]map[string]any)
+		m.serverState = make(map[string]any)
+	}
+	m.rid = resp.Rid
+	for hash, fields := range resp.Torrents {
+		t, ok := m.torrents[hash]
+		if !ok {
+			t = map[string]any{"hash": hash}
+			m.torrents[hash] = t
+		}
+		for k, v := range fields {
+			t[k] = v
+		}
+	}
+	for _, hash := range resp.TorrentsRemoved {
+		delete(m.torrents, hash)
+	}
+	for k, v := range resp.ServerState {
+		m.serverState[k] = v
+	}
+	return nil
+}
+
+func (m *mainData) list() ([]torrentInfo, error) {
+	raw := make([]map[string]any, 0, len(m.torrents))
+	for _, t := range m.torrents {
+		raw = append(raw, t)
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var torrents []torrentInfo
+	if err := json.Unmarshal(data, &torrents); err != nil {
+		return nil, err
+	}
+	return torrents, nil
+}
+
+func (m *mainData) serverInt(key string) int64 {
+	n, _ := m.serverState[key].(float64)
+	return int64(n)
+}
+
+// trackerErrors caches tracker messages of torrents that have no working
+// tracker; they are not part of maindata and need one request per torrent.
+type trackerErrors struct {
+	messages map[string]string
+	checked  map[string]time.Time
+}
+
+const (
+	trackerErrorTTL      = time.Minute
+	trackerErrorsPerPoll = 5
+)
+
+func (e *trackerErrors) refresh(ctx context.Context, client *apiClient, torrents []torrentInfo, now time.Time) {
+	if e.messages == nil {
+		e.messages = make(map[string]string)
+		e.checked = make(map[string]time.Time)
+	}
+
+	present := make(map[string]bool, len(torrents))
+	fetched := 0
+	for _, t := range torrents {
+		present[t.Hash] = true
+		if t.Tracker != "" || !topTrackerCandidate(t.State) {
+			delete(e.messages, t.Hash)
+			continue
+		}
+		if now.Sub(e.checked[t.Hash]) < trackerErrorTTL || fetched >= trackerErrorsPerPoll {
+			continue
+		}
+		fetched++
+		e.checked[t.Hash] = now
+
+		trackers, err := client.torrentTrackers(ctx, t.Hash)
+		if err != nil {
+			continue
+		}
+		var msgs []string
+		for _, tr := range trackers {
+			if tr.Status == 4 && !strings.HasPrefix(tr.URL, "** [") {
+				msg := tr.Msg
+				if msg == "" {
+					msg = "not working"
+				}
+				msgs = append(msgs, trackerHost(tr.URL)+": "+msg)
+			}
+		}
+		if len(msgs) > 0 {
+			e.messages[t.Hash] = strings.Join(msgs, "; ")
+		} else {
+			delete(e.messages, t.Hash)
+		}
+	}
+	for hash := range e.checked {
+		if !present[hash] {
+			delete(e.checked, hash)
+			delete(e.messages, hash)
+		}
+	}
+}
+
+// topTrackerCandidate reports whether a torrent in this state is expected to
+// have a working tracker.
+func topTrackerCandidate(state string) bool {
+	switch state {
+	case "pausedUP", "pausedDL", "stoppedUP", "stoppedDL", "metaDL", "checkingUP", "checkingDL", "checkingResumeData", "moving", "queuedUP", "queuedDL":
+		return false
+	}
+	return true
+}
+
+func runTop(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("top", flag.ContinueOnError)
+	interval := flags.Duration("interval", 2*time.Second, "refresh interval")
+	sortBy := flags.String("sort", "speed", "sort by "+strings.Join(topSortOrders, ", "))
+	once := flags.Bool("once", false, "print a single snapshot without clearing the screen")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	sortIndex := -1
+	for i, name := range topSortOrders {
+		if name == *sortBy {
+			sortIndex = i
+		}
+	}
+	if sortIndex < 0 {
+		return fmt.Errorf("unknown sort order %q", *sortBy)
+	}
+	if *interval < 500*time.Millisecond {
+		*interval = 500 * time.Millisecond
+	}
+
+	var (
+		data   mainData
+		errs   trackerErrors
+		status string
+	)
+	draw := func() error {
+		if err := data.update(ctx, client); err != nil {
+			return err
+		}
+		torrents, err := data.list()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		errs.refresh(ctx, client, torrents, now)
+		screen := renderTop(&data, torrents, &errs, topSortOrders[sortIndex], status, now, !*once)
+		_, err = os.Stdout.WriteString(screen)
+		return err
+	}
+
+	if *once {
+		return draw()
+	}
+
+	keys := make(chan byte)
+	if restore, err := enableCbreak(os.Stdin.Fd()); err == nil {
+		defer restore()
+		go func() {
+			buf := make([]byte, 1)
+			for {
+				if n, err := os.Stdin.Read(buf); err != nil || n == 0 {
+					return
+				}
+				keys <- buf[0]
+			}
+		}()
+		status = "q quit  s sort"
+	} else {
+		status = "Ctrl-C to quit"
+	}
+
+	// Alternate screen buffer, hidden cursor; restored on exit.
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+	defer os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := draw(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case key := <-keys:
+			switch key {
+			case 'q', 'Q':
+				return nil
+			case 's', 'S':
+				sortIndex = (sortIndex + 1) % len(topSortOrders)
+			}
+		}
+	}
+}
+
+func renderTop(data *mainData, torrents []torrentInfo, errs *trackerErrors, sortBy, status string, now time.Time, interactive bool) string {
+	cols, rows, ok := terminalSize(os.Stdout.Fd())
+	if !ok {
+		cols, rows = 120, 40
+	}
+
+	sortTopTorrents(torrents, sortBy)
+
+	var counts struct{ downloading, seeding, stalled, stopped, errored int }
+	for _, t := range torrents {
+		switch {
+		case t.State == "error" || t.State == "missingFiles":
+			counts.errored++
+		case strings.HasPrefix(t.State, "stalled"):
+			counts.stalled++
+		case strings.HasPrefix(t.State, "paused") || strings.HasPrefix(t.State, "stopped"):
+			counts.stopped++
+		case t.State == "uploading" || t.State == "forcedUP":
+			counts.seeding++
+		case t.State == "downloading" || t.State == "forcedDL" || t.State == "metaDL":
+			counts.downloading++
+		}
+	}
+
+	var lines []string
+	connection, _ := data.serverState["connection_status"].(string)
+	lines = append(lines,
+		fmt.Sprintf("qbt top - %s  %s  down %s  up %s  dht %d  free %s",
+			now.Format("15:04:05"), connection,
+			formatRate(data.serverInt("dl_info_speed")), formatRate(data.serverInt("up_info_speed")),
+			data.serverInt("dht_nodes"), formatBytes(data.serverInt("free_space_on_disk"))),
+		fmt.Sprintf("torrents %d: %d downloading, %d seeding, %d stalled, %d stopped, %d errored  (sort: %s)  %s",
+			len(torrents), counts.downloading, counts.seeding, counts.stalled, counts.stopped, counts.errored, sortBy, status),
+		"",
+	)
+
+	var errorLines []string
+	if len(errs.messages) > 0 {
+		errorLines = append(errorLines, "", "TRACKER ERRORS")
+		for _, hash := range sortedKeys(errs.messages) {
+			name := hash
+			if t, ok := data.torrents[hash]; ok {
+				name, _ = t["name"].(string)
+			}
+			errorLines = append(errorLines, fmt.Sprintf("%s  %s  %s", shortHash(hash), truncate(name, 40), errs.messages[hash]))
+		}
+		if maxLines := rows / 3; len(errorLines) > maxLines && maxLines > 2 {
+			errorLines = append(errorLines[:maxLines-1], fmt.Sprintf("... %d more", len(errs.messages)-(maxLines-3)))
+		}
+	}
+
+	limit := rows - len(lines) - len(errorLines) - 1
+	if !interactive {
+		limit = len(torrents)
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	if len(torrents) > limit {
+		torrents = torrents[:limit]
+	}
+
+	var sb strings.Builder
+	t := newTable(&sb, "HASH", "NAME", "STATE", "PROGRESS", "DOWN", "UP", "RATIO", "ETA", "SEEDS", "PEERS")
+	for _, tr := range torrents {
+		t.row(
+			shortHash(tr.Hash),
+			truncate(tr.Name, 50),
+			tr.State,
+			fmt.Sprintf("%.1f%%", tr.Progress*100),
+			formatRate(tr.DlSpeed),
+			formatRate(tr.UpSpeed),
+			fmt.Sprintf("%.2f", tr.Ratio),
+			formatDuration(tr.Eta),
+			strconv.Itoa(tr.NumSeeds),
+			strconv.Itoa(tr.NumLeechs),
+		)
+	}
+	t.flush()
+	lines = append(lines, strings.Split(strings.TrimRight(sb.String(), "\n"), "\n")...)
+	lines = append(lines, errorLines...)
+
+	if !interactive {
+		return strings.Join(lines, "\n") + "\n"
+	}
+	for i, line := range lines {
+		lines[i] = truncate(line, cols)
+	}
+	return "\x1b[H\x1b[2J" + strings.Join(lines, "\n")
+}
+
+func sortTopTorrents(torrents []torrentInfo, sortBy string) {
+	sort.SliceStable(torrents, func(i, j int) bool {
+		a, b := torrents[i], torrents[j]
+		switch sortBy {
+		case "progress":
+			if a.Progress != b.Progress {
+				return a.Progress > b.Progress
+			}
+		case "ratio":
+			if a.Ratio != b.Ratio {
+				return a.Ratio > b.Ratio
+			}
+		case "added":
+			if a.AddedOn != b.AddedOn {
+				return a.AddedOn > b.AddedOn
+			}
+		case "speed":
+			if sa, sb := a.DlSpeed+a.UpSpeed, b.DlSpeed+b.UpSpeed; sa != sb {
+				return sa > sb
+			}
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+}
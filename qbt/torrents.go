@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func runList(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	filter := flags.String("filter", "", "status filter (all, downloading, seeding, completed, stopped, active, inactive, stalled, errored)")
+	category := flags.String("category", "", "only torrents in this category")
+	tag := flags.String("tag", "", "only torrents with this tag")
+	sortBy := flags.String("sort", "added_on", "sort by field (name, size, progress, ratio, added_on, dlspeed, upspeed)")
+	reverse := flags.Bool("reverse", false, "reverse sort order")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	var where filterExpr
+	flags.Var(&where, "where", "only torrents matching a filter expression, e.g. 'state=stalledUP ratio>2 added<30d'")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	query := url.Values{"sort": {*sortBy}}
+	if *filter != "" {
+		query.Set("filter", *filter)
+	}
+	if *category != "" {
+		query.Set("category", *category)
+	}
+	if *tag != "" {
+		query.Set("tag", *tag)
+	}
+	if *reverse {
+		query.Set("reverse", "true")
+	}
+
+	torrents, err := client.torrents(ctx, query)
+	if err != nil {
+		return err
+	}
+	if len(where) > 0 {
+		now := time.Now()
+		matched := torrents[:0]
+		for _, t := range torrents {
+			if where.match(t, now) {
+				matched = append(matched, t)
+			}
+		}
+		torrents = matched
+	}
+	if *asJSON {
+		return printJSON(torrents)
+	}
+
+	t := newTable(os.Stdout, "HASH", "NAME", "STATE", "PROGRESS", "SIZE", "RATIO", "DOWN", "UP", "CATEGORY")
+	for _, tr := range torrents {
+		t.row(
+			shortHash(tr.Hash),
+			truncate(tr.Name, 60),
+			tr.State,
+			fmt.Sprintf("%.1f%%", tr.Progress*100),
+			formatBytes(tr.Size),
+			fmt.Sprintf("%.2f", tr.Ratio),
+			formatRate(tr.DlSpeed),
+			formatRate(tr.UpSpeed),
+			tr.Category,
+		)
+	}
+	return t.flush()
+}
+
+func runInfo(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("info", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: qbt info [--json] <hash>")
+	}
+
+	hashes, err := resolveHashes(ctx, client, flags.Args())
+	if err != nil {
+		return err
+	}
+	torrents, err := client.torrents(ctx, url.Values{"hashes": {hashes[0]}})
+	if err != nil {
+		return err
+	}
+	if len(torrents) == 0 {
+		return fmt.Errorf("torrent %s not found", hashes[0])
+	}
+	tr := torrents[0]
+
+	var props map[string]any
+	if err := client.getJSON(ctx, "/api/v2/torrents/properties", url.Values{"hash": {tr.Hash}}, &props); err != nil {
+		return err
+	}
+	trackers, err := client.torrentTrackers(ctx, tr.Hash)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(map[string]any{
+			"torrent":    tr,
+			"properties": props,
+			"trackers":   trackers,
+		})
+	}
+
+	fields := [][2]string{
+		{"Name", tr.Name},
+		{"Hash", tr.Hash},
+		{"State", tr.State},
+		{"Progress", fmt.Sprintf("%.2f%%", tr.Progress*100)},
+		{"Size", formatBytes(tr.Size)},
+		{"Downloaded", formatBytes(tr.Downloaded)},
+		{"Uploaded", formatBytes(tr.Uploaded)},
+		{"Ratio", fmt.Sprintf("%.3f", tr.Ratio)},
+		{"Speed", fmt.Sprintf("down %s, up %s", formatRate(tr.DlSpeed), formatRate(tr.UpSpeed))},
+		{"Peers", fmt.Sprintf("%d seeds, %d leechers", tr.NumSeeds, tr.NumLeechs)},
+		{"ETA", formatDuration(tr.Eta)},
+		{"Seeding time", formatDuration(tr.SeedingTime)},
+		{"Category", tr.Category},
+		{"Tags", tr.Tags},
+		{"Save path", tr.SavePath},
+		{"Content path", tr.ContentPath},
+		{"Added", formatTime(tr.AddedOn)},
+		{"Completed", formatTime(tr.CompletionOn)},
+	}
+	if comment, _ := props["comment"].(string); comment != "" {
+		fields = append(fields, [2]string{"Comment", comment})
+	}
+
+	t := newTable(os.Stdout)
+	for _, f := range fields {
+		t.row(f[0]+":", f[1])
+	}
+	if err := t.flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	tt := newTable(os.Stdout, "TIER", "STATUS", "PEERS", "URL", "MESSAGE")
+	for _, tracker := range trackers {
+		tt.row(fmt.Sprint(tracker.Tier), trackerStatus(tracker.Status), fmt.Sprint(tracker.NumPeers), tracker.URL, tracker.Msg)
+	}
+	return tt.flush()
+}
+
+func trackerStatus(status int) string {
+	switch status {
+	case 0:
+		return "disabled"
+	case 1:
+		return "not contacted"
+	case 2:
+		return "working"
+	case 3:
+		return "updating"
+	case 4:
+		return "not working"
+	case 5:
+		return "tracker error"
+	case 6:
+		return "unreachable"
+	default:
+		return fmt.Sprint(status)
+	}
+}
+
+func runPause(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("pause", flag.ContinueOnError)
+	return runSelectedAction(ctx, client, flags, args, func(hashes string) error {
+		return client.postCompat(ctx, "/api/v2/torrents/stop", "/api/v2/torrents/pause", url.Values{"hashes": {hashes}})
+	})
+}
+
+func runResume(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("resume", flag.ContinueOnError)
+	return runSelectedAction(ctx, client, flags, args, func(hashes string) error {
+		return client.postCompat(ctx, "/api/v2/torrents/start", "/api/v2/torrents/resume", url.Values{"hashes": {hashes}})
+	})
+}
+
+func runRecheck(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("recheck", flag.ContinueOnError)
+	return runSelectedAction(ctx, client, flags, args, func(hashes string) error {
+		return client.postForm(ctx, "/api/v2/torrents/recheck", url.Values{"hashes": {hashes}})
+	})
+}
+
+func runReannounce(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("reannounce", flag.ContinueOnError)
+	return runSelectedAction(ctx, client, flags, args, func(hashes string) error {
+		return client.postForm(ctx, "/api/v2/torrents/reannounce", url.Values{"hashes": {hashes}})
+	})
+}
+
+// runSelectedAction adds the selector flags and --dry-run to flags, parses
+// args and applies action to the selected torrents.
+func runSelectedAction(ctx context.Context, client *apiClient, flags *flag.FlagSet, args []string, action func(hashes string) error) error {
+	name := flags.Name()
+	var sel torrentSelector
+	sel.register(flags)
+	dryRun := flags.Bool("dry-run", false, "print the selected torrents without acting on them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !sel.active() && flags.NArg() == 0 {
+		return fmt.Errorf("usage: qbt %s [--category c] [--tag t] [--tracker glob] [--stalled-only] [--where expr] [<hash>... | all]", name)
+	}
+
+	hashes, err := sel.resolve(ctx, client, flags.Args())
+	if err != nil {
+		return err
+	}
+	if len(hashes) == 0 {
+		fmt.Printf("%s: no matching torrents\n", name)
+		return nil
+	}
+	if *dryRun {
+		for _, h := range hashes {
+			fmt.Println(h)
+		}
+		return nil
+	}
+	return applyHashAction(name, hashes, action)
+}
+
+func runDelete(ctx context.Context, client *apiClient, args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	deleteFiles := flags.Bool("delete-files", false, "also delete downloaded data")
+	return runSelectedAction(ctx, client, flags, args, func(hashes string) error {
+		return client.postForm(ctx, "/api/v2/torrents/delete", url.Values{
+			"hashes":      {hashes},
+			"deleteFiles": {fmt.Sprint(*deleteFiles)},
+		})
+	})
+}
+
+func applyHashAction(name string, hashes []string, action func(hashes string) error) error {
+	if err := action(strings.Join(hashes, "|")); err != nil {
+		return err
+	}
+	if len(hashes) == 1 && hashes[0] == "all" {
+		fmt.Printf("%s: all torrents\n", name)
+	} else {
+		fmt.Printf("%s: %d torrent(s)\n", name, len(hashes))
+	}
+	return nil
+}
+
+// resolveHashes expands unambiguous hash prefixes (as printed by list) to
+// full info hashes.
+func resolveHashes(ctx context.Context, client *apiClient, args []string) ([]string, error) {
+	for _, a := range args {
+		if a == "all" {
+			return []string{"all"}, nil
+		}
+	}
+
+	var (
+		resolved []string
+		known    []string
+	)
+	for _, a := range args {
+		a = strings.ToLower(a)
+		if len(a) == 40 || len(a) == 64 {
+			resolved = append(resolved, a)
+			continue
+		}
+
+		if known == nil {
+			torrents, err := client.torrents(ctx, nil)
+			if err != nil {
+				return nil, err
+			}
+			for _, t := range torrents {
+				known = append(known, t.Hash)
+			}
+			sort.Strings(known)
+		}
+
+		var matches []string
+		for _, h := range known {
+			if strings.HasPrefix(h, a) {
+				matches = append(matches, h)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("no torrent matches %q", a)
+		case 1:
+			resolved = append(resolved, matches[0])
+		default:
+			return nil, fmt.Errorf("hash prefix %q is ambiguous (%d matches)", a, len(matches))
+		}
+	}
+	return resolved, nil
+}